@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// breachedPrefixLength es la longitud del prefijo del SHA-1 con el que se
+// agrupan los hashes, igual que el API de rangos de Have I Been Pwned
+const breachedPrefixLength = 5
+
+// BreachedPasswords es una lista de contraseñas filtradas cargada en memoria.
+// Se guardan solo los SHA-1, agrupados por los primeros 5 caracteres; la
+// consulta pide el grupo de un prefijo y busca el resto del hash en él, de modo
+// que se podría reemplazar por el API de rangos sin enviar el hash completo.
+type BreachedPasswords struct {
+	ranges map[string][]string // prefijo -> sufijos ordenados
+	count  int
+}
+
+// LoadBreachedPasswords lee un archivo con un SHA-1 en hexadecimal por línea,
+// opcionalmente seguido de ":apariciones" (formato de las descargas de Have I
+// Been Pwned). Las líneas vacías y las que empiezan con # se ignoran.
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir la lista de contraseñas filtradas: %v", err)
+	}
+	defer file.Close()
+
+	list := &BreachedPasswords{ranges: make(map[string][]string)}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 || !isHex(hash) {
+			return nil, fmt.Errorf("línea %d de %s: se esperaba un SHA-1 en hexadecimal", lineNumber, path)
+		}
+		prefix := hash[:breachedPrefixLength]
+		list.ranges[prefix] = append(list.ranges[prefix], hash[breachedPrefixLength:])
+		list.count++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error al leer %s: %v", path, err)
+	}
+
+	for prefix := range list.ranges {
+		sort.Strings(list.ranges[prefix])
+	}
+	return list, nil
+}
+
+// Len devuelve cuántos hashes tiene la lista
+func (b *BreachedPasswords) Len() int {
+	return b.count
+}
+
+// Range devuelve los sufijos de los hashes que empiezan con el prefijo
+func (b *BreachedPasswords) Range(prefix string) []string {
+	return b.ranges[strings.ToUpper(prefix)]
+}
+
+// Contains indica si la contraseña está en la lista
+func (b *BreachedPasswords) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes := b.Range(hash[:breachedPrefixLength])
+	suffix := hash[breachedPrefixLength:]
+	i := sort.SearchStrings(suffixes, suffix)
+	return i < len(suffixes) && suffixes[i] == suffix
+}
+
+func isHex(value string) bool {
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
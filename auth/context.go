@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// Principal representa al usuario autenticado que realiza la solicitud
+type Principal struct {
+	UserID        int
+	Role          string
+	EmailVerified bool
+	TokenID       string    // jti del token de acceso
+	SessionID     string    // Familia de tokens de actualización
+	ExpiresAt     time.Time // Expiración del token de acceso
+
+	// Solo para solicitudes autenticadas con una API key
+	APIKeyID int
+	Scopes   []string
+
+	// Administrador que suplanta al usuario, o 0 si no es una suplantación
+	ImpersonatorID int
+}
+
+// IsImpersonated indica si un administrador está actuando como este usuario
+func (p *Principal) IsImpersonated() bool {
+	return p.ImpersonatorID != 0
+}
+
+// IsAPIKey indica si la solicitud se autenticó con una API key en lugar de un token de sesión
+func (p *Principal) IsAPIKey() bool {
+	return p.APIKeyID != 0
+}
+
+// HasScope indica si la API key tiene el alcance pedido
+func (p *Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey int
+
+const principalKey contextKey = iota
+
+// WithPrincipal devuelve una copia del contexto con el usuario autenticado
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// PrincipalFromContext obtiene el usuario autenticado guardado por el middleware
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey).(*Principal)
+	return principal, ok && principal != nil
+}
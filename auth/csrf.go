@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// Cookies del modo de sesión con cookies. El token de acceso y el de
+// actualización van en cookies HttpOnly; el token CSRF va en una cookie que
+// JavaScript sí puede leer para repetirlo en la cabecera CSRFHeader.
+const (
+	SessionCookie = "np_session"
+	RefreshCookie = "np_refresh"
+	CSRFCookie    = "np_csrf"
+	CSRFHeader    = "X-CSRF-Token"
+)
+
+// NewCSRFToken genera un token aleatorio para el double-submit
+func NewCSRFToken() (string, error) {
+	token, _, err := NewOpaqueToken()
+	return token, err
+}
+
+// SafeMethod indica si el método no cambia el estado y por lo tanto no necesita
+// el token CSRF
+func SafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// ValidCSRF comprueba el double-submit: en los métodos que cambian estado la
+// cabecera debe traer el mismo valor que la cookie. Otro sitio puede hacer que
+// el navegador envíe la cookie, pero no puede leerla para copiarla.
+func ValidCSRF(r *http.Request) bool {
+	if SafeMethod(r.Method) {
+		return true
+	}
+	cookie, err := r.Cookie(CSRFCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(CSRFHeader)), []byte(cookie.Value)) == 1
+}
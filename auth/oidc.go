@@ -0,0 +1,345 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"dbconnection/config"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// jwksRefreshInterval limita cuántas veces se vuelve a descargar el JWKS del
+// proveedor cuando llega un token firmado con un kid desconocido
+const jwksRefreshInterval = time.Minute
+
+// maxOIDCResponse es el tamaño máximo que se lee de las respuestas del proveedor
+const maxOIDCResponse = 1 << 20
+
+// OIDCIdentity es la identidad que el proveedor asegura en el ID token
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// OIDCProvider implementa el lado cliente del flujo authorization code + PKCE
+// contra un proveedor OpenID Connect. Los endpoints se descubren a partir del
+// emisor y las llaves del JWKS se guardan en memoria.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	TrustEmail   bool
+	HTTPClient   *http.Client
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	keys          map[string]*rsa.PublicKey
+	keysFetchedAt time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewOIDCProvider construye el cliente de un proveedor configurado
+func NewOIDCProvider(cfg config.OIDCProviderConfig) *OIDCProvider {
+	return &OIDCProvider{
+		Name:         cfg.Name,
+		Issuer:       cfg.Issuer,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Scopes:       cfg.Scopes,
+		TrustEmail:   cfg.TrustEmail,
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewPKCE genera el code_verifier y su code_challenge S256 (RFC 7636)
+func NewPKCE() (verifier, challenge string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	verifier = base64.RawURLEncoding.EncodeToString(buf)
+	return verifier, PKCEChallenge(verifier), nil
+}
+
+// PKCEChallenge calcula el code_challenge S256 de un code_verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL arma la URL a la que se envía al usuario para autenticarse
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, challenge, redirectURI string) (string, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange canjea el código de autorización por el ID token
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier, redirectURI string) (string, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {p.ClientID},
+		"code_verifier": {verifier},
+	}
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &body)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("el proveedor %s rechazó el código (%d): %s %s", p.Name, status, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("el proveedor %s no devolvió un id_token", p.Name)
+	}
+	return body.IDToken, nil
+}
+
+// VerifyIDToken valida la firma y los claims del ID token (emisor, audiencia,
+// vigencia y nonce) y devuelve la identidad que asegura
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*OIDCIdentity, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &jwt.Parser{ValidMethods: []string{jwt.SigningMethodRS256.Alg()}}
+	claims := jwt.MapClaims{}
+	_, err = parser.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, discovery.JWKSURI, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("id_token inválido: %v", err)
+	}
+
+	// jwt-go no exige exp en MapClaims, así que se revisa aquí
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.New("id_token expirado o sin exp")
+	}
+	if iss, _ := claims["iss"].(string); iss != discovery.Issuer {
+		return nil, fmt.Errorf("id_token con emisor inesperado %q", iss)
+	}
+	if !p.audienceMatches(claims) {
+		return nil, errors.New("id_token emitido para otro cliente")
+	}
+	if got, _ := claims["nonce"].(string); nonce == "" || got != nonce {
+		return nil, errors.New("id_token con nonce inválido")
+	}
+
+	identity := &OIDCIdentity{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	if identity.Subject == "" {
+		return nil, errors.New("id_token sin sub")
+	}
+
+	// Algunos proveedores envían email_verified como texto
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	case nil:
+		identity.EmailVerified = p.TrustEmail && identity.Email != ""
+	}
+	return identity, nil
+}
+
+// audienceMatches acepta aud como texto o como lista; si hay varias audiencias
+// el claim azp debe ser nuestro client_id
+func (p *OIDCProvider) audienceMatches(claims jwt.MapClaims) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == p.ClientID
+	case []interface{}:
+		found := false
+		for _, item := range aud {
+			if value, _ := item.(string); value == p.ClientID {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+		if len(aud) > 1 {
+			azp, _ := claims["azp"].(string)
+			return azp == p.ClientID
+		}
+		return true
+	}
+	return false
+}
+
+// getDiscovery lee y guarda /.well-known/openid-configuration del emisor
+func (p *OIDCProvider) getDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	discovery := &oidcDiscovery{}
+	status, err := p.doJSON(req, discovery)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("discovery de %s respondió %d", p.Name, status)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("el emisor publicado por %s (%q) no coincide con el configurado", p.Name, discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery de %s incompleto", p.Name)
+	}
+
+	p.discovery = discovery
+	return discovery, nil
+}
+
+// publicKey busca la llave del kid; si no está se vuelve a descargar el JWKS,
+// como mucho una vez por jwksRefreshInterval, para soportar la rotación de llaves
+func (p *OIDCProvider) publicKey(ctx context.Context, jwksURI, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("llave %q desconocida", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		Keys []JWK `json:"keys"`
+	}
+	status, err := p.doJSON(req, &body)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("JWKS de %s respondió %d", p.Name, status)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(body.Keys))
+	for _, jwk := range body.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := rsaKeyFromJWK(jwk)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("llave %q desconocida", kid)
+}
+
+// doJSON ejecuta la solicitud y decodifica la respuesta JSON, si la hay
+func (p *OIDCProvider) doJSON(req *http.Request, out interface{}) (int, error) {
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxOIDCResponse)).Decode(out); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("respuesta inválida de %s: %v", p.Name, err)
+	}
+	return resp.StatusCode, nil
+}
+
+func rsaKeyFromJWK(jwk JWK) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31 {
+		return nil, errors.New("exponente RSA inválido")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxPasswordBytes es el límite de bcrypt: los bytes siguientes se ignorarían
+const maxPasswordBytes = 72
+
+// PasswordPolicy define qué contraseñas se aceptan al registrarse o al cambiarla
+type PasswordPolicy struct {
+	MinLength  int     // Mínimo de caracteres
+	MinEntropy float64 // Mínimo de bits estimados, ver EstimateEntropy
+	Breached   *BreachedPasswords
+}
+
+// Check devuelve los problemas de la contraseña, o nil si cumple la política.
+// personal son datos del usuario (correo, nombre) que la contraseña no puede contener.
+func (p *PasswordPolicy) Check(password string, personal ...string) []string {
+	var problems []string
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		problems = append(problems, fmt.Sprintf("debe tener al menos %d caracteres", p.MinLength))
+	}
+	if len(password) > maxPasswordBytes {
+		problems = append(problems, fmt.Sprintf("no puede superar los %d bytes", maxPasswordBytes))
+	}
+	if length > 0 && EstimateEntropy(password) < p.MinEntropy {
+		problems = append(problems, "es demasiado fácil de adivinar; usa más caracteres o mezcla letras, números y símbolos")
+	}
+	if containsPersonalData(password, personal) {
+		problems = append(problems, "no puede contener tu correo ni tu nombre")
+	}
+	if p.Breached != nil && p.Breached.Contains(password) {
+		problems = append(problems, "aparece en filtraciones de contraseñas conocidas; elige otra")
+	}
+	return problems
+}
+
+// EstimateEntropy estima los bits de la contraseña según los tipos de
+// caracteres que usa. Un carácter que repite el anterior o continúa una
+// secuencia ("aaa", "abc", "123") aporta solo un bit.
+func EstimateEntropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	bitsPerChar := math.Log2(float64(pool))
+	entropy := 0.0
+	var previous rune = -1
+	for _, r := range password {
+		delta := r - previous
+		if previous >= 0 && delta >= -1 && delta <= 1 {
+			entropy++
+		} else {
+			entropy += bitsPerChar
+		}
+		previous = r
+	}
+	return entropy
+}
+
+// containsPersonalData revisa el valor completo y cada palabra; en un correo
+// solo cuenta la parte local, para no vetar el dominio ("gmail", "com"). Los
+// fragmentos de menos de 3 caracteres se ignoran.
+func containsPersonalData(password string, personal []string) bool {
+	lowered := strings.ToLower(password)
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		words := value
+		if local, _, found := strings.Cut(value, "@"); found {
+			words = local
+		}
+		fragments := strings.FieldsFunc(words, func(r rune) bool {
+			return unicode.IsSpace(r) || r == '.' || r == '_' || r == '-' || r == '+'
+		})
+		fragments = append(fragments, value, words)
+		for _, fragment := range fragments {
+			if utf8.RuneCountInString(fragment) >= 3 && strings.Contains(lowered, fragment) {
+				return true
+			}
+		}
+	}
+	return false
+}
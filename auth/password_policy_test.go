@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEstimateEntropy(t *testing.T) {
+	lower := math.Log2(26)
+	tests := []struct {
+		password string
+		want     float64
+	}{
+		{"", 0},
+		{"a", lower},
+		{"qmzx", 4 * lower},
+		{"aaaa", lower + 3},                  // Repeticiones
+		{"abcd", lower + 3},                  // Secuencia ascendente
+		{"dcba", lower + 3},                  // Secuencia descendente
+		{"1234", math.Log2(10) + 3},          // Secuencia de dígitos
+		{"a1b2", 4 * math.Log2(36)},          // Minúsculas y dígitos
+		{"Ab1!", 4 * math.Log2(95)},          // Las cuatro clases ASCII
+		{"ñu", 2 * math.Log2(126)},           // Fuera de ASCII
+		{"xaaaq", 3*lower + 2},               // Solo se descuentan los caracteres repetidos
+		{"zzzzzzzzzzzzzzzzzzzz", lower + 19}, // Larga pero trivial
+	}
+	for _, tt := range tests {
+		if got := EstimateEntropy(tt.password); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("EstimateEntropy(%q) = %.3f, se esperaba %.3f", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestContainsPersonalData(t *testing.T) {
+	personal := []string{"maria.lopez+ferias@gmail.com", "María López"}
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"lopez2030!", true},                   // Fragmento de la parte local del correo
+		{"xxMARIA.LOPEZxx", true},              // Parte local completa, sin distinguir mayúsculas
+		{"ferias-del-año", true},               // Fragmento después del +
+		{"maría-2030", true},                   // Palabra del nombre con acento
+		{"gmail.com-2030", false},              // El dominio no cuenta
+		{"mazorca verde", false},               // "ma" es demasiado corto
+		{"kZ9#vQ2!pL", false},                  // Nada en común
+		{"lo-pe-z", false},                     // Fragmentos separados no forman la palabra
+		{"maria.lopez+ferias@gmail.com", true}, // El correo completo
+	}
+	for _, tt := range tests {
+		if got := containsPersonalData(tt.password, personal); got != tt.want {
+			t.Errorf("containsPersonalData(%q) = %v, se esperaba %v", tt.password, got, tt.want)
+		}
+	}
+
+	if containsPersonalData("al-li-2030", []string{"Al Li"}) {
+		t.Error("los nombres de menos de 3 caracteres no deberían vetar la contraseña")
+	}
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	breached, err := LoadBreachedPasswords(filepath.Join("testdata", "breached.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := &PasswordPolicy{MinLength: 8, MinEntropy: 40, Breached: breached}
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string // Fragmento de cada problema esperado, en orden
+	}{
+		{"válida", "kZ9#vQ2!pL", nil},
+		{"corta", "kZ9#vQ", []string{"al menos 8", "fácil de adivinar"}},
+		{"72 bytes", strings.Repeat("kZ9#vQ2!", 9), nil},
+		{"73 bytes", strings.Repeat("kZ9#vQ2!", 9) + "x", []string{"72 bytes"}},
+		{"72 bytes en runas de 2 bytes", strings.Repeat("ñé", 18), nil},
+		{"74 bytes en 37 runas", strings.Repeat("ñé", 18) + "ñ", []string{"72 bytes"}},
+		{"repetida", "aaaaaaaaaaaaaaaa", []string{"fácil de adivinar"}},
+		{"secuencia", "abcdefghijklmnop", []string{"fácil de adivinar"}},
+		{"datos personales", "lopez#Q9z!kW", []string{"correo ni tu nombre"}},
+		{"filtrada", "password", []string{"fácil de adivinar", "filtraciones"}},
+		{"filtrada de dígitos", "123456", []string{"al menos 8", "fácil de adivinar", "filtraciones"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := policy.Check(tt.password, "maria.lopez@example.com", "María López")
+			if len(problems) != len(tt.want) {
+				t.Fatalf("Check(%q) = %q, se esperaban %d problemas", tt.password, problems, len(tt.want))
+			}
+			for i, fragment := range tt.want {
+				if !strings.Contains(problems[i], fragment) {
+					t.Errorf("problema %d = %q, se esperaba que mencionara %q", i, problems[i], fragment)
+				}
+			}
+		})
+	}
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	list, err := LoadBreachedPasswords(filepath.Join("testdata", "breached.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Len() != 3 {
+		t.Errorf("Len() = %d, se esperaban 3", list.Len())
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"123456", true}, // Hash en minúsculas en el archivo
+		{"qwerty", true}, // Sin conteo de apariciones
+		{"Password", false},
+		{"password ", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := list.Contains(tt.password); got != tt.want {
+			t.Errorf("Contains(%q) = %v, se esperaba %v", tt.password, got, tt.want)
+		}
+	}
+
+	if got := list.Range("5baa6"); len(got) != 1 || got[0] != "1E4C9B93F3F0682250B6CF8331B7EE68FD8" {
+		t.Errorf("Range(\"5baa6\") = %q", got)
+	}
+}
+
+func TestLoadBreachedPasswordsRejectsInvalidLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8\nno-es-un-hash\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadBreachedPasswords(path)
+	if err == nil || !strings.Contains(err.Error(), "línea 2") {
+		t.Errorf("err = %v, se esperaba un error en la línea 2", err)
+	}
+}
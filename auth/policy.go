@@ -0,0 +1,136 @@
+package auth
+
+// Roles de los usuarios
+const (
+	RoleAdmin     = "admin"
+	RoleOrganizer = "organizer"
+	RoleExhibitor = "exhibitor"
+	RoleVisitor   = "visitor"
+)
+
+// ValidRole indica si el rol es uno de los roles conocidos
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleOrganizer, RoleExhibitor, RoleVisitor:
+		return true
+	}
+	return false
+}
+
+// Alcances que se pueden otorgar a una API key
+const (
+	ScopeFairsRead  = "fairs:read"
+	ScopeFairsWrite = "fairs:write"
+)
+
+// ValidScope indica si el alcance es uno de los alcances conocidos
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeFairsRead, ScopeFairsWrite:
+		return true
+	}
+	return false
+}
+
+// Action identifica una operación sujeta a autorización
+type Action string
+
+const (
+	ActionCreateFair        Action = "fairs:create"
+	ActionUpdateFair        Action = "fairs:update"
+	ActionDeleteFair        Action = "fairs:delete"
+	ActionUpdateProfile     Action = "users:update"
+	ActionUpdatePreferences Action = "preferences:update"
+	ActionManageUsers       Action = "users:manage"
+	ActionReadFairs         Action = "fairs:read"
+	ActionManageAPIKeys     Action = "apikeys:manage"
+	ActionReadAuditLog      Action = "audit:read"
+	ActionImpersonate       Action = "users:impersonate"
+	ActionManageCredentials Action = "credentials:manage"
+	ActionRegisterFair      Action = "fairs:register"
+	ActionReadRegistrations Action = "registrations:read"
+	ActionCheckIn           Action = "registrations:checkin"
+	ActionManageStands      Action = "stands:manage"
+	ActionDeleteStand       Action = "stands:delete"
+)
+
+// actionScopes son las acciones que puede ejecutar una API key y el alcance que
+// necesita para cada una; cualquier otra acción le está vetada
+var actionScopes = map[Action]string{
+	ActionReadFairs:  ScopeFairsRead,
+	ActionCreateFair: ScopeFairsWrite,
+	ActionUpdateFair: ScopeFairsWrite,
+	ActionDeleteFair: ScopeFairsWrite,
+	// Los stands son parte de la feria, así que usan los mismos alcances
+	ActionManageStands: ScopeFairsWrite,
+	ActionDeleteStand:  ScopeFairsWrite,
+}
+
+// impersonationBlocked son las acciones que no se pueden hacer mientras se
+// suplanta a un usuario: borrar datos, administrar usuarios y cambiar las
+// credenciales de la cuenta (contraseña, passkeys, MFA, sesiones, API keys)
+var impersonationBlocked = map[Action]bool{
+	ActionDeleteFair:        true,
+	ActionDeleteStand:       true,
+	ActionManageUsers:       true,
+	ActionManageAPIKeys:     true,
+	ActionImpersonate:       true,
+	ActionManageCredentials: true,
+}
+
+// unverifiedRestricted son las acciones que no pueden ejecutar los usuarios
+// con el correo sin verificar; se configura una sola vez al iniciar
+var unverifiedRestricted = map[Action]bool{}
+
+// RestrictUnverified define las acciones que requieren el correo verificado
+func RestrictUnverified(actions []Action) {
+	unverifiedRestricted = make(map[Action]bool, len(actions))
+	for _, action := range actions {
+		unverifiedRestricted[action] = true
+	}
+}
+
+// Can decide si el usuario puede ejecutar la acción. ownerID es el dueño del
+// recurso afectado, o 0 si la acción no recae sobre un recurso existente.
+// Los administradores pueden ejecutar cualquier acción. Una API key además
+// necesita el alcance correspondiente, aunque sea de un administrador, y
+// durante una suplantación se vetan las acciones de impersonationBlocked.
+func Can(principal *Principal, action Action, ownerID int) bool {
+	if principal == nil {
+		return false
+	}
+	if principal.IsAPIKey() {
+		scope, ok := actionScopes[action]
+		if !ok || !principal.HasScope(scope) {
+			return false
+		}
+	}
+	if principal.IsImpersonated() && impersonationBlocked[action] {
+		return false
+	}
+	if principal.Role == RoleAdmin {
+		return true
+	}
+	if !principal.EmailVerified && unverifiedRestricted[action] {
+		return false
+	}
+
+	isOwner := ownerID != 0 && ownerID == principal.UserID
+	switch action {
+	case ActionReadFairs:
+		return true
+	case ActionCreateFair:
+		return principal.Role == RoleOrganizer
+	case ActionUpdateFair, ActionDeleteFair, ActionManageStands, ActionDeleteStand:
+		return isOwner
+	case ActionRegisterFair:
+		// Cualquier usuario se puede inscribir, siempre a su propio nombre
+		return true
+	case ActionReadRegistrations, ActionCheckIn:
+		// Solo el organizador de la feria ve a los inscritos y registra su entrada
+		return isOwner
+	case ActionUpdateProfile, ActionUpdatePreferences, ActionManageAPIKeys, ActionManageCredentials:
+		return isOwner
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"dbconnection/config"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestTokenManager(t *testing.T) *TokenManager {
+	t.Helper()
+	manager, err := NewTokenManager(&config.Config{JWTTemporaryKey: true, JWTAccessTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manager
+}
+
+func issueTestTicket(t *testing.T, manager *TokenManager, expiresAt time.Time) string {
+	t.Helper()
+	ticket, err := manager.IssueTicket(TicketClaims{RegistrationID: 7, FairID: 3, UserID: 11, Code: "codigo"}, expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ticket
+}
+
+func TestParseTicketRoundTrip(t *testing.T) {
+	manager := newTestTokenManager(t)
+
+	ticket, err := manager.ParseTicket(issueTestTicket(t, manager, time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.RegistrationID != 7 || ticket.FairID != 3 || ticket.UserID != 11 || ticket.Code != "codigo" || ticket.Purpose != PurposeTicket {
+		t.Errorf("boleto leído = %+v", ticket)
+	}
+
+	// Sin fecha de fin de la feria el boleto no vence
+	if _, err := manager.ParseTicket(issueTestTicket(t, manager, time.Time{})); err != nil {
+		t.Errorf("boleto sin vencimiento rechazado: %v", err)
+	}
+}
+
+func TestParseTicketRejects(t *testing.T) {
+	manager := newTestTokenManager(t)
+	valid := issueTestTicket(t, manager, time.Now().Add(time.Hour))
+
+	// Cambiar la feria del boleto sin volver a firmarlo
+	parts := strings.Split(valid, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(payload), `"fid":3`, `"fid":4`, 1)))
+	tampered := strings.Join(parts, ".")
+	if tampered == valid {
+		t.Fatal("no se pudo alterar el boleto")
+	}
+
+	accessToken, _, err := manager.IssueAccessToken(Claims{UserID: 11, Role: RoleVisitor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	purposeToken, err := manager.IssuePurposeToken(Claims{UserID: 11}, "email_verification", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, token string
+	}{
+		{"firma alterada", tampered},
+		{"otra llave", issueTestTicket(t, newTestTokenManager(t), time.Now().Add(time.Hour))},
+		{"vencido", issueTestTicket(t, manager, time.Now().Add(-time.Minute))},
+		{"token de acceso", accessToken},
+		{"token de otro propósito", purposeToken},
+		{"vacío", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := manager.ParseTicket(tt.token); err == nil {
+				t.Error("se aceptó el boleto")
+			}
+		})
+	}
+}
+
+func TestParseAccessTokenRejectsTicket(t *testing.T) {
+	manager := newTestTokenManager(t)
+	if _, err := manager.ParseAccessToken(issueTestTicket(t, manager, time.Now().Add(time.Hour))); err == nil {
+		t.Error("un boleto se aceptó como token de acceso")
+	}
+}
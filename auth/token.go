@@ -0,0 +1,334 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"dbconnection/config"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Claims son los datos que viajan dentro de los tokens emitidos por la API.
+// Se mantiene "userId" por compatibilidad con los tokens que ya usa el frontend.
+type Claims struct {
+	UserID        int    `json:"userId"`
+	Role          string `json:"role,omitempty"`
+	EmailVerified bool   `json:"ev,omitempty"`
+	SessionID     string `json:"sid,omitempty"` // Familia de tokens de actualización del login
+
+	// Purpose distingue los tokens de un solo propósito (por ejemplo la
+	// verificación de correo) de los tokens de acceso, que no lo llevan
+	Purpose string `json:"purpose,omitempty"`
+	Email   string `json:"email,omitempty"`
+
+	// Actor identifica al administrador que suplanta al usuario (claim "act" de
+	// RFC 8693); solo lo llevan los tokens de suplantación
+	Actor *Actor `json:"act,omitempty"`
+
+	jwt.StandardClaims
+}
+
+// Actor es el usuario que realmente actúa cuando el token es de suplantación
+type Actor struct {
+	Subject string `json:"sub"`
+	UserID  int    `json:"userId"`
+}
+
+// Propósitos de los tokens que no sirven como tokens de acceso
+const (
+	PurposeEmailVerification = "email_verification"
+	PurposeMFA               = "mfa_pending"
+)
+
+// TokenManager es el único punto de la aplicación que emite y valida tokens JWT.
+// Firma siempre con la llave activa y acepta cualquiera de las llaves
+// configuradas, identificadas por la cabecera "kid", para poder rotarlas sin
+// invalidar los tokens vigentes.
+type TokenManager struct {
+	method     jwt.SigningMethod
+	activeKID  string
+	signingKey interface{}
+	verifyKeys map[string]interface{}
+	issuer     string
+	accessTTL  time.Duration
+	publicKeys map[string]*rsa.PublicKey
+}
+
+// NewTokenManager construye el TokenManager a partir de la configuración.
+// Solo se admiten HS256 y RS256, que son los algoritmos que soporta jwt-go.
+func NewTokenManager(cfg *config.Config) (*TokenManager, error) {
+	manager := &TokenManager{
+		activeKID:  cfg.JWTActiveKeyID,
+		verifyKeys: make(map[string]interface{}),
+		publicKeys: make(map[string]*rsa.PublicKey),
+		issuer:     cfg.JWTIssuer,
+		accessTTL:  cfg.JWTAccessTTL,
+	}
+
+	entries, err := parseKeyList(cfg.JWTKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm := strings.ToUpper(cfg.JWTAlgorithm)
+	if (algorithm == "" || algorithm == "HS256") && len(entries) == 0 && cfg.JWTTemporaryKey {
+		// Solo para desarrollo: los tokens dejan de ser válidos al reiniciar el
+		// servidor y no los aceptan otras instancias
+		log.Println("JWT_KEYS no está configurado; se usa una llave temporal (JWT_TEMPORARY_KEY)")
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		entries = []keyEntry{{kid: "dev", value: string(secret)}}
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("JWT_KEYS no está configurado")
+	}
+	if manager.activeKID == "" && len(entries) > 0 {
+		manager.activeKID = entries[0].kid
+	}
+
+	switch algorithm {
+	case "", "HS256":
+		manager.method = jwt.SigningMethodHS256
+		for _, entry := range entries {
+			manager.verifyKeys[entry.kid] = []byte(entry.value)
+		}
+	case "RS256":
+		manager.method = jwt.SigningMethodRS256
+		for _, entry := range entries {
+			if err := manager.loadRSAKey(entry); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("algoritmo JWT no soportado: %s", cfg.JWTAlgorithm)
+	}
+
+	if manager.method == jwt.SigningMethodHS256 {
+		manager.signingKey = manager.verifyKeys[manager.activeKID]
+	}
+	if manager.signingKey == nil {
+		return nil, fmt.Errorf("no hay una llave de firma para el kid activo %q", manager.activeKID)
+	}
+
+	return manager, nil
+}
+
+// IssueAccessToken firma un token de acceso con los datos propios del usuario
+// (ID, rol, sesión); los claims estándar los completa el TokenManager
+func (m *TokenManager) IssueAccessToken(claims Claims) (string, time.Time, error) {
+	return m.issueAccess(claims, m.accessTTL)
+}
+
+// IssueImpersonationToken firma un token de acceso del usuario suplantado que
+// lleva en el claim act al administrador. No tiene sesión ni token de
+// actualización: al vencer hay que pedir otro.
+func (m *TokenManager) IssueImpersonationToken(claims Claims, actorID int, ttl time.Duration) (string, time.Time, error) {
+	claims.Actor = &Actor{Subject: strconv.Itoa(actorID), UserID: actorID}
+	claims.SessionID = ""
+	return m.issueAccess(claims, ttl)
+}
+
+func (m *TokenManager) issueAccess(claims Claims, ttl time.Duration) (string, time.Time, error) {
+	jti, err := newID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims.StandardClaims = jwt.StandardClaims{
+		Id:        jti,
+		Subject:   strconv.Itoa(claims.UserID),
+		Issuer:    m.issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}
+
+	tokenString, err := m.sign(&claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenString, expiresAt, nil
+}
+
+// ParseAccessToken verifica la firma, la expiración y el emisor del token
+func (m *TokenManager) ParseAccessToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	if err := m.parse(tokenString, claims); err != nil {
+		return nil, err
+	}
+	if claims.UserID == 0 {
+		return nil, errors.New("el token no contiene el ID del usuario")
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("el token no es un token de acceso")
+	}
+	if claims.Actor != nil && (claims.Actor.UserID == 0 || claims.Actor.UserID == claims.UserID) {
+		return nil, errors.New("claim act inválido")
+	}
+	// Los tokens emitidos antes de existir los roles se tratan como visitantes
+	if claims.Role == "" {
+		claims.Role = RoleVisitor
+	}
+	return claims, nil
+}
+
+// IssuePurposeToken firma un token de un solo propósito con la vigencia indicada.
+// Estos tokens nunca se aceptan como tokens de acceso.
+func (m *TokenManager) IssuePurposeToken(claims Claims, purpose string, ttl time.Duration) (string, error) {
+	jti, err := newID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims.Purpose = purpose
+	claims.StandardClaims = jwt.StandardClaims{
+		Id:        jti,
+		Subject:   strconv.Itoa(claims.UserID),
+		Issuer:    m.issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+	return m.sign(&claims)
+}
+
+// ParsePurposeToken valida un token de un solo propósito y que sea del propósito esperado
+func (m *TokenManager) ParsePurposeToken(tokenString, purpose string) (*Claims, error) {
+	claims := &Claims{}
+	if err := m.parse(tokenString, claims); err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purpose || claims.UserID == 0 {
+		return nil, errors.New("el token no corresponde a esta operación")
+	}
+	return claims, nil
+}
+
+func (m *TokenManager) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(m.method, claims)
+	token.Header["kid"] = m.activeKID
+	return token.SignedString(m.signingKey)
+}
+
+// parse valida cualquier tipo de claims emitidas por este TokenManager
+func (m *TokenManager) parse(tokenString string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != m.method.Alg() {
+			return nil, fmt.Errorf("método de firma inesperado: %v", token.Header["alg"])
+		}
+
+		// Los tokens sin "kid" se validan con la llave activa
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = m.activeKID
+		}
+		key, ok := m.verifyKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("llave desconocida: %q", kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("token inválido")
+	}
+
+	if standard, ok := claims.(interface{ VerifyIssuer(string, bool) bool }); ok && !standard.VerifyIssuer(m.issuer, m.issuer != "") {
+		return errors.New("emisor del token inválido")
+	}
+	return nil
+}
+
+// JWK es la representación pública de una llave RSA según RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKS devuelve las llaves públicas de verificación. Con HS256 las llaves son
+// secretas, así que no se publica nada y ok es false.
+func (m *TokenManager) JWKS() (keys []JWK, ok bool) {
+	if m.method != jwt.SigningMethodRS256 {
+		return nil, false
+	}
+
+	keys = make([]JWK, 0, len(m.publicKeys))
+	for kid, key := range m.publicKeys {
+		keys = append(keys, JWK{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: m.method.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	return keys, true
+}
+
+// loadRSAKey lee un archivo PEM; una llave privada también puede firmar, una
+// pública solo sirve para validar tokens de una llave ya retirada
+func (m *TokenManager) loadRSAKey(entry keyEntry) error {
+	pemBytes, err := os.ReadFile(entry.value)
+	if err != nil {
+		return fmt.Errorf("no se pudo leer la llave %q: %v", entry.kid, err)
+	}
+
+	if privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes); err == nil {
+		m.verifyKeys[entry.kid] = &privateKey.PublicKey
+		m.publicKeys[entry.kid] = &privateKey.PublicKey
+		if entry.kid == m.activeKID {
+			m.signingKey = privateKey
+		}
+		return nil
+	}
+
+	publicKey, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes)
+	if err != nil {
+		return fmt.Errorf("la llave %q no es una llave RSA válida: %v", entry.kid, err)
+	}
+	m.verifyKeys[entry.kid] = publicKey
+	m.publicKeys[entry.kid] = publicKey
+	return nil
+}
+
+type keyEntry struct {
+	kid   string
+	value string
+}
+
+// parseKeyList interpreta JWT_KEYS con el formato "kid1:valor1,kid2:valor2",
+// donde el valor es el secreto (HS256) o la ruta al archivo PEM (RS256)
+func parseKeyList(raw string) ([]keyEntry, error) {
+	var entries []keyEntry
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kid, value, found := strings.Cut(item, ":")
+		if !found || kid == "" || value == "" {
+			return nil, fmt.Errorf("entrada inválida en JWT_KEYS: se esperaba kid:valor")
+		}
+		entries = append(entries, keyEntry{kid: kid, value: value})
+	}
+	return entries, nil
+}
@@ -0,0 +1,220 @@
+// Proveedor OpenID Connect mínimo para probar el login OIDC en local sin
+// depender de Google o Microsoft. Genera una llave RSA al arrancar, acepta a
+// cualquier usuario que escriba su correo y solo soporta authorization code + PKCE.
+//
+// Uso:
+//
+//	go run ./cmd/mockidp -addr :9000 -client-id netproject
+//
+// y en la configuración de la API:
+//
+//	OIDC_PROVIDERS=mock
+//	OIDC_MOCK_ISSUER=http://localhost:9000
+//	OIDC_MOCK_CLIENT_ID=netproject
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"dbconnection/auth"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const keyID = "mock"
+
+// authorization es un código emitido que todavía no se canjeó
+type authorization struct {
+	clientID      string
+	redirectURI   string
+	challenge     string
+	nonce         string
+	email         string
+	name          string
+	emailVerified bool
+	expiresAt     time.Time
+}
+
+type server struct {
+	issuer   string
+	clientID string
+	key      *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]*authorization
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<html><body>
+<h1>Mock IdP</h1>
+<form method="get" action="/authorize">
+{{range $key, $values := .}}{{range $values}}<input type="hidden" name="{{$key}}" value="{{.}}">{{end}}{{end}}
+<p><label>Email <input name="email" type="email" required></label></p>
+<p><label>Nombre <input name="name"></label></p>
+<p><label><input name="email_verified" type="checkbox" value="true" checked> Correo verificado</label></p>
+<p><button type="submit">Iniciar sesión</button></p>
+</form>
+</body></html>`))
+
+func main() {
+	addr := flag.String("addr", ":9000", "dirección en la que escucha el proveedor")
+	issuer := flag.String("issuer", "http://localhost:9000", "URL pública del proveedor (claim iss)")
+	clientID := flag.String("client-id", "netproject", "client_id aceptado")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalf("Error al generar la llave RSA: %v", err)
+	}
+
+	s := &server{issuer: *issuer, clientID: *clientID, key: key, codes: map[string]*authorization{}}
+	http.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	http.HandleFunc("/authorize", s.authorize)
+	http.HandleFunc("/token", s.token)
+	http.HandleFunc("/jwks", s.jwks)
+
+	log.Printf("Mock IdP escuchando en %s (issuer %s)", *addr, *issuer)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+func (s *server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.issuer + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"jwks_uri":                              s.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize muestra el formulario y, cuando llega el correo, redirige de vuelta
+// a la aplicación con el código. Pasar email= en la URL permite usarlo sin navegador.
+func (s *server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("response_type") != "code" || query.Get("client_id") != s.clientID ||
+		query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	email := query.Get("email")
+	if email == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		loginPage.Execute(w, query)
+		return
+	}
+
+	code, _, err := auth.NewOpaqueToken()
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+	s.mu.Lock()
+	s.codes[code] = &authorization{
+		clientID:      query.Get("client_id"),
+		redirectURI:   query.Get("redirect_uri"),
+		challenge:     query.Get("code_challenge"),
+		nonce:         query.Get("nonce"),
+		email:         email,
+		name:          query.Get("name"),
+		emailVerified: query.Get("email_verified") == "true",
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token canjea el código (una sola vez) comprobando el client_id, la
+// redirect_uri y el code_verifier de PKCE
+func (s *server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	s.mu.Lock()
+	code := r.PostForm.Get("code")
+	authz := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+
+	if authz == nil || time.Now().After(authz.expiresAt) ||
+		authz.clientID != r.PostForm.Get("client_id") ||
+		authz.redirectURI != r.PostForm.Get("redirect_uri") ||
+		auth.PKCEChallenge(r.PostForm.Get("code_verifier")) != authz.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	// El sub se deriva del correo para que el mismo usuario obtenga siempre el mismo
+	sum := sha256.Sum256([]byte(authz.email))
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            s.issuer,
+		"sub":            hex.EncodeToString(sum[:16]),
+		"aud":            authz.clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          authz.nonce,
+		"email":          authz.email,
+		"email_verified": authz.emailVerified,
+		"name":           authz.name,
+	})
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": idToken,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (s *server) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []auth.JWK{{
+			Kty: "RSA",
+			Kid: keyID,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -2,8 +2,14 @@
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -14,20 +20,339 @@ type Config struct {
 	DBHost     string
 	DBPort     string
 	DBName     string
+	BcryptCost int // Costo de bcrypt para los hashes de contraseñas
+
+	// Política de contraseñas
+	PasswordMinLength     int
+	PasswordMinEntropy    int    // Bits estimados
+	BreachedPasswordsFile string // SHA-1 de contraseñas filtradas, uno por línea
+
+	// Pool de conexiones
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+
+	// Servidor HTTP
+	ListenAddr            string
+	HTTPReadTimeout       time.Duration
+	HTTPReadHeaderTimeout time.Duration
+	HTTPWriteTimeout      time.Duration
+	HTTPIdleTimeout       time.Duration
+
+	// CORS
+	CORSAllowedOrigins   []string
+	CORSAllowCredentials bool
+
+	// Imágenes
+	MediaBackend        string // "cloudinary" o "none" (se rechazan las subidas)
+	CloudinaryCloudName string
+	CloudinaryAPIKey    string
+	CloudinaryAPISecret string
+
+	// Búsqueda de ferias
+	FairSearchBackend string // "fulltext" (índice de MySQL) o "scan" (revisión en Go)
+
+	// Tokens JWT
+	JWTAlgorithm    string        // HS256 o RS256
+	JWTKeys         string        // "kid:secreto,..." (HS256) o "kid:ruta.pem,..." (RS256)
+	JWTTemporaryKey bool          // Sin JWTKeys, firmar con una llave aleatoria (solo desarrollo)
+	JWTActiveKeyID  string        // kid con el que se firman los tokens nuevos
+	JWTIssuer       string        // Valor del claim "iss"
+	JWTAccessTTL    time.Duration // Vigencia de los tokens de acceso
+	RefreshTTL      time.Duration // Vigencia de los tokens de actualización
+
+	// Correo
+	AppBaseURL   string // URL pública del frontend, usada en los enlaces de los correos
+	MailBackend  string // "log" o "smtp"
+	MailFrom     string
+	MailLogPath  string // Archivo donde escribe el backend "log"; vacío para usar el log
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUser     string
+	SMTPPassword string
+
+	PasswordResetTTL        time.Duration // Vigencia de los enlaces para restablecer la contraseña
+	PasswordResetResendWait time.Duration // Tiempo mínimo entre dos enlaces para la misma cuenta
+
+	// Verificación de correo
+	EmailVerificationTTL   time.Duration
+	VerificationResendWait time.Duration // Tiempo mínimo entre reenvíos del correo de verificación
+	UnverifiedRestrictions []string      // Acciones (por ejemplo "fairs:create") vetadas sin correo verificado
+
+	// Protección contra fuerza bruta en el login
+	LoginMaxAttempts   int
+	LoginMaxAttemptsIP int
+	LoginWindow        time.Duration // Los fallos más antiguos que esto se olvidan
+	LoginLockout       time.Duration // Bloqueo inicial; se duplica con cada fallo adicional
+	LoginMaxLockout    time.Duration
+	TrustProxyHeaders  bool // Tomar la IP del cliente de X-Forwarded-For
+	NewDeviceAlerts    bool // Avisar por correo de los inicios de sesión desde un dispositivo nuevo
+
+	// Inicio de sesión sin contraseña
+	MagicLinkTTL        time.Duration
+	MagicLinkResendWait time.Duration // Tiempo mínimo entre dos enlaces para la misma cuenta
+	CookieSecure        bool          // Enviar las cookies solo por HTTPS
+
+	// Verificación en dos pasos (TOTP)
+	MFAIssuer       string        // Nombre que muestran las aplicaciones autenticadoras
+	MFAChallengeTTL time.Duration // Tiempo para enviar el código tras la contraseña
+
+	// Suplantación de usuarios por parte de soporte
+	ImpersonationTTL time.Duration
+
+	// Passkeys (WebAuthn)
+	WebAuthnRPID    string // Dominio al que quedan ligadas las passkeys
+	WebAuthnRPName  string
+	WebAuthnOrigins []string // Orígenes del frontend desde los que se aceptan las ceremonias
+	WebAuthnTimeout time.Duration
+
+	// Inicio de sesión con proveedores OpenID Connect
+	OIDCProviders   []OIDCProviderConfig
+	OIDCRedirectURL string        // Página del frontend registrada como redirect_uri en los proveedores
+	OIDCStateTTL    time.Duration // Tiempo para volver del proveedor con el código
+
+	// Valores crudos de cada opción, usados para imprimir la configuración
+	values map[string]string
 }
 
+// LoadConfig carga la configuración a partir de los argumentos del proceso y
+// termina el programa si no es válida
 func LoadConfig() *Config {
-	// Cargar el archivo .env
-	err := godotenv.Load()
+	cfg, err := Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("Configuración inválida:\n%v", err)
+	}
+	return cfg
+}
+
+// Load arma la configuración por capas, de menor a mayor prioridad: valores por
+// defecto, archivo (.env si existe, o el indicado con CONFIG_FILE / -config),
+// variables de entorno y flags. Devuelve un *ValidationError con todos los
+// problemas encontrados.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("netproject", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "archivo de configuración (por defecto .env si existe)")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.key] = fs.String(flagName(s.key), s.def, s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(settings))
+	for _, s := range settings {
+		values[s.key] = s.def
+	}
+
+	// Archivo: si no se indicó ninguno, .env es opcional
+	path, explicit := *configFile, *configFile != ""
+	if !explicit {
+		path = ".env"
+	}
+	fileValues, err := godotenv.Read(path)
+	if err != nil {
+		if explicit || !os.IsNotExist(err) {
+			return nil, fmt.Errorf("Error al leer el archivo de configuración %s: %v", path, err)
+		}
+	} else {
+		log.Printf("Configuración leída de %s", path)
+	}
+	for key, value := range fileValues {
+		if _, known := values[key]; known || isProviderKey(key) {
+			values[key] = value
+		}
+	}
+
+	// Las variables de entorno tienen prioridad sobre el archivo
+	for _, entry := range os.Environ() {
+		key, value, _ := strings.Cut(entry, "=")
+		if _, known := values[key]; known || isProviderKey(key) {
+			values[key] = value
+		}
+	}
+
+	// Y los flags indicados explícitamente sobre todo lo anterior
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if flagName(s.key) == f.Name {
+				values[s.key] = *flagValues[s.key]
+			}
+		}
+	})
+
+	return parse(values)
+}
+
+// parse convierte los valores crudos en la configuración tipada y la valida
+func parse(values map[string]string) (*Config, error) {
+	p := &parser{values: values}
+	cfg := &Config{
+		DBUser:     p.str("DB_USER"),
+		DBPassword: p.str("DB_PASSWORD"),
+		DBHost:     p.str("DB_HOST"),
+		DBPort:     p.str("DB_PORT"),
+		DBName:     p.str("DB_NAME"),
+		BcryptCost: p.int("BCRYPT_COST"),
+
+		PasswordMinLength:     p.int("PASSWORD_MIN_LENGTH"),
+		PasswordMinEntropy:    p.int("PASSWORD_MIN_ENTROPY"),
+		BreachedPasswordsFile: p.str("BREACHED_PASSWORDS_FILE"),
+
+		DBMaxOpenConns:    p.int("DB_MAX_OPEN_CONNS"),
+		DBMaxIdleConns:    p.int("DB_MAX_IDLE_CONNS"),
+		DBConnMaxLifetime: p.duration("DB_CONN_MAX_LIFETIME"),
+
+		ListenAddr:            p.str("LISTEN_ADDR"),
+		HTTPReadTimeout:       p.duration("HTTP_READ_TIMEOUT"),
+		HTTPReadHeaderTimeout: p.duration("HTTP_READ_HEADER_TIMEOUT"),
+		HTTPWriteTimeout:      p.duration("HTTP_WRITE_TIMEOUT"),
+		HTTPIdleTimeout:       p.duration("HTTP_IDLE_TIMEOUT"),
+
+		CORSAllowedOrigins:   p.list("CORS_ALLOWED_ORIGINS"),
+		CORSAllowCredentials: p.bool("CORS_ALLOW_CREDENTIALS"),
+
+		MediaBackend:        strings.ToLower(p.str("MEDIA_BACKEND")),
+		CloudinaryCloudName: p.str("CLOUDINARY_CLOUD_NAME"),
+		CloudinaryAPIKey:    p.str("CLOUDINARY_API_KEY"),
+		CloudinaryAPISecret: p.str("CLOUDINARY_API_SECRET"),
+
+		FairSearchBackend: strings.ToLower(p.str("FAIR_SEARCH_BACKEND")),
+
+		JWTAlgorithm:    strings.ToUpper(p.str("JWT_ALGORITHM")),
+		JWTKeys:         p.str("JWT_KEYS"),
+		JWTTemporaryKey: p.bool("JWT_TEMPORARY_KEY"),
+		JWTActiveKeyID:  p.str("JWT_ACTIVE_KID"),
+		JWTIssuer:       p.str("JWT_ISSUER"),
+		JWTAccessTTL:    p.duration("JWT_ACCESS_TTL"),
+		RefreshTTL:      p.duration("REFRESH_TOKEN_TTL"),
+
+		AppBaseURL:   p.str("APP_BASE_URL"),
+		MailBackend:  strings.ToLower(p.str("MAIL_BACKEND")),
+		MailFrom:     p.str("MAIL_FROM"),
+		MailLogPath:  p.str("MAIL_LOG_PATH"),
+		SMTPHost:     p.str("SMTP_HOST"),
+		SMTPPort:     p.str("SMTP_PORT"),
+		SMTPUser:     p.str("SMTP_USER"),
+		SMTPPassword: p.str("SMTP_PASSWORD"),
+
+		PasswordResetTTL:        p.duration("PASSWORD_RESET_TTL"),
+		PasswordResetResendWait: p.duration("PASSWORD_RESET_RESEND_WAIT"),
+
+		EmailVerificationTTL:   p.duration("EMAIL_VERIFICATION_TTL"),
+		VerificationResendWait: p.duration("EMAIL_VERIFICATION_RESEND_WAIT"),
+		UnverifiedRestrictions: p.list("UNVERIFIED_RESTRICTIONS"),
+
+		LoginMaxAttempts:   p.int("LOGIN_MAX_ATTEMPTS"),
+		LoginMaxAttemptsIP: p.int("LOGIN_MAX_ATTEMPTS_IP"),
+		LoginWindow:        p.duration("LOGIN_ATTEMPT_WINDOW"),
+		LoginLockout:       p.duration("LOGIN_LOCKOUT"),
+		LoginMaxLockout:    p.duration("LOGIN_MAX_LOCKOUT"),
+		TrustProxyHeaders:  p.bool("TRUST_PROXY_HEADERS"),
+		NewDeviceAlerts:    p.bool("NEW_DEVICE_ALERTS"),
+
+		MagicLinkTTL:        p.duration("MAGIC_LINK_TTL"),
+		MagicLinkResendWait: p.duration("MAGIC_LINK_RESEND_WAIT"),
+		CookieSecure:        p.bool("COOKIE_SECURE"),
+
+		MFAIssuer:       p.str("MFA_ISSUER"),
+		MFAChallengeTTL: p.duration("MFA_CHALLENGE_TTL"),
+
+		ImpersonationTTL: p.duration("IMPERSONATION_TTL"),
+
+		WebAuthnRPID:    p.str("WEBAUTHN_RP_ID"),
+		WebAuthnRPName:  p.str("WEBAUTHN_RP_NAME"),
+		WebAuthnOrigins: p.list("WEBAUTHN_ORIGINS"),
+		WebAuthnTimeout: p.duration("WEBAUTHN_TIMEOUT"),
+
+		OIDCRedirectURL: p.str("OIDC_REDIRECT_URL"),
+		OIDCStateTTL:    p.duration("OIDC_STATE_TTL"),
+
+		values: values,
+	}
+	cfg.OIDCProviders = p.providers(p.list("OIDC_PROVIDERS"))
+
+	problems := append(p.problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// String imprime la configuración con los secretos ocultos
+func (cfg *Config) String() string {
+	var b strings.Builder
+	for _, s := range settings {
+		value := cfg.values[s.key]
+		if s.secret && value != "" {
+			value = "[REDACTED]"
+		}
+		fmt.Fprintf(&b, "%s=%s\n", s.key, value)
+	}
+	for _, provider := range cfg.OIDCProviders {
+		b.WriteString(provider.String())
+	}
+	return b.String()
+}
+
+// GoString evita que %#v imprima los secretos
+func (cfg *Config) GoString() string {
+	return cfg.String()
+}
+
+// flagName convierte la clave de una opción en el nombre de su flag
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// parser convierte los valores crudos y acumula los errores de formato
+type parser struct {
+	values   map[string]string
+	problems []string
+}
+
+func (p *parser) str(key string) string {
+	return strings.TrimSpace(p.values[key])
+}
+
+func (p *parser) int(key string) int {
+	value := p.str(key)
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		p.problems = append(p.problems, fmt.Sprintf("%s: %q no es un número entero", key, value))
+	}
+	return parsed
+}
+
+func (p *parser) bool(key string) bool {
+	value := p.str(key)
+	parsed, err := strconv.ParseBool(value)
 	if err != nil {
-		log.Fatalf("Error al cargar el archivo .env: %v", err)
+		p.problems = append(p.problems, fmt.Sprintf("%s: %q no es un valor booleano", key, value))
 	}
+	return parsed
+}
+
+// duration acepta valores como "15m" o "24h"
+func (p *parser) duration(key string) time.Duration {
+	value := p.str(key)
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		p.problems = append(p.problems, fmt.Sprintf("%s: %q no es una duración válida", key, value))
+	}
+	return parsed
+}
 
-	return &Config{
-		DBUser:     os.Getenv("DB_USER"),
-		DBPassword: os.Getenv("DB_PASSWORD"),
-		DBHost:     os.Getenv("DB_HOST"),
-		DBPort:     os.Getenv("DB_PORT"),
-		DBName:     os.Getenv("DB_NAME"),
+// list lee una lista separada por comas
+func (p *parser) list(key string) []string {
+	var items []string
+	for _, item := range strings.Split(p.values[key], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
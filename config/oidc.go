@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const oidcKeyPrefix = "OIDC_"
+
+// OIDCProviderConfig son los datos de un proveedor OpenID Connect (Google,
+// Microsoft, un IdP institucional...) registrados para esta aplicación
+type OIDCProviderConfig struct {
+	Name         string // Nombre usado en las rutas, por ejemplo "google"
+	Issuer       string // URL del emisor; de ahí se lee /.well-known/openid-configuration
+	ClientID     string
+	ClientSecret string   // Vacío para clientes públicos (solo PKCE)
+	Scopes       []string // Por defecto openid, email y profile
+	TrustEmail   bool     // Tratar el correo como verificado aunque falte el claim email_verified
+}
+
+// String imprime el proveedor con el secreto oculto
+func (p OIDCProviderConfig) String() string {
+	prefix := providerPrefix(p.Name)
+	secret := ""
+	if p.ClientSecret != "" {
+		secret = "[REDACTED]"
+	}
+	return fmt.Sprintf("%sISSUER=%s\n%sCLIENT_ID=%s\n%sCLIENT_SECRET=%s\n%sSCOPES=%s\n%sTRUST_EMAIL=%t\n",
+		prefix, p.Issuer, prefix, p.ClientID, prefix, secret, prefix, strings.Join(p.Scopes, ","), prefix, p.TrustEmail)
+}
+
+// providerPrefix arma el prefijo de las variables de un proveedor (google -> OIDC_GOOGLE_)
+func providerPrefix(name string) string {
+	return oidcKeyPrefix + strings.ToUpper(name) + "_"
+}
+
+// isProviderKey indica si la clave pertenece a la configuración de algún proveedor
+func isProviderKey(key string) bool {
+	return strings.HasPrefix(key, oidcKeyPrefix)
+}
+
+func validProviderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// providers lee la configuración de cada proveedor habilitado
+func (p *parser) providers(names []string) []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range names {
+		name = strings.ToLower(name)
+		prefix := providerPrefix(name)
+		provider := OIDCProviderConfig{
+			Name:         name,
+			Issuer:       strings.TrimSuffix(p.str(prefix+"ISSUER"), "/"),
+			ClientID:     p.str(prefix + "CLIENT_ID"),
+			ClientSecret: p.str(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(strings.ReplaceAll(p.str(prefix+"SCOPES"), ",", " ")),
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{"openid", "email", "profile"}
+		}
+		if p.str(prefix+"TRUST_EMAIL") != "" {
+			provider.TrustEmail = p.bool(prefix + "TRUST_EMAIL")
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
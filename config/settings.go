@@ -0,0 +1,100 @@
+package config
+
+// setting describe una opción de configuración: la variable de entorno (y
+// clave del archivo) que la define, su valor por defecto y si es un secreto que
+// no debe aparecer en los logs. Cada opción también se puede pasar como flag,
+// con el nombre en minúsculas y guiones (DB_HOST -> -db-host).
+type setting struct {
+	key    string
+	def    string
+	usage  string
+	secret bool
+}
+
+var settings = []setting{
+	// Base de datos
+	{key: "DB_USER", usage: "usuario de MySQL"},
+	{key: "DB_PASSWORD", usage: "contraseña de MySQL", secret: true},
+	{key: "DB_HOST", usage: "host de MySQL"},
+	{key: "DB_PORT", def: "3306", usage: "puerto de MySQL"},
+	{key: "DB_NAME", usage: "nombre de la base de datos"},
+	{key: "DB_MAX_OPEN_CONNS", def: "25", usage: "máximo de conexiones abiertas (0 = sin límite)"},
+	{key: "DB_MAX_IDLE_CONNS", def: "5", usage: "máximo de conexiones inactivas en el pool"},
+	{key: "DB_CONN_MAX_LIFETIME", def: "5m", usage: "tiempo máximo de vida de una conexión"},
+
+	// Servidor HTTP
+	{key: "LISTEN_ADDR", def: ":8080", usage: "dirección en la que escucha el servidor"},
+	{key: "HTTP_READ_TIMEOUT", def: "15s", usage: "tiempo máximo para leer la solicitud completa"},
+	{key: "HTTP_READ_HEADER_TIMEOUT", def: "5s", usage: "tiempo máximo para leer las cabeceras"},
+	{key: "HTTP_WRITE_TIMEOUT", def: "30s", usage: "tiempo máximo para escribir la respuesta"},
+	{key: "HTTP_IDLE_TIMEOUT", def: "60s", usage: "tiempo máximo de una conexión keep-alive inactiva"},
+	{key: "TRUST_PROXY_HEADERS", def: "false", usage: "tomar la IP del cliente de X-Forwarded-For"},
+
+	// CORS
+	{key: "CORS_ALLOWED_ORIGINS", def: "http://localhost:3000", usage: "orígenes permitidos, separados por comas (* no admite credenciales)"},
+	{key: "CORS_ALLOW_CREDENTIALS", def: "true", usage: "permitir cookies y cabeceras de autenticación"},
+
+	// Imágenes
+	{key: "MEDIA_BACKEND", def: "cloudinary", usage: "almacenamiento de imágenes: cloudinary o none"},
+	{key: "CLOUDINARY_CLOUD_NAME", usage: "nombre de la cuenta de Cloudinary"},
+	{key: "CLOUDINARY_API_KEY", usage: "API key de Cloudinary"},
+	{key: "CLOUDINARY_API_SECRET", usage: "API secret de Cloudinary", secret: true},
+
+	// Búsqueda
+	{key: "FAIR_SEARCH_BACKEND", def: "fulltext", usage: "búsqueda de ferias: fulltext (índice de MySQL) o scan (sin índice, para otras bases de datos)"},
+
+	// Contraseñas y tokens
+	{key: "BCRYPT_COST", def: "12", usage: "costo de bcrypt para los hashes de contraseñas"},
+	{key: "PASSWORD_MIN_LENGTH", def: "10", usage: "mínimo de caracteres de las contraseñas"},
+	{key: "PASSWORD_MIN_ENTROPY", def: "45", usage: "mínimo de bits de entropía estimada de las contraseñas"},
+	{key: "BREACHED_PASSWORDS_FILE", usage: "archivo con los SHA-1 de contraseñas filtradas (vacío = no se revisa)"},
+	{key: "JWT_ALGORITHM", def: "HS256", usage: "algoritmo de firma: HS256 o RS256"},
+	{key: "JWT_KEYS", usage: "llaves kid:secreto (HS256) o kid:ruta.pem (RS256), separadas por comas", secret: true},
+	{key: "JWT_TEMPORARY_KEY", def: "false", usage: "solo desarrollo: sin JWT_KEYS, firmar con una llave aleatoria que se pierde al reiniciar"},
+	{key: "JWT_ACTIVE_KID", usage: "kid con el que se firman los tokens nuevos"},
+	{key: "JWT_ISSUER", def: "netproject", usage: "valor del claim iss"},
+	{key: "JWT_ACCESS_TTL", def: "15m", usage: "vigencia de los tokens de acceso"},
+	{key: "REFRESH_TOKEN_TTL", def: "720h", usage: "vigencia de los tokens de actualización"},
+
+	// Correo
+	{key: "APP_BASE_URL", def: "http://localhost:3000", usage: "URL pública del frontend para los enlaces de los correos"},
+	{key: "MAIL_BACKEND", def: "log", usage: "envío de correos: log o smtp"},
+	{key: "MAIL_FROM", def: "no-reply@netproject.local", usage: "remitente de los correos"},
+	{key: "MAIL_LOG_PATH", usage: "archivo donde escribe el backend log (vacío = log del servidor)"},
+	{key: "SMTP_HOST", usage: "servidor SMTP"},
+	{key: "SMTP_PORT", def: "587", usage: "puerto SMTP"},
+	{key: "SMTP_USER", usage: "usuario SMTP"},
+	{key: "SMTP_PASSWORD", usage: "contraseña SMTP", secret: true},
+
+	// Cuentas
+	{key: "PASSWORD_RESET_TTL", def: "1h", usage: "vigencia de los enlaces para restablecer la contraseña"},
+	{key: "PASSWORD_RESET_RESEND_WAIT", def: "1m", usage: "tiempo mínimo entre dos enlaces para restablecer la contraseña"},
+	{key: "EMAIL_VERIFICATION_TTL", def: "48h", usage: "vigencia de los enlaces de verificación de correo"},
+	{key: "EMAIL_VERIFICATION_RESEND_WAIT", def: "5m", usage: "tiempo mínimo entre reenvíos de la verificación"},
+	{key: "UNVERIFIED_RESTRICTIONS", def: "fairs:create", usage: "acciones vetadas sin correo verificado, separadas por comas"},
+	{key: "LOGIN_MAX_ATTEMPTS", def: "5", usage: "intentos fallidos por cuenta antes del bloqueo"},
+	{key: "LOGIN_MAX_ATTEMPTS_IP", def: "50", usage: "intentos fallidos por IP antes del bloqueo"},
+	{key: "LOGIN_ATTEMPT_WINDOW", def: "15m", usage: "ventana en la que se cuentan los intentos fallidos"},
+	{key: "LOGIN_LOCKOUT", def: "1m", usage: "bloqueo inicial; se duplica con cada fallo adicional"},
+	{key: "LOGIN_MAX_LOCKOUT", def: "1h", usage: "bloqueo máximo"},
+	{key: "NEW_DEVICE_ALERTS", def: "true", usage: "avisar por correo de los inicios de sesión desde un dispositivo nuevo"},
+	{key: "MAGIC_LINK_TTL", def: "15m", usage: "vigencia de los enlaces de inicio de sesión sin contraseña"},
+	{key: "MAGIC_LINK_RESEND_WAIT", def: "1m", usage: "tiempo mínimo entre dos enlaces de inicio de sesión"},
+	{key: "COOKIE_SECURE", def: "false", usage: "enviar las cookies solo por HTTPS (obligatorio en producción para el modo de sesión con cookies)"},
+	{key: "MFA_ISSUER", def: "NetProject", usage: "nombre que muestran las aplicaciones autenticadoras"},
+	{key: "MFA_CHALLENGE_TTL", def: "5m", usage: "tiempo para enviar el código TOTP tras la contraseña"},
+	{key: "IMPERSONATION_TTL", def: "15m", usage: "vigencia de los tokens con los que un administrador suplanta a un usuario"},
+
+	// Passkeys (WebAuthn)
+	{key: "WEBAUTHN_RP_ID", def: "localhost", usage: "dominio de la aplicación al que quedan ligadas las passkeys"},
+	{key: "WEBAUTHN_RP_NAME", def: "NetProject", usage: "nombre que muestra el navegador al crear una passkey"},
+	{key: "WEBAUTHN_ORIGINS", def: "http://localhost:3000", usage: "orígenes del frontend permitidos, separados por comas"},
+	{key: "WEBAUTHN_TIMEOUT", def: "5m", usage: "tiempo para completar el registro o el inicio de sesión con passkey"},
+
+	// Inicio de sesión con OpenID Connect. Cada proveedor de OIDC_PROVIDERS se
+	// configura con OIDC_<NOMBRE>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _SCOPES y
+	// _TRUST_EMAIL (solo desde el archivo o el entorno)
+	{key: "OIDC_PROVIDERS", usage: "proveedores OpenID Connect habilitados, separados por comas"},
+	{key: "OIDC_REDIRECT_URL", def: "http://localhost:3000/auth/oidc/callback", usage: "redirect_uri registrada en los proveedores"},
+	{key: "OIDC_STATE_TTL", def: "10m", usage: "tiempo para volver del proveedor con el código"},
+}
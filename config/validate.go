@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValidationError reúne todos los problemas encontrados en la configuración
+// para poder reportarlos de una sola vez al arrancar
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// validate revisa las reglas que dependen de más de una opción o de rangos
+func (cfg *Config) validate() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	for key, value := range map[string]string{
+		"DB_USER": cfg.DBUser,
+		"DB_HOST": cfg.DBHost,
+		"DB_PORT": cfg.DBPort,
+		"DB_NAME": cfg.DBName,
+	} {
+		if value == "" {
+			add("%s es obligatorio", key)
+		}
+	}
+
+	if cfg.BcryptCost < 4 || cfg.BcryptCost > 31 {
+		add("BCRYPT_COST debe estar entre 4 y 31")
+	}
+	// bcrypt solo usa los primeros 72 bytes
+	if cfg.PasswordMinLength < 1 || cfg.PasswordMinLength > 72 {
+		add("PASSWORD_MIN_LENGTH debe estar entre 1 y 72")
+	}
+	if cfg.PasswordMinEntropy < 0 {
+		add("PASSWORD_MIN_ENTROPY no puede ser negativo")
+	}
+	if cfg.DBMaxOpenConns < 0 || cfg.DBMaxIdleConns < 0 {
+		add("DB_MAX_OPEN_CONNS y DB_MAX_IDLE_CONNS no pueden ser negativos")
+	}
+	// Con credenciales el navegador exige un origen explícito; aceptar "*" haría
+	// que cualquier sitio pudiera leer respuestas autenticadas con las cookies
+	if cfg.CORSAllowCredentials {
+		for _, origin := range cfg.CORSAllowedOrigins {
+			if origin == "*" {
+				add("CORS_ALLOWED_ORIGINS no puede incluir * si CORS_ALLOW_CREDENTIALS está activo; lista los orígenes del frontend")
+				break
+			}
+		}
+	}
+	if cfg.ListenAddr == "" {
+		add("LISTEN_ADDR es obligatorio")
+	}
+
+	switch cfg.MediaBackend {
+	case "cloudinary":
+		if cfg.CloudinaryCloudName == "" || cfg.CloudinaryAPIKey == "" || cfg.CloudinaryAPISecret == "" {
+			add("MEDIA_BACKEND=cloudinary requiere CLOUDINARY_CLOUD_NAME, CLOUDINARY_API_KEY y CLOUDINARY_API_SECRET")
+		}
+	case "none":
+	default:
+		add("MEDIA_BACKEND debe ser cloudinary o none")
+	}
+
+	switch cfg.FairSearchBackend {
+	case "fulltext", "scan":
+	default:
+		add("FAIR_SEARCH_BACKEND debe ser fulltext o scan")
+	}
+
+	switch cfg.JWTAlgorithm {
+	case "HS256":
+		// Una llave aleatoria invalida los tokens al reiniciar y no sirve con
+		// varias instancias, así que solo se usa si se pide explícitamente
+		if cfg.JWTKeys == "" && !cfg.JWTTemporaryKey {
+			add("JWT_KEYS es obligatorio; para desarrollo se puede activar JWT_TEMPORARY_KEY")
+		}
+	case "RS256":
+		if cfg.JWTKeys == "" {
+			add("JWT_ALGORITHM=RS256 requiere JWT_KEYS")
+		}
+	default:
+		add("JWT_ALGORITHM debe ser HS256 o RS256")
+	}
+
+	switch cfg.MailBackend {
+	case "log":
+	case "smtp":
+		if cfg.SMTPHost == "" || cfg.MailFrom == "" {
+			add("MAIL_BACKEND=smtp requiere SMTP_HOST y MAIL_FROM")
+		}
+	default:
+		add("MAIL_BACKEND debe ser log o smtp")
+	}
+
+	if cfg.LoginMaxAttempts <= 0 || cfg.LoginMaxAttemptsIP <= 0 {
+		add("LOGIN_MAX_ATTEMPTS y LOGIN_MAX_ATTEMPTS_IP deben ser mayores que cero")
+	}
+	if cfg.LoginMaxLockout < cfg.LoginLockout {
+		add("LOGIN_MAX_LOCKOUT no puede ser menor que LOGIN_LOCKOUT")
+	}
+
+	if cfg.WebAuthnRPID == "" || len(cfg.WebAuthnOrigins) == 0 {
+		add("WEBAUTHN_RP_ID y WEBAUTHN_ORIGINS son obligatorios")
+	}
+
+	if len(cfg.OIDCProviders) > 0 && cfg.OIDCRedirectURL == "" {
+		add("OIDC_REDIRECT_URL es obligatorio si hay proveedores OpenID Connect")
+	}
+	for _, provider := range cfg.OIDCProviders {
+		prefix := providerPrefix(provider.Name)
+		if !validProviderName(provider.Name) {
+			add("OIDC_PROVIDERS: %q solo puede contener letras minúsculas, números y guiones bajos", provider.Name)
+		}
+		if !strings.HasPrefix(provider.Issuer, "https://") && !strings.HasPrefix(provider.Issuer, "http://") {
+			add("%sISSUER debe ser una URL http(s)", prefix)
+		}
+		if provider.ClientID == "" {
+			add("%sCLIENT_ID es obligatorio", prefix)
+		}
+	}
+
+	for key, value := range map[string]time.Duration{
+		"JWT_ACCESS_TTL":           cfg.JWTAccessTTL,
+		"REFRESH_TOKEN_TTL":        cfg.RefreshTTL,
+		"PASSWORD_RESET_TTL":       cfg.PasswordResetTTL,
+		"EMAIL_VERIFICATION_TTL":   cfg.EmailVerificationTTL,
+		"LOGIN_ATTEMPT_WINDOW":     cfg.LoginWindow,
+		"LOGIN_LOCKOUT":            cfg.LoginLockout,
+		"MFA_CHALLENGE_TTL":        cfg.MFAChallengeTTL,
+		"IMPERSONATION_TTL":        cfg.ImpersonationTTL,
+		"MAGIC_LINK_TTL":           cfg.MagicLinkTTL,
+		"WEBAUTHN_TIMEOUT":         cfg.WebAuthnTimeout,
+		"OIDC_STATE_TTL":           cfg.OIDCStateTTL,
+		"HTTP_READ_TIMEOUT":        cfg.HTTPReadTimeout,
+		"HTTP_READ_HEADER_TIMEOUT": cfg.HTTPReadHeaderTimeout,
+		"HTTP_WRITE_TIMEOUT":       cfg.HTTPWriteTimeout,
+		"HTTP_IDLE_TIMEOUT":        cfg.HTTPIdleTimeout,
+	} {
+		if value <= 0 {
+			add("%s debe ser una duración positiva", key)
+		}
+	}
+
+	// Los mapas no tienen orden; se ordena para que el reporte sea estable
+	sort.Strings(problems)
+	return problems
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"dbconnection/auth"
+	"dbconnection/models"
+	"dbconnection/services"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type AdminController struct {
+	UserService   *services.UserService
+	LoginThrottle *services.LoginThrottleService
+	Audit         *services.AuditService
+	Impersonation *services.ImpersonationService
+}
+
+// GrantRole - Endpoint para asignar un rol a un usuario
+func (c *AdminController) GrantRole(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Rol string `json:"rol"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	c.setRole(w, r, id, body.Rol)
+}
+
+// RevokeRole - Endpoint para quitar el rol de un usuario; vuelve a ser visitante
+func (c *AdminController) RevokeRole(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	c.setRole(w, r, id, auth.RoleVisitor)
+}
+
+func (c *AdminController) setRole(w http.ResponseWriter, r *http.Request, id int, role string) {
+	user, err := c.UserService.SetRole(r.Context(), id, role)
+	if err != nil {
+		log.Printf("Error al cambiar el rol del usuario %d: %v", id, err)
+		writeServiceError(w, err, "Error updating user role")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// UnlockUser - Endpoint para quitar el bloqueo por intentos fallidos de la cuenta de un usuario
+func (c *AdminController) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.LoginThrottle.UnlockUser(r.Context(), id); err != nil {
+		log.Printf("Error al desbloquear al usuario %d: %v", id, err)
+		writeServiceError(w, err, "Error unlocking user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AuditLog - Endpoint para consultar el registro de auditoría. Filtros opcionales:
+// actor, impersonator, entity, entity_id, from y to (RFC 3339), before (ID del último registro
+// de la página anterior) y limit
+func (c *AdminController) AuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{Entidad: query.Get("entity")}
+
+	var err error
+	ints := map[string]*int{"actor": &filter.ActorID, "impersonator": &filter.ImpersonatorID, "entity_id": &filter.EntityID, "limit": &filter.Limit}
+	for name, target := range ints {
+		if value := query.Get(name); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if value := query.Get("before"); value != "" {
+		if filter.BeforeID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			http.Error(w, "Invalid before", http.StatusBadRequest)
+			return
+		}
+	}
+	times := map[string]*time.Time{"from": &filter.Desde, "to": &filter.Hasta}
+	for name, target := range times {
+		if value := query.Get(name); value != "" {
+			if *target, err = time.Parse(time.RFC3339, value); err != nil {
+				http.Error(w, "Invalid "+name+", expected RFC 3339", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	entries, err := c.Audit.ListEntries(r.Context(), filter)
+	if err != nil {
+		log.Printf("Error al consultar la auditoría: %v", err)
+		writeServiceError(w, err, "Error querying audit log")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// ImpersonateUser - Endpoint para obtener un token con el que ver la aplicación
+// como el usuario; el cuerpo lleva el motivo, que queda en la auditoría
+func (c *AdminController) ImpersonateUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Motivo string `json:"motivo"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	impersonation, err := c.Impersonation.Start(r.Context(), id, body.Motivo)
+	if err != nil {
+		log.Printf("Error al suplantar al usuario %d: %v", id, err)
+		writeServiceError(w, err, "Error impersonating user")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(impersonation)
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"dbconnection/services"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type APIKeyController struct {
+	APIKeyService *services.APIKeyService
+}
+
+// CreateKey - Endpoint para crear una API key; la llave completa solo aparece en esta respuesta
+func (c *APIKeyController) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Nombre string   `json:"nombre"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	key, err := c.APIKeyService.CreateKey(r.Context(), body.Nombre, body.Scopes)
+	if err != nil {
+		log.Printf("Error al crear la API key: %v", err)
+		writeServiceError(w, err, "Error creating API key")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+// ListKeys - Endpoint para listar las API keys del usuario; un administrador
+// puede pedir las de otro usuario con ?id_usuario=
+func (c *APIKeyController) ListKeys(w http.ResponseWriter, r *http.Request) {
+	userID := 0
+	if value := r.URL.Query().Get("id_usuario"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		userID = id
+	}
+
+	keys, err := c.APIKeyService.ListKeys(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error al listar las API keys: %v", err)
+		writeServiceError(w, err, "Error listing API keys")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeKey - Endpoint para revocar una API key
+func (c *APIKeyController) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.APIKeyService.RevokeKey(r.Context(), id); err != nil {
+		log.Printf("Error al revocar la API key %d: %v", id, err)
+		writeServiceError(w, err, "Error revoking API key")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
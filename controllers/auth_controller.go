@@ -0,0 +1,279 @@
+package controllers
+
+import (
+	"dbconnection/auth"
+	"dbconnection/services"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+)
+
+type AuthController struct {
+	Tokens               *auth.TokenManager
+	TokenService         *services.TokenService
+	PasswordResetService *services.PasswordResetService
+	EmailVerification    *services.EmailVerificationService
+	MagicLink            *services.MagicLinkService
+	CookieSecure         bool // Marcar las cookies como Secure (solo HTTPS)
+	TrustProxyHeaders    bool
+	Cookies              *SessionCookies // Modo de sesión con cookies (?mode=cookie)
+}
+
+// magicLinkCookie guarda el valor que ata el enlace al navegador que lo pidió
+const magicLinkCookie = "magic_link_browser"
+
+// JWKS - Publica las llaves públicas para que otros servicios validen nuestros tokens
+func (c *AuthController) JWKS(w http.ResponseWriter, r *http.Request) {
+	keys, ok := c.Tokens.JWKS()
+	if !ok {
+		http.Error(w, "JWKS not available for symmetric signing keys", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+// Refresh - Endpoint para rotar el token de actualización y obtener un nuevo token de acceso.
+// En el modo con cookies el token se toma de la cookie y se exige el token CSRF.
+func (c *AuthController) Refresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	fromCookie := false
+	if body.RefreshToken == "" {
+		token, ok := refreshTokenFromCookie(r)
+		if !ok {
+			http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+			return
+		}
+		body.RefreshToken, fromCookie = token, token != ""
+	}
+	if body.RefreshToken == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := c.TokenService.Refresh(body.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			if fromCookie {
+				c.Cookies.clear(w)
+			}
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		log.Printf("Error al rotar el token de actualización: %v", err)
+		http.Error(w, "Error refreshing token", http.StatusInternalServerError)
+		return
+	}
+
+	c.Cookies.writeLoginResponse(w, &services.LoginResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	}, fromCookie || cookieModeRequested(r))
+}
+
+// Logout - Endpoint para cerrar la sesión actual; revoca el token de acceso y su
+// familia de tokens de actualización y borra las cookies del modo con cookies
+func (c *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// El cuerpo es opcional: permite indicar además un token de actualización
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if body.RefreshToken == "" {
+		if token, ok := refreshTokenFromCookie(r); ok {
+			body.RefreshToken = token
+		}
+	}
+
+	if err := c.TokenService.Logout(r.Context(), body.RefreshToken); err != nil {
+		log.Printf("Error al cerrar la sesión: %v", err)
+		writeServiceError(w, err, "Error during logout")
+		return
+	}
+
+	c.Cookies.clear(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ForgotPassword - Endpoint para solicitar el enlace de restablecimiento de contraseña.
+// Siempre responde 202 para no revelar si el correo está registrado.
+func (c *AuthController) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Email == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	c.PasswordResetService.ForgotPassword(body.Email)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword - Endpoint para elegir una nueva contraseña con el token recibido por correo
+func (c *AuthController) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Token    string `json:"token"`
+		Password string `json:"contraseña"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Token == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := c.PasswordResetService.ResetPassword(r.Context(), body.Token, body.Password)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidResetToken) || errors.Is(err, services.ErrEmptyPassword) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error al restablecer la contraseña: %v", err)
+		writeServiceError(w, err, "Error resetting password")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail - Endpoint del enlace de verificación de correo (?token=...)
+func (c *AuthController) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "Missing token", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.EmailVerification.ConfirmEmail(token); err != nil {
+		log.Printf("Error al verificar el correo: %v", err)
+		writeServiceError(w, err, "Error verifying email")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerification - Endpoint para reenviar el correo de verificación al usuario autenticado
+func (c *AuthController) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := c.EmailVerification.ResendVerification(r.Context()); err != nil {
+		log.Printf("Error al reenviar la verificación de correo: %v", err)
+		writeServiceError(w, err, "Error sending verification email")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// RequestMagicLink - Endpoint para pedir un enlace de inicio de sesión sin contraseña.
+// Siempre responde 202 para no revelar si el correo está registrado.
+func (c *AuthController) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Email == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	binding, err := c.MagicLink.RequestLink(body.Email)
+	if err != nil {
+		log.Printf("Error al procesar la solicitud de enlace de inicio de sesión: %v", err)
+		http.Error(w, "Error sending sign-in link", http.StatusInternalServerError)
+		return
+	}
+
+	// El enlace solo se puede canjear desde el navegador que guarda esta cookie
+	http.SetCookie(w, &http.Cookie{
+		Name:     magicLinkCookie,
+		Value:    binding,
+		Path:     "/api/auth/magic-link",
+		MaxAge:   int(c.MagicLink.TTL.Seconds()),
+		HttpOnly: true,
+		Secure:   c.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// MagicLinkLogin - Endpoint para canjear el enlace recibido por correo; responde igual que /api/login
+func (c *AuthController) MagicLinkLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Token == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var binding string
+	if cookie, err := r.Cookie(magicLinkCookie); err == nil {
+		binding = cookie.Value
+	}
+
+	loginResponse, err := c.MagicLink.Login(r.Context(), body.Token, binding, clientInfo(r, c.TrustProxyHeaders))
+	if err != nil {
+		log.Printf("Error al iniciar sesión con el enlace: %v", err)
+		writeServiceError(w, err, "Error during login")
+		return
+	}
+
+	// El enlace ya se usó; la cookie deja de servir
+	http.SetCookie(w, &http.Cookie{
+		Name:     magicLinkCookie,
+		Path:     "/api/auth/magic-link",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   c.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	c.Cookies.writeLoginResponse(w, loginResponse, cookieModeRequested(r))
+}
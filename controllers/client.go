@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"dbconnection/services"
+	"dbconnection/utils"
+	"net/http"
+)
+
+// clientInfo reúne la IP y el user agent con los que se registra un inicio de sesión
+func clientInfo(r *http.Request, trustProxy bool) services.ClientInfo {
+	return services.ClientInfo{
+		IP:        utils.ClientIP(r, trustProxy),
+		UserAgent: r.UserAgent(),
+	}
+}
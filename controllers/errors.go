@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"dbconnection/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// writeServiceError traduce los errores conocidos de los servicios a su código
+// HTTP y usa el mensaje genérico para cualquier otro error.
+func writeServiceError(w http.ResponseWriter, err error, fallback string) {
+	var throttled *services.ThrottledError
+	var invalid *services.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		// Los errores de validación van por campo para mostrarlos en el formulario
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": invalid.Error(),
+			"errors":  invalid.Fields,
+		})
+	case errors.As(err, &throttled):
+		w.Header().Set("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, services.ErrUnauthorized), errors.Is(err, services.ErrInvalidCredentials),
+		errors.Is(err, services.ErrOIDCLoginFailed), errors.Is(err, services.ErrInvalidMagicLink),
+		errors.Is(err, services.ErrWebAuthnFailed), errors.Is(err, services.ErrInvalidAPIKey):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, services.ErrForbidden), errors.Is(err, services.ErrOIDCEmailNotVerified):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrNotFound), errors.Is(err, services.ErrUnknownProvider):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidRole), errors.Is(err, services.ErrInvalidVerificationToken),
+		errors.Is(err, services.ErrInvalidMFACode), errors.Is(err, services.ErrInvalidOIDCState),
+		errors.Is(err, services.ErrInvalidWebAuthnSession), errors.Is(err, services.ErrInvalidAPIKeyRequest),
+		errors.Is(err, services.ErrImpersonationReasonRequired), errors.Is(err, services.ErrInvalidTicket):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrAlreadyVerified), errors.Is(err, services.ErrMFAAlreadyEnabled),
+		errors.Is(err, services.ErrMFANotEnrolled), errors.Is(err, services.ErrRegistrationClosed),
+		errors.Is(err, services.ErrTicketUnavailable), errors.Is(err, services.ErrTicketCancelled),
+		errors.Is(err, services.ErrAlreadyCheckedIn):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
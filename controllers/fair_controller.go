@@ -2,12 +2,17 @@ package controllers
 
 import (
 	"database/sql"
+	"dbconnection/auth"
 	"dbconnection/models"
 	"dbconnection/services"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
@@ -30,10 +35,10 @@ func (c *FairController) DeleteFair(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Llamar al servicio para eliminar la feria
-	err = c.FairService.DeleteFair(id)
+	err = c.FairService.DeleteFair(r.Context(), id)
 	if err != nil {
 		log.Printf("Error al eliminar la feria: %v", err)
-		http.Error(w, "Error deleting fair", http.StatusInternalServerError)
+		writeServiceError(w, err, "Error deleting fair")
 		return
 	}
 
@@ -53,18 +58,8 @@ func (c *FairController) UpdateFair(w http.ResponseWriter, r *http.Request) {
 	fair := &models.Fair{
 		Titulo:      r.FormValue("titulo"),
 		Descripcion: r.FormValue("descripcion"),
-		FechaInicio: r.FormValue("fecha_inicio"),
 	}
-
-	// Convertir el id_usuario (string) a int
-	idUsuarioStr := r.FormValue("id_usuario")
-	idUsuario, err := strconv.Atoi(idUsuarioStr)
-	if err != nil {
-		log.Printf("Error al convertir el id_usuario: %v", err)
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
-		return
-	}
-	fair.IdUsuario = idUsuario
+	form := fairFormFromRequest(r)
 
 	// Obtener el ID de la feria de la URL
 	idStr := mux.Vars(r)["id"]
@@ -74,6 +69,12 @@ func (c *FairController) UpdateFair(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Verificar los permisos antes de subir la foto, para no sobrescribir la de otra feria
+	if err := c.FairService.CheckOwnership(r.Context(), id); err != nil {
+		writeServiceError(w, err, "Error updating fair")
+		return
+	}
+
 	// Obtener la foto de la feria si está presente
 	file, _, err := r.FormFile("foto_feria")
 	if err != nil && err != http.ErrMissingFile {
@@ -83,6 +84,11 @@ func (c *FairController) UpdateFair(w http.ResponseWriter, r *http.Request) {
 
 	// Subir la foto a Cloudinary
 	if file != nil {
+		if c.Cloudinary == nil {
+			http.Error(w, "Image uploads are disabled", http.StatusServiceUnavailable)
+			return
+		}
+
 		// Crear un nombre único para la foto usando el ID de la feria
 		publicID := "fair_picture_" + strconv.Itoa(id)
 
@@ -109,10 +115,10 @@ func (c *FairController) UpdateFair(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Llamar al servicio para actualizar la feria
-	updatedFair, err := c.FairService.UpdateFair(id, fair) // Llamamos al servicio de actualización
+	updatedFair, err := c.FairService.UpdateFair(r.Context(), id, fair, form) // Llamamos al servicio de actualización
 	if err != nil {
 		log.Printf("Error al actualizar la feria: %v", err)
-		http.Error(w, "Error updating fair", http.StatusInternalServerError)
+		writeServiceError(w, err, "Error updating fair")
 		return
 	}
 
@@ -133,18 +139,13 @@ func (c *FairController) CreateFair(w http.ResponseWriter, r *http.Request) {
 	fair := &models.Fair{
 		Titulo:      r.FormValue("titulo"),
 		Descripcion: r.FormValue("descripcion"),
-		FechaInicio: r.FormValue("fecha_inicio"),
 	}
+	form := fairFormFromRequest(r)
 
-	// Convertir el id_usuario (string) a int
-	idUsuarioStr := r.FormValue("id_usuario")
-	idUsuario, err := strconv.Atoi(idUsuarioStr)
-	if err != nil {
-		log.Printf("Error al convertir el id_usuario: %v", err)
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
-		return
+	// La feria pertenece al usuario autenticado
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		fair.IdUsuario = principal.UserID
 	}
-	fair.IdUsuario = idUsuario
 
 	// Obtener la foto de la feria si está presente
 	file, _, err := r.FormFile("foto_feria")
@@ -155,6 +156,11 @@ func (c *FairController) CreateFair(w http.ResponseWriter, r *http.Request) {
 
 	// Subir la foto a Cloudinary
 	if file != nil {
+		if c.Cloudinary == nil {
+			http.Error(w, "Image uploads are disabled", http.StatusServiceUnavailable)
+			return
+		}
+
 		// Crear un nombre único para la foto usando el ID de la feria
 		publicID := "fair_picture_" + strconv.Itoa(fair.IdUsuario)
 
@@ -180,11 +186,12 @@ func (c *FairController) CreateFair(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Crear la feria con los datos del formulario y la URL de la foto de la feria
-	createdFair, err := c.FairService.CreateFair(fair, r) // Pasar el objeto fair y el request r
+	// Crear la feria con los datos del formulario y la URL de la foto de la feria;
+	// el servicio la asigna al usuario autenticado
+	createdFair, err := c.FairService.CreateFair(r.Context(), fair, form)
 	if err != nil {
 		log.Printf("Error al crear la feria: %v", err)
-		http.Error(w, "Error creating fair", http.StatusInternalServerError)
+		writeServiceError(w, err, "Error creating fair")
 		return
 	}
 
@@ -192,6 +199,21 @@ func (c *FairController) CreateFair(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(createdFair)
 }
 
+// fairFormFromRequest lee las fechas y la capacidad del formulario; el
+// servicio las valida
+func fairFormFromRequest(r *http.Request) services.FairForm {
+	form := services.FairForm{
+		Inicio:      r.FormValue("fecha_inicio"),
+		Fin:         r.FormValue("fecha_fin"),
+		ZonaHoraria: r.FormValue("zona_horaria"),
+	}
+	if _, sent := r.Form["capacidad"]; sent {
+		capacity := r.FormValue("capacidad")
+		form.Capacidad = &capacity
+	}
+	return form
+}
+
 func (c *FairController) GetFair(w http.ResponseWriter, r *http.Request) {
 	idStr := r.URL.Query().Get("id")
 	id, err := strconv.Atoi(idStr)
@@ -201,38 +223,113 @@ func (c *FairController) GetFair(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fair, err := c.FairService.GetFairDetails(id)
+	fair, err := c.FairService.GetFairDetails(r.Context(), id)
+	if errors.Is(err, services.ErrForbidden) {
+		writeServiceError(w, err, "Error getting fair")
+		return
+	}
 	if err != nil {
 		log.Printf("Error al obtener la feria con ID %d: %v", id, err)
 		http.Error(w, "Fair not found", http.StatusNotFound)
 		return
 	}
 
-	// Convertir la foto_feria a una URL válida si existe
-	if fair.FotoFeria.Valid {
-		fair.FotoFeria.String = fair.FotoFeria.String // Foto_faire no nula, mostrar URL
-	} else {
-		fair.FotoFeria.String = "" // Si la foto es nula, asignamos vacío
+	// Si la foto es nula, asignamos vacío
+	if !fair.FotoFeria.Valid {
+		fair.FotoFeria.String = ""
 	}
 
 	json.NewEncoder(w).Encode(fair)
 }
 
+// GetAllFairs - Lista las ferias por páginas. Parámetros opcionales:
+//   - status: upcoming, ongoing o past
+//   - from/to: RFC 3339, ferias que se cruzan con ese intervalo
+//   - id_usuario: organizador
+//   - q: texto en el título o la descripción
+//   - sort (start, title o created), order (asc o desc), limit y cursor
+//
+// Responde {items, next_cursor, total}; next_cursor se envía como cursor para
+// pedir la página siguiente y es null en la última.
 func (c *FairController) GetAllFairs(w http.ResponseWriter, r *http.Request) {
-	fairs, err := c.FairService.GetAllFairs()
+	query := r.URL.Query()
+	filter, ok := fairFilterFromQuery(w, query)
+	if !ok {
+		return
+	}
+	filter.Texto = strings.TrimSpace(query.Get("q"))
+	filter.Sort = query.Get("sort")
+	filter.Order = query.Get("order")
+
+	page, err := c.FairService.ListFairs(r.Context(), filter, query.Get("cursor"))
+	var invalid *services.ValidationError
+	if errors.Is(err, services.ErrForbidden) || errors.As(err, &invalid) {
+		writeServiceError(w, err, "Error getting fairs")
+		return
+	}
 	if err != nil {
 		http.Error(w, "Error al obtener las ferias: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Asignar el valor de FotoFeria en el resultado
-	for i, fair := range fairs {
+	for i, fair := range page.Items {
 		if fair.FotoFeria.Valid {
-			fairs[i].FotoFeria.String = fair.FotoFeria.String // Mostrar la URL de la foto
+			page.Items[i].FotoFeria.String = fair.FotoFeria.String // Mostrar la URL de la foto
 		} else {
-			fairs[i].FotoFeria.String = "" // Si es nula, mostrar vacío
+			page.Items[i].FotoFeria.String = "" // Si es nula, mostrar vacío
 		}
 	}
 
-	json.NewEncoder(w).Encode(fairs)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// SearchFairs - Búsqueda de texto en las ferias (?q=), ordenada por relevancia.
+// Admite los filtros status, from, to, id_usuario y limit de GetAllFairs y
+// responde {items, total}; cada resultado trae el título y un fragmento de la
+// descripción con las coincidencias en <mark>.
+func (c *FairController) SearchFairs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter, ok := fairFilterFromQuery(w, query)
+	if !ok {
+		return
+	}
+
+	page, err := c.FairService.SearchFairs(r.Context(), query.Get("q"), filter)
+	if err != nil {
+		log.Printf("Error al buscar ferias: %v", err)
+		writeServiceError(w, err, "Error searching fairs")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// fairFilterFromQuery lee los filtros comunes del listado y la búsqueda;
+// responde 400 y devuelve false si alguno no tiene el formato esperado
+func fairFilterFromQuery(w http.ResponseWriter, query url.Values) (models.FairFilter, bool) {
+	filter := models.FairFilter{Status: query.Get("status")}
+	times := map[string]*time.Time{"from": &filter.Desde, "to": &filter.Hasta}
+	for name, target := range times {
+		if value := query.Get(name); value != "" {
+			var err error
+			if *target, err = time.Parse(time.RFC3339, value); err != nil {
+				http.Error(w, "Invalid "+name+", expected RFC 3339", http.StatusBadRequest)
+				return filter, false
+			}
+		}
+	}
+	numbers := map[string]*int{"id_usuario": &filter.IdUsuario, "limit": &filter.Limit}
+	for name, target := range numbers {
+		if value := query.Get(name); value != "" {
+			var err error
+			if *target, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return filter, false
+			}
+		}
+	}
+	return filter, true
 }
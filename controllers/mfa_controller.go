@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"dbconnection/services"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+type MFAController struct {
+	MFAService        *services.MFAService
+	TrustProxyHeaders bool
+	Cookies           *SessionCookies // Modo de sesión con cookies (?mode=cookie)
+}
+
+type mfaCodeRequest struct {
+	Code     string `json:"code"`
+	MFAToken string `json:"mfa_token"`
+}
+
+// Enroll - Endpoint para iniciar la inscripción de una aplicación autenticadora
+func (c *MFAController) Enroll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	enrollment, err := c.MFAService.Enroll(r.Context())
+	if err != nil {
+		log.Printf("Error al iniciar la inscripción TOTP: %v", err)
+		writeServiceError(w, err, "Error enrolling MFA")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+// Confirm - Endpoint para confirmar la inscripción con el primer código; devuelve los códigos de recuperación
+func (c *MFAController) Confirm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Code == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := c.MFAService.Confirm(r.Context(), body.Code)
+	if err != nil {
+		log.Printf("Error al confirmar la inscripción TOTP: %v", err)
+		writeServiceError(w, err, "Error confirming MFA")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"recovery_codes": codes})
+}
+
+// Disable - Endpoint para desactivar la verificación en dos pasos
+func (c *MFAController) Disable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.MFAService.Disable(r.Context(), body.Code); err != nil {
+		log.Printf("Error al desactivar la verificación en dos pasos: %v", err)
+		writeServiceError(w, err, "Error disabling MFA")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Verify - Segundo paso del login: recibe el token "mfa pendiente" y el código
+func (c *MFAController) Verify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Code == "" || body.MFAToken == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	client := clientInfo(r, c.TrustProxyHeaders)
+	loginResponse, err := c.MFAService.VerifyLogin(body.MFAToken, body.Code, client)
+	if err != nil {
+		log.Printf("Error en el segundo paso del login: %v", err)
+		if errors.Is(err, services.ErrInvalidMFACode) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		writeServiceError(w, err, "Error during login")
+		return
+	}
+
+	c.Cookies.writeLoginResponse(w, loginResponse, cookieModeRequested(r))
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"dbconnection/services"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type OIDCController struct {
+	OIDCService       *services.OIDCService
+	TrustProxyHeaders bool
+	CookieSecure      bool            // Marcar las cookies como Secure (solo HTTPS)
+	Cookies           *SessionCookies // Modo de sesión con cookies (?mode=cookie)
+}
+
+// oidcStateCookie guarda el state en el navegador que inició el login, para que
+// el código del proveedor solo se pueda canjear desde ese mismo navegador
+const (
+	oidcStateCookie     = "oidc_state"
+	oidcStateCookiePath = "/api/auth/oidc"
+)
+
+// Providers - Lista los proveedores disponibles para mostrar los botones de inicio de sesión
+func (c *OIDCController) Providers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"providers": c.OIDCService.ProviderNames()})
+}
+
+// Authorize - Devuelve la URL del proveedor a la que el frontend debe redirigir
+// al usuario y guarda el state en una cookie del navegador
+func (c *OIDCController) Authorize(w http.ResponseWriter, r *http.Request) {
+	authorization, err := c.OIDCService.Begin(r.Context(), mux.Vars(r)["provider"])
+	if err != nil {
+		log.Printf("Error al iniciar el login OIDC: %v", err)
+		writeServiceError(w, err, "Error starting login")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    authorization.State,
+		Path:     oidcStateCookiePath,
+		MaxAge:   int(c.OIDCService.StateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   c.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authorization)
+}
+
+// Callback - Recibe el state y el código que el proveedor entregó al frontend y
+// responde igual que /api/login. El state debe coincidir con el de la cookie que
+// guardó Authorize; si no, otro navegador inició el flujo (CSRF de login).
+func (c *OIDCController) Callback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		State string `json:"state"`
+		Code  string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.State == "" || body.Code == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var browserState string
+	if cookie, err := r.Cookie(oidcStateCookie); err == nil {
+		browserState = cookie.Value
+	}
+
+	loginResponse, err := c.OIDCService.Complete(r.Context(), body.State, browserState, body.Code, clientInfo(r, c.TrustProxyHeaders))
+	if err != nil {
+		log.Printf("Error al completar el login OIDC: %v", err)
+		writeServiceError(w, err, "Error during login")
+		return
+	}
+
+	// El state ya se usó; la cookie deja de servir
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Path:     oidcStateCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   c.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	c.Cookies.writeLoginResponse(w, loginResponse, cookieModeRequested(r))
+}
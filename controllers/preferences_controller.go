@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"dbconnection/auth"
 	"dbconnection/models"
 	"dbconnection/services"
 	"encoding/json"
@@ -15,12 +16,6 @@ type PreferenceController struct {
 
 // CreatePreferences - Endpoint para crear nuevas preferencias de un usuario
 func (controller *PreferenceController) CreatePreferences(w http.ResponseWriter, r *http.Request) {
-	token := r.Header.Get("Authorization")
-	if token == "" {
-		http.Error(w, "Token de autenticación no proporcionado", http.StatusUnauthorized)
-		return
-	}
-
 	var pref models.Preference
 	if err := json.NewDecoder(r.Body).Decode(&pref); err != nil {
 		log.Printf("Error al decodificar el cuerpo de la solicitud: %v", err)
@@ -28,10 +23,10 @@ func (controller *PreferenceController) CreatePreferences(w http.ResponseWriter,
 		return
 	}
 
-	createdPref, err := controller.PreferenceService.CreatePreferences(&pref, token)
+	createdPref, err := controller.PreferenceService.CreatePreferences(r.Context(), &pref)
 	if err != nil {
 		log.Printf("Error al crear las preferencias: %v", err)
-		http.Error(w, "Error creating preferences", http.StatusInternalServerError)
+		writeServiceError(w, err, "Error creating preferences")
 		return
 	}
 
@@ -52,9 +47,7 @@ func (controller *PreferenceController) GetPreferences(w http.ResponseWriter, r
 		return
 	}
 
-	token := r.Header.Get("Authorization")
-
-	preferences, err := controller.PreferenceService.GetPreferencesByUserID(idUsuario, token)
+	preferences, err := controller.PreferenceService.GetPreferencesByUserID(idUsuario)
 	if err != nil {
 		// Si se recibe un error de la tabla inexistente, devolver 204 No Content
 		if err.Error() == "La tabla 'preferencias_usuarios' no existe en la base de datos" {
@@ -77,12 +70,6 @@ func (controller *PreferenceController) GetPreferences(w http.ResponseWriter, r
 
 // UpdatePreferences - Endpoint para actualizar las preferencias del usuario
 func (c *PreferenceController) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
-	token := r.Header.Get("Authorization")
-	if token == "" {
-		http.Error(w, "Token de autenticación no proporcionado", http.StatusUnauthorized)
-		return
-	}
-
 	// Obtener el id_usuario y id_pref
 	var pref models.Preference
 	if err := json.NewDecoder(r.Body).Decode(&pref); err != nil {
@@ -91,8 +78,13 @@ func (c *PreferenceController) UpdatePreferences(w http.ResponseWriter, r *http.
 		return
 	}
 
+	// Si no se indica el usuario, se usan las preferencias del usuario autenticado
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok && pref.IdUsuario == 0 {
+		pref.IdUsuario = principal.UserID
+	}
+
 	// Verificar si ya existe una preferencia para este usuario
-	existingPref, err := c.PreferenceService.GetPreferencesByUserID(pref.IdUsuario, token)
+	existingPref, err := c.PreferenceService.GetPreferencesByUserID(pref.IdUsuario)
 	if err != nil {
 		http.Error(w, "Error fetching preferences", http.StatusInternalServerError)
 		return
@@ -100,9 +92,9 @@ func (c *PreferenceController) UpdatePreferences(w http.ResponseWriter, r *http.
 
 	if existingPref == nil {
 		// Si no existen preferencias, crear nuevas preferencias
-		newPref, err := c.PreferenceService.CreatePreferences(&pref, token)
+		newPref, err := c.PreferenceService.CreatePreferences(r.Context(), &pref)
 		if err != nil {
-			http.Error(w, "Error creating preferences", http.StatusInternalServerError)
+			writeServiceError(w, err, "Error creating preferences")
 			return
 		}
 		w.WriteHeader(http.StatusCreated)
@@ -111,10 +103,10 @@ func (c *PreferenceController) UpdatePreferences(w http.ResponseWriter, r *http.
 	}
 
 	// Si ya existen preferencias, actualizar las preferencias existentes
-	updatedPref, err := c.PreferenceService.UpdatePreferences(&pref, token)
+	updatedPref, err := c.PreferenceService.UpdatePreferences(r.Context(), &pref)
 	if err != nil {
 		log.Printf("Error al actualizar las preferencias: %v", err)
-		http.Error(w, "Error updating preferences", http.StatusInternalServerError)
+		writeServiceError(w, err, "Error updating preferences")
 		return
 	}
 
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"dbconnection/services"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/skip2/go-qrcode"
+)
+
+// ticketQRSize es el tamaño en píxeles del código QR del boleto
+const ticketQRSize = 320
+
+type RegistrationController struct {
+	RegistrationService *services.RegistrationService
+	TicketService       *services.TicketService
+}
+
+// Register - Endpoint para inscribirse en una feria. Responde 201 con la
+// inscripción nueva (confirmada o en lista de espera) o 200 si ya existía.
+func (c *RegistrationController) Register(w http.ResponseWriter, r *http.Request) {
+	fairID, ok := fairIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	registration, created, err := c.RegistrationService.Register(r.Context(), fairID)
+	if err != nil {
+		log.Printf("Error al inscribirse en la feria %d: %v", fairID, err)
+		writeServiceError(w, err, "Error registering for fair")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(registration)
+}
+
+// Unregister - Endpoint para cancelar la inscripción en una feria
+func (c *RegistrationController) Unregister(w http.ResponseWriter, r *http.Request) {
+	fairID, ok := fairIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.RegistrationService.Unregister(r.Context(), fairID); err != nil {
+		log.Printf("Error al cancelar la inscripción en la feria %d: %v", fairID, err)
+		writeServiceError(w, err, "Error cancelling registration")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRegistration - Endpoint para ver la inscripción propia en una feria y el
+// lugar en la lista de espera
+func (c *RegistrationController) GetRegistration(w http.ResponseWriter, r *http.Request) {
+	fairID, ok := fairIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	registration, err := c.RegistrationService.GetRegistration(r.Context(), fairID)
+	if err != nil {
+		writeServiceError(w, err, "Error getting registration")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(registration)
+}
+
+// ListRegistrations - Endpoint para que el organizador vea los inscritos de su
+// feria (?estado=confirmed o waitlist)
+func (c *RegistrationController) ListRegistrations(w http.ResponseWriter, r *http.Request) {
+	fairID, ok := fairIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	summary, err := c.RegistrationService.ListRegistrations(r.Context(), fairID, r.URL.Query().Get("estado"))
+	if err != nil {
+		log.Printf("Error al listar las inscripciones de la feria %d: %v", fairID, err)
+		writeServiceError(w, err, "Error listing registrations")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// GetTicket - Endpoint para obtener el boleto firmado de la inscripción propia
+func (c *RegistrationController) GetTicket(w http.ResponseWriter, r *http.Request) {
+	fairID, ok := fairIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	ticket, err := c.TicketService.GetTicket(r.Context(), fairID)
+	if err != nil {
+		log.Printf("Error al obtener el boleto de la feria %d: %v", fairID, err)
+		writeServiceError(w, err, "Error getting ticket")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ticket)
+}
+
+// GetTicketQR - Endpoint que devuelve el boleto propio como código QR en PNG
+func (c *RegistrationController) GetTicketQR(w http.ResponseWriter, r *http.Request) {
+	fairID, ok := fairIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	ticket, err := c.TicketService.GetTicket(r.Context(), fairID)
+	if err != nil {
+		log.Printf("Error al obtener el boleto de la feria %d: %v", fairID, err)
+		writeServiceError(w, err, "Error getting ticket")
+		return
+	}
+	png, err := qrcode.Encode(ticket.Boleto, qrcode.Medium, ticketQRSize)
+	if err != nil {
+		log.Printf("Error al generar el código QR del boleto %d: %v", ticket.IdInscripcion, err)
+		http.Error(w, "Error generating QR code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(png)
+}
+
+// CheckIn - Endpoint para que el organizador registre la entrada de un boleto
+// leído del código QR. Responde 409 si el boleto ya se usó o fue cancelado.
+func (c *RegistrationController) CheckIn(w http.ResponseWriter, r *http.Request) {
+	fairID, ok := fairIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Boleto string `json:"boleto"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Boleto == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, err := c.TicketService.CheckIn(r.Context(), fairID, request.Boleto)
+	if err != nil {
+		log.Printf("Error al registrar la entrada en la feria %d: %v", fairID, err)
+		writeServiceError(w, err, "Error checking in")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// Attendance - Endpoint para que el organizador vea cuántos confirmados ya entraron
+func (c *RegistrationController) Attendance(w http.ResponseWriter, r *http.Request) {
+	fairID, ok := fairIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	attendance, err := c.TicketService.Attendance(r.Context(), fairID)
+	if err != nil {
+		writeServiceError(w, err, "Error getting attendance")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attendance)
+}
+
+// fairIDFromPath lee el ID de la feria de la URL; responde 400 si no es un número
+func fairIDFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid fair ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"dbconnection/services"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type SessionController struct {
+	SessionService *services.SessionService
+}
+
+// ListSessions - Endpoint para listar las sesiones abiertas del usuario
+func (c *SessionController) ListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := c.SessionService.ListActiveSessions(r.Context())
+	if err != nil {
+		log.Printf("Error al listar las sesiones: %v", err)
+		writeServiceError(w, err, "Error listing sessions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// LoginHistory - Endpoint para ver los últimos inicios de sesión (?limit=, máximo 100)
+func (c *SessionController) LoginHistory(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	history, err := c.SessionService.LoginHistory(r.Context(), limit)
+	if err != nil {
+		log.Printf("Error al obtener el historial de inicios de sesión: %v", err)
+		writeServiceError(w, err, "Error getting login history")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// RevokeSession - Endpoint para cerrar una sesión, por ejemplo la de un dispositivo perdido
+func (c *SessionController) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := c.SessionService.RevokeSession(r.Context(), id); err != nil {
+		log.Printf("Error al revocar la sesión %s: %v", id, err)
+		writeServiceError(w, err, "Error revoking session")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"dbconnection/auth"
+	"dbconnection/services"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// refreshCookiePath limita la cookie del token de actualización a las rutas que la usan
+const refreshCookiePath = "/api/auth"
+
+// SessionCookies implementa el modo de sesión con cookies para las páginas que
+// no pueden guardar los tokens en localStorage. Se pide agregando ?mode=cookie
+// a cualquier endpoint de inicio de sesión.
+type SessionCookies struct {
+	Secure     bool          // Marcar las cookies como Secure (solo HTTPS)
+	RefreshTTL time.Duration // Vigencia de la cookie del token de actualización
+}
+
+// cookieModeRequested indica si el cliente pidió la sesión en cookies
+func cookieModeRequested(r *http.Request) bool {
+	return r.URL.Query().Get("mode") == "cookie"
+}
+
+// writeLoginResponse responde a un inicio de sesión o a una rotación de tokens.
+// En el modo con cookies los tokens se guardan en cookies y no van en el cuerpo.
+func (c *SessionCookies) writeLoginResponse(w http.ResponseWriter, response *services.LoginResponse, cookieMode bool) {
+	if c != nil && cookieMode && response.Token != "" {
+		csrfToken, err := auth.NewCSRFToken()
+		if err != nil {
+			log.Printf("Error al generar el token CSRF: %v", err)
+			http.Error(w, "Error during login", http.StatusInternalServerError)
+			return
+		}
+		c.set(w, response.Token, response.RefreshToken, response.ExpiresIn, csrfToken)
+
+		body := *response
+		body.Token = ""
+		body.RefreshToken = ""
+		body.CSRFToken = csrfToken
+		response = &body
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// set guarda el token de acceso, el de actualización y el token CSRF
+func (c *SessionCookies) set(w http.ResponseWriter, accessToken, refreshToken string, expiresIn int, csrfToken string) {
+	refreshMaxAge := int(c.RefreshTTL.Seconds())
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.SessionCookie,
+		Value:    accessToken,
+		Path:     "/",
+		MaxAge:   expiresIn,
+		HttpOnly: true,
+		Secure:   c.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.RefreshCookie,
+		Value:    refreshToken,
+		Path:     refreshCookiePath,
+		MaxAge:   refreshMaxAge,
+		HttpOnly: true,
+		Secure:   c.Secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+	// La cookie CSRF dura lo mismo que la sesión y JavaScript la puede leer
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.CSRFCookie,
+		Value:    csrfToken,
+		Path:     "/",
+		MaxAge:   refreshMaxAge,
+		Secure:   c.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clear borra las cookies de la sesión al cerrarla
+func (c *SessionCookies) clear(w http.ResponseWriter) {
+	if c == nil {
+		return
+	}
+	for _, cookie := range []struct {
+		name, path string
+		httpOnly   bool
+	}{
+		{auth.SessionCookie, "/", true},
+		{auth.RefreshCookie, refreshCookiePath, true},
+		{auth.CSRFCookie, "/", false},
+	} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookie.name,
+			Path:     cookie.path,
+			MaxAge:   -1,
+			HttpOnly: cookie.httpOnly,
+			Secure:   c.Secure,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+// refreshTokenFromCookie devuelve el token de actualización de la cookie. Como
+// el navegador la envía solo, se exige el token CSRF; ok es false si falta.
+func refreshTokenFromCookie(r *http.Request) (token string, ok bool) {
+	cookie, err := r.Cookie(auth.RefreshCookie)
+	if err != nil || cookie.Value == "" {
+		return "", true
+	}
+	if !auth.ValidCSRF(r) {
+		return "", false
+	}
+	return cookie.Value, true
+}
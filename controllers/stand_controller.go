@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"dbconnection/services"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type StandController struct {
+	StandService *services.StandService
+}
+
+// standRequest es el cuerpo para crear o actualizar un stand
+type standRequest struct {
+	Codigo      string `json:"codigo"`
+	Ubicacion   string `json:"ubicacion"`
+	Expositores []int  `json:"expositores"`
+}
+
+func (req standRequest) form() services.StandForm {
+	return services.StandForm{Codigo: req.Codigo, Ubicacion: req.Ubicacion, Expositores: req.Expositores}
+}
+
+// ListStands - Endpoint para listar los stands de una feria con sus expositores
+func (c *StandController) ListStands(w http.ResponseWriter, r *http.Request) {
+	fairID, ok := fairIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	stands, err := c.StandService.ListStands(r.Context(), fairID)
+	if err != nil {
+		log.Printf("Error al listar los stands de la feria %d: %v", fairID, err)
+		writeServiceError(w, err, "Error listing stands")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stands)
+}
+
+// GetStand - Endpoint para ver un stand de una feria
+func (c *StandController) GetStand(w http.ResponseWriter, r *http.Request) {
+	fairID, standID, ok := standIDsFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	stand, err := c.StandService.GetStand(r.Context(), fairID, standID)
+	if err != nil {
+		writeServiceError(w, err, "Error getting stand")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stand)
+}
+
+// CreateStand - Endpoint para que el organizador cree un stand en su feria
+func (c *StandController) CreateStand(w http.ResponseWriter, r *http.Request) {
+	fairID, ok := fairIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var request standRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	stand, err := c.StandService.CreateStand(r.Context(), fairID, request.form())
+	if err != nil {
+		log.Printf("Error al crear el stand en la feria %d: %v", fairID, err)
+		writeServiceError(w, err, "Error creating stand")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(stand)
+}
+
+// UpdateStand - Endpoint para reemplazar los datos y los expositores de un stand
+func (c *StandController) UpdateStand(w http.ResponseWriter, r *http.Request) {
+	fairID, standID, ok := standIDsFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var request standRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	stand, err := c.StandService.UpdateStand(r.Context(), fairID, standID, request.form())
+	if err != nil {
+		log.Printf("Error al actualizar el stand %d: %v", standID, err)
+		writeServiceError(w, err, "Error updating stand")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stand)
+}
+
+// DeleteStand - Endpoint para borrar un stand
+func (c *StandController) DeleteStand(w http.ResponseWriter, r *http.Request) {
+	fairID, standID, ok := standIDsFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.StandService.DeleteStand(r.Context(), fairID, standID); err != nil {
+		log.Printf("Error al borrar el stand %d: %v", standID, err)
+		writeServiceError(w, err, "Error deleting stand")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// standIDsFromPath lee el ID de la feria y el del stand de la URL
+func standIDsFromPath(w http.ResponseWriter, r *http.Request) (fairID, standID int, ok bool) {
+	if fairID, ok = fairIDFromPath(w, r); !ok {
+		return 0, 0, false
+	}
+	standID, err := strconv.Atoi(mux.Vars(r)["standId"])
+	if err != nil {
+		http.Error(w, "Invalid stand ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return fairID, standID, true
+}
@@ -4,6 +4,7 @@ import (
 	"dbconnection/models"
 	"dbconnection/services"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -14,8 +15,10 @@ import (
 )
 
 type UserController struct {
-	UserService *services.UserService
-	Cloudinary  *cloudinary.Cloudinary
+	UserService       *services.UserService
+	Cloudinary        *cloudinary.Cloudinary
+	TrustProxyHeaders bool            // Usar X-Forwarded-For para obtener la IP del cliente
+	Cookies           *SessionCookies // Modo de sesión con cookies (?mode=cookie)
 }
 
 func (controller *UserController) Login(w http.ResponseWriter, r *http.Request) {
@@ -35,23 +38,31 @@ func (controller *UserController) Login(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Log para verificar que los datos fueron correctamente decodificados
-	log.Printf("Decoded login data: email=%s, password=%s", loginData.Email, loginData.Password)
+	log.Printf("Decoded login data: email=%s", loginData.Email)
 
 	// Llamar al servicio para realizar el login
-	loginResponse, err := controller.UserService.Login(loginData.Email, loginData.Password)
+	client := clientInfo(r, controller.TrustProxyHeaders)
+	loginResponse, err := controller.UserService.Login(loginData.Email, loginData.Password, client)
 	if err != nil {
 		// Log el error que ocurre en el servicio de Login
 		log.Printf("Error during login: %v", err)
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		writeServiceError(w, err, "Error during login")
 		return
 	}
 
 	// Log para verificar la respuesta del login
-	log.Printf("Login successful, user ID: %d", loginResponse.User.ID)
+	if loginResponse.MFARequired {
+		log.Printf("Password accepted, waiting for MFA code")
+	} else {
+		log.Printf("Login successful, user ID: %d", loginResponse.User.ID)
+	}
 
 	// Retornar el token y la información del usuario
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(loginResponse)
+	controller.Cookies.writeLoginResponse(w, loginResponse, cookieModeRequested(r))
 }
 
 func (controller *UserController) CreateUser(w http.ResponseWriter, r *http.Request) {
@@ -63,9 +74,10 @@ func (controller *UserController) CreateUser(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Crear el usuario con los datos del formulario
-	createdUser, err := controller.UserService.RegisterUser(&user)
+	createdUser, err := controller.UserService.RegisterUser(r.Context(), &user)
 	if err != nil {
-		http.Error(w, "Error creating user", http.StatusInternalServerError)
+		log.Printf("Error al registrar el usuario: %v", err)
+		writeServiceError(w, err, "Error creating user")
 		return
 	}
 
@@ -74,6 +86,26 @@ func (controller *UserController) CreateUser(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(createdUser)
 }
 
+// ChangePassword - Endpoint para que el usuario autenticado cambie su contraseña
+func (controller *UserController) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		CurrentPassword string `json:"contraseña_actual"`
+		NewPassword     string `json:"contraseña_nueva"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := controller.UserService.ChangePassword(r.Context(), body.CurrentPassword, body.NewPassword); err != nil {
+		log.Printf("Error al cambiar la contraseña: %v", err)
+		writeServiceError(w, err, "Error changing password")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (c *UserController) GetUser(w http.ResponseWriter, r *http.Request) {
 	idStr := r.URL.Query().Get("id")
 	id, err := strconv.Atoi(idStr)
@@ -99,6 +131,20 @@ func (controller *UserController) UpdateUserProfile(w http.ResponseWriter, r *ht
 		return
 	}
 
+	// Obtener el ID del usuario desde la URL
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	// Verificar los permisos antes de subir cualquier archivo
+	if err := controller.UserService.CheckProfileOwnership(r.Context(), id); err != nil {
+		writeServiceError(w, err, "Error updating user profile")
+		return
+	}
+
 	// Obtener los datos del formulario
 	user := &models.User{
 		Nombre:    r.FormValue("nombre"),
@@ -115,6 +161,11 @@ func (controller *UserController) UpdateUserProfile(w http.ResponseWriter, r *ht
 
 	// Subir la foto a Cloudinary
 	if file != nil {
+		if controller.Cloudinary == nil {
+			http.Error(w, "Image uploads are disabled", http.StatusServiceUnavailable)
+			return
+		}
+
 		// Usar UploadParams de Cloudinary
 		uploadParams := uploader.UploadParams{
 			Folder:    "profile_pictures", // Puedes definir una carpeta en Cloudinary
@@ -133,18 +184,10 @@ func (controller *UserController) UpdateUserProfile(w http.ResponseWriter, r *ht
 		user.FotoPerfil = uploadResult.URL
 	}
 
-	// Obtener el ID del usuario desde la URL
-	idStr := mux.Vars(r)["id"]
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
-		return
-	}
-
 	// Actualizar los datos del usuario (incluyendo la URL de la foto de perfil)
-	updatedUser, err := controller.UserService.UpdateUserProfile(id, user)
+	updatedUser, err := controller.UserService.UpdateUserProfile(r.Context(), id, user)
 	if err != nil {
-		http.Error(w, "Error updating user profile", http.StatusInternalServerError)
+		writeServiceError(w, err, "Error updating user profile")
 		return
 	}
 
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"dbconnection/services"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type WebAuthnController struct {
+	WebAuthnService   *services.WebAuthnService
+	TrustProxyHeaders bool
+	Cookies           *SessionCookies // Modo de sesión con cookies (?mode=cookie)
+}
+
+// webAuthnFinishRequest es la respuesta del navegador a la ceremonia; credential
+// es el PublicKeyCredential serializado tal cual
+type webAuthnFinishRequest struct {
+	SessionID  string          `json:"session_id"`
+	Nombre     string          `json:"nombre"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+// BeginRegistration - Endpoint para obtener las opciones de navigator.credentials.create
+func (c *WebAuthnController) BeginRegistration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ceremony, err := c.WebAuthnService.BeginRegistration(r.Context())
+	if err != nil {
+		log.Printf("Error al iniciar el registro de la passkey: %v", err)
+		writeServiceError(w, err, "Error starting passkey registration")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ceremony)
+}
+
+// FinishRegistration - Endpoint para guardar la passkey creada por el navegador
+func (c *WebAuthnController) FinishRegistration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body webAuthnFinishRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.SessionID == "" || len(body.Credential) == 0 {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	credential, err := c.WebAuthnService.FinishRegistration(r.Context(), body.SessionID, body.Nombre, body.Credential)
+	if err != nil {
+		log.Printf("Error al registrar la passkey: %v", err)
+		writeServiceError(w, err, "Error registering passkey")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(credential)
+}
+
+// ListCredentials - Endpoint para listar las passkeys del usuario
+func (c *WebAuthnController) ListCredentials(w http.ResponseWriter, r *http.Request) {
+	credentials, err := c.WebAuthnService.ListCredentials(r.Context())
+	if err != nil {
+		log.Printf("Error al listar las passkeys: %v", err)
+		writeServiceError(w, err, "Error listing passkeys")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(credentials)
+}
+
+// DeleteCredential - Endpoint para quitar una passkey del usuario
+func (c *WebAuthnController) DeleteCredential(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid credential ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.WebAuthnService.DeleteCredential(r.Context(), id); err != nil {
+		log.Printf("Error al borrar la passkey %d: %v", id, err)
+		writeServiceError(w, err, "Error deleting passkey")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BeginLogin - Endpoint para obtener las opciones de navigator.credentials.get
+func (c *WebAuthnController) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ceremony, err := c.WebAuthnService.BeginLogin()
+	if err != nil {
+		log.Printf("Error al iniciar el login con passkey: %v", err)
+		writeServiceError(w, err, "Error starting passkey login")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ceremony)
+}
+
+// FinishLogin - Endpoint para validar la passkey; responde igual que /api/login
+func (c *WebAuthnController) FinishLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body webAuthnFinishRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.SessionID == "" || len(body.Credential) == 0 {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	loginResponse, err := c.WebAuthnService.FinishLogin(body.SessionID, body.Credential, clientInfo(r, c.TrustProxyHeaders))
+	if err != nil {
+		log.Printf("Error durante el login con passkey: %v", err)
+		writeServiceError(w, err, "Error during login")
+		return
+	}
+
+	c.Cookies.writeLoginResponse(w, loginResponse, cookieModeRequested(r))
+}
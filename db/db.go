@@ -10,7 +10,8 @@ import (
 )
 
 func Connect(cfg *config.Config) (*sql.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s",
+	// parseTime permite leer las columnas DATETIME como time.Time
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
 		cfg.DBUser,
 		cfg.DBPassword,
 		cfg.DBHost,
@@ -23,6 +24,11 @@ func Connect(cfg *config.Config) (*sql.DB, error) {
 		return nil, fmt.Errorf("Error al abrir la base de datos: %v", err)
 	}
 
+	// Tamaño del pool de conexiones
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("Error al conectar a la base de datos: %v", err)
 	}
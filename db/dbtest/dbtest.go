@@ -0,0 +1,112 @@
+// Package dbtest prepara bases de datos MySQL desechables para las pruebas que
+// necesitan transacciones y bloqueos reales.
+package dbtest
+
+import (
+	"database/sql"
+	"dbconnection/db"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DSNEnv es la variable con el DSN de un servidor MySQL para las pruebas, por
+// ejemplo "root:secret@tcp(localhost:3306)/". Si no está definida, las pruebas
+// que usan la base de datos se omiten.
+const DSNEnv = "TEST_MYSQL_DSN"
+
+// baseSchema son las tablas que ya existían antes de la primera migración, con
+// las columnas que las migraciones esperan encontrar
+var baseSchema = []string{
+	`CREATE TABLE usuario (
+		id_usuario INT AUTO_INCREMENT PRIMARY KEY,
+		nombre VARCHAR(100) NOT NULL,
+		ocupacion VARCHAR(100) NOT NULL DEFAULT '',
+		contraseña VARCHAR(100) NOT NULL,
+		email VARCHAR(100) NOT NULL UNIQUE,
+		foto_perfil VARCHAR(255) NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE feria (
+		id_feria INT AUTO_INCREMENT PRIMARY KEY,
+		titulo VARCHAR(255) NOT NULL,
+		descripcion TEXT,
+		fecha_inicio VARCHAR(255),
+		id_usuario INT NOT NULL,
+		foto_feria VARCHAR(255)
+	)`,
+	`CREATE TABLE preferenciasusuarios (
+		id_pref INT AUTO_INCREMENT PRIMARY KEY,
+		id_usuario INT NOT NULL,
+		linkedinlink VARCHAR(255),
+		instagramlink VARCHAR(255),
+		xlink VARCHAR(255)
+	)`,
+}
+
+// Open crea una base de datos vacía con el esquema base y todas las
+// migraciones, y la borra al terminar la prueba
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv(DSNEnv)
+	if dsn == "" {
+		t.Skipf("%s no está definida; se omite la prueba con base de datos", DSNEnv)
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("%s inválido: %v", DSNEnv, err)
+	}
+	cfg.ParseTime = true
+	cfg.DBName = ""
+
+	server, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	name := fmt.Sprintf("ferias_test_%d", time.Now().UnixNano())
+	if _, err := server.Exec("CREATE DATABASE " + name + " CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci"); err != nil {
+		t.Fatalf("Error al crear la base de datos de prueba: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := server.Exec("DROP DATABASE " + name); err != nil {
+			t.Logf("Error al borrar la base de datos de prueba %s: %v", name, err)
+		}
+	})
+
+	cfg.DBName = name
+	database, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	for _, statement := range baseSchema {
+		if _, err := database.Exec(statement); err != nil {
+			t.Fatalf("Error al crear el esquema base: %v", err)
+		}
+	}
+	if err := db.Migrate(database); err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+// CreateUser inserta un usuario con el rol indicado y devuelve su ID
+func CreateUser(t testing.TB, database *sql.DB, name, role string) int {
+	t.Helper()
+	result, err := database.Exec("INSERT INTO usuario (nombre, contraseña, email, rol, email_verificado, email_verificado_alguna_vez) VALUES (?, '', ?, ?, TRUE, TRUE)",
+		name, fmt.Sprintf("%s-%d@example.com", name, time.Now().UnixNano()), role)
+	if err != nil {
+		t.Fatalf("Error al crear el usuario %s: %v", name, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(id)
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrate aplica en orden los scripts de db/migrations que todavía no se han
+// ejecutado y registra cada uno en la tabla schema_migrations.
+func Migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version VARCHAR(255) NOT NULL PRIMARY KEY,
+		aplicada_en TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("Error al crear la tabla schema_migrations: %v", err)
+	}
+
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		version := strings.TrimSuffix(strings.TrimPrefix(name, "migrations/"), ".sql")
+
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = ?", version).Scan(&count); err != nil {
+			return fmt.Errorf("Error al consultar la migración %s: %v", version, err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		content, err := migrationFiles.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		// El driver de MySQL no admite varias sentencias en un mismo Exec
+		for _, statement := range strings.Split(string(content), ";") {
+			if strings.TrimSpace(statement) == "" {
+				continue
+			}
+			if _, err := db.Exec(statement); err != nil {
+				return fmt.Errorf("Error al aplicar la migración %s: %v", version, err)
+			}
+		}
+
+		if _, err := db.Exec("INSERT INTO schema_migrations (version) VALUES (?)", version); err != nil {
+			return fmt.Errorf("Error al registrar la migración %s: %v", version, err)
+		}
+		log.Printf("Migración aplicada: %s", version)
+	}
+
+	return nil
+}
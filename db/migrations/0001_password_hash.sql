@@ -0,0 +1,2 @@
+-- Los hashes bcrypt ocupan 60 caracteres; se deja margen para otros algoritmos
+ALTER TABLE usuario MODIFY contraseña VARCHAR(255);
@@ -0,0 +1,20 @@
+-- Tokens de actualización rotativos; todos los de un mismo login comparten familia
+CREATE TABLE IF NOT EXISTS refresh_token (
+	id_refresh INT AUTO_INCREMENT PRIMARY KEY,
+	id_usuario INT NOT NULL,
+	token_hash CHAR(64) NOT NULL,
+	familia CHAR(32) NOT NULL,
+	expira_en DATETIME NOT NULL,
+	usado_en DATETIME NULL,
+	revocado_en DATETIME NULL,
+	creado_en DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY uq_refresh_token_hash (token_hash),
+	KEY idx_refresh_token_familia (familia),
+	KEY idx_refresh_token_usuario (id_usuario)
+);
+
+-- Tokens de acceso revocados antes de su expiración (logout)
+CREATE TABLE IF NOT EXISTS token_revocado (
+	jti CHAR(32) NOT NULL PRIMARY KEY,
+	expira_en DATETIME NOT NULL
+);
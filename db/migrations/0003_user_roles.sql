@@ -0,0 +1,5 @@
+-- Rol del usuario: admin, organizer, exhibitor o visitor
+ALTER TABLE usuario ADD COLUMN rol VARCHAR(20) NOT NULL DEFAULT 'visitor';
+
+-- Los usuarios que ya crearon ferias conservan ese permiso
+UPDATE usuario SET rol = 'organizer' WHERE id_usuario IN (SELECT DISTINCT id_usuario FROM feria);
@@ -0,0 +1,11 @@
+-- Tokens de un solo uso para restablecer la contraseña; solo se guarda el hash
+CREATE TABLE IF NOT EXISTS password_reset_token (
+	id_reset INT AUTO_INCREMENT PRIMARY KEY,
+	id_usuario INT NOT NULL,
+	token_hash CHAR(64) NOT NULL,
+	expira_en DATETIME NOT NULL,
+	usado_en DATETIME NULL,
+	creado_en DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY uq_password_reset_token_hash (token_hash),
+	KEY idx_password_reset_usuario (id_usuario)
+);
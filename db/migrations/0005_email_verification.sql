@@ -0,0 +1,5 @@
+ALTER TABLE usuario ADD COLUMN email_verificado BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE usuario ADD COLUMN verificacion_enviada_en DATETIME NULL;
+
+-- Las cuentas creadas antes de la verificación de correo se consideran verificadas
+UPDATE usuario SET email_verificado = TRUE;
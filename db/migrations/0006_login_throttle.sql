@@ -0,0 +1,7 @@
+-- Intentos fallidos de inicio de sesión por cuenta ("email:...") y por IP ("ip:...")
+CREATE TABLE IF NOT EXISTS login_throttle (
+	clave VARCHAR(320) NOT NULL PRIMARY KEY,
+	fallos INT NOT NULL DEFAULT 0,
+	ultimo_fallo DATETIME NOT NULL,
+	bloqueado_hasta DATETIME NULL
+);
@@ -0,0 +1,17 @@
+-- Segundo factor TOTP; confirmado pasa a TRUE cuando el usuario valida el primer código
+CREATE TABLE IF NOT EXISTS usuario_mfa (
+	id_usuario INT NOT NULL PRIMARY KEY,
+	secreto VARCHAR(64) NOT NULL,
+	confirmado BOOLEAN NOT NULL DEFAULT FALSE,
+	ultimo_paso BIGINT NOT NULL DEFAULT 0,
+	creado_en DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Códigos de recuperación de un solo uso; solo se guarda el hash
+CREATE TABLE IF NOT EXISTS codigo_recuperacion (
+	id_codigo INT AUTO_INCREMENT PRIMARY KEY,
+	id_usuario INT NOT NULL,
+	codigo_hash CHAR(64) NOT NULL,
+	usado_en DATETIME NULL,
+	KEY idx_codigo_recuperacion_usuario (id_usuario)
+);
@@ -0,0 +1,22 @@
+-- Solicitudes de inicio de sesión OIDC en curso; el state se guarda hasheado y
+-- se borra al volver del proveedor para que no se pueda reutilizar
+CREATE TABLE IF NOT EXISTS oidc_estado (
+	state_hash CHAR(64) NOT NULL PRIMARY KEY,
+	proveedor VARCHAR(50) NOT NULL,
+	verificador VARCHAR(128) NOT NULL,
+	nonce VARCHAR(64) NOT NULL,
+	expira_en DATETIME NOT NULL,
+	KEY idx_oidc_estado_expira (expira_en)
+);
+
+-- Identidades externas vinculadas a cada usuario (proveedor + sub del ID token)
+CREATE TABLE IF NOT EXISTS usuario_identidad (
+	id_identidad INT AUTO_INCREMENT PRIMARY KEY,
+	id_usuario INT NOT NULL,
+	proveedor VARCHAR(50) NOT NULL,
+	sujeto VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NULL,
+	creado_en DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY uq_usuario_identidad (proveedor, sujeto),
+	KEY idx_usuario_identidad_usuario (id_usuario)
+);
@@ -0,0 +1,13 @@
+-- Enlaces de inicio de sesión sin contraseña. Se guardan los hashes del token
+-- y del valor de la cookie del navegador que lo pidió; solo se pueden usar una vez
+CREATE TABLE IF NOT EXISTS magic_link_token (
+	id_magic_link INT AUTO_INCREMENT PRIMARY KEY,
+	id_usuario INT NOT NULL,
+	token_hash CHAR(64) NOT NULL,
+	navegador_hash CHAR(64) NOT NULL,
+	expira_en DATETIME NOT NULL,
+	usado_en DATETIME NULL,
+	creado_en DATETIME NOT NULL,
+	UNIQUE KEY uq_magic_link_token_hash (token_hash),
+	KEY idx_magic_link_usuario (id_usuario)
+);
@@ -0,0 +1,24 @@
+-- Passkeys registradas por cada usuario. datos guarda el registro completo de
+-- la credencial (llave pública, banderas, AAGUID) serializado en JSON
+CREATE TABLE IF NOT EXISTS webauthn_credencial (
+	id_credencial INT AUTO_INCREMENT PRIMARY KEY,
+	id_usuario INT NOT NULL,
+	credential_id VARBINARY(255) NOT NULL,
+	nombre VARCHAR(100) NOT NULL,
+	datos TEXT NOT NULL,
+	contador INT UNSIGNED NOT NULL DEFAULT 0,
+	creado_en DATETIME NOT NULL,
+	ultimo_uso_en DATETIME NULL,
+	UNIQUE KEY uq_webauthn_credential_id (credential_id),
+	KEY idx_webauthn_credencial_usuario (id_usuario)
+);
+
+-- Ceremonias de registro o inicio de sesión en curso (desafío y opciones);
+-- id_usuario es 0 en los inicios de sesión, donde todavía no se sabe quién es
+CREATE TABLE IF NOT EXISTS webauthn_sesion (
+	sesion_hash CHAR(64) NOT NULL PRIMARY KEY,
+	id_usuario INT NOT NULL DEFAULT 0,
+	datos TEXT NOT NULL,
+	expira_en DATETIME NOT NULL,
+	KEY idx_webauthn_sesion_expira (expira_en)
+);
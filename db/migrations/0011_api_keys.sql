@@ -0,0 +1,16 @@
+-- API keys para integraciones (por ejemplo, el portal de la universidad). Solo
+-- se guarda el hash de la llave; prefijo es el inicio visible para reconocerla
+-- en los listados. scopes son los alcances otorgados, separados por comas
+CREATE TABLE IF NOT EXISTS api_key (
+	id_api_key INT AUTO_INCREMENT PRIMARY KEY,
+	id_usuario INT NOT NULL,
+	nombre VARCHAR(100) NOT NULL,
+	prefijo VARCHAR(16) NOT NULL,
+	key_hash CHAR(64) NOT NULL,
+	scopes VARCHAR(255) NOT NULL,
+	creado_en DATETIME NOT NULL,
+	ultimo_uso_en DATETIME NULL,
+	revocado_en DATETIME NULL,
+	UNIQUE KEY uq_api_key_hash (key_hash),
+	KEY idx_api_key_usuario (id_usuario)
+);
@@ -0,0 +1,13 @@
+-- Un registro por cada inicio de sesión exitoso. familia es la sesión (familia
+-- de tokens de actualización) que abrió, así que la misma tabla sirve como
+-- historial y, junto con refresh_token, para listar las sesiones activas
+CREATE TABLE IF NOT EXISTS sesion (
+	familia CHAR(32) NOT NULL PRIMARY KEY,
+	id_usuario INT NOT NULL,
+	ip VARCHAR(45) NOT NULL,
+	user_agent VARCHAR(255) NOT NULL,
+	dispositivo_nuevo BOOLEAN NOT NULL DEFAULT FALSE,
+	creado_en DATETIME NOT NULL,
+	ultimo_uso_en DATETIME NOT NULL,
+	KEY idx_sesion_usuario (id_usuario, creado_en)
+);
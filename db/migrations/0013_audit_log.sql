@@ -0,0 +1,18 @@
+-- Registro de auditoría de las altas, cambios y bajas. La aplicación solo
+-- inserta y consulta esta tabla; antes y despues guardan en JSON únicamente los
+-- campos que cambiaron (o el registro completo en altas y bajas)
+CREATE TABLE IF NOT EXISTS auditoria (
+	id_auditoria BIGINT AUTO_INCREMENT PRIMARY KEY,
+	id_actor INT NULL,
+	id_api_key INT NULL,
+	accion VARCHAR(50) NOT NULL,
+	entidad VARCHAR(30) NOT NULL,
+	id_entidad INT NOT NULL,
+	antes TEXT NULL,
+	despues TEXT NULL,
+	request_id VARCHAR(64) NOT NULL,
+	creado_en DATETIME NOT NULL,
+	KEY idx_auditoria_actor (id_actor, creado_en),
+	KEY idx_auditoria_entidad (entidad, id_entidad, creado_en),
+	KEY idx_auditoria_fecha (creado_en)
+);
@@ -0,0 +1,3 @@
+-- Administrador que actuaba a nombre de id_actor cuando se hizo el cambio
+ALTER TABLE auditoria ADD COLUMN id_impersonador INT NULL AFTER id_api_key;
+ALTER TABLE auditoria ADD KEY idx_auditoria_impersonador (id_impersonador, creado_en);
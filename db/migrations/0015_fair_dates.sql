@@ -0,0 +1,17 @@
+-- Las fechas de las ferias pasan de texto libre a instantes en UTC, con fecha
+-- de fin y la zona horaria IANA en la que se muestran
+ALTER TABLE feria
+	ADD COLUMN inicio DATETIME NULL,
+	ADD COLUMN fin DATETIME NULL,
+	ADD COLUMN zona_horaria VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- De los valores con formato AAAA-MM-DD se conserva el día y se asume que la
+-- feria dura ese día completo. Los demás quedan en NULL; el texto original se
+-- guarda en fecha_inicio_original para corregirlos a mano.
+UPDATE feria SET inicio = STR_TO_DATE(LEFT(fecha_inicio, 10), '%Y-%m-%d') WHERE fecha_inicio REGEXP '^[0-9]{4}-[0-9]{2}-[0-9]{2}';
+UPDATE feria SET fin = DATE_ADD(inicio, INTERVAL 1 DAY) WHERE inicio IS NOT NULL;
+
+ALTER TABLE feria CHANGE fecha_inicio fecha_inicio_original VARCHAR(255) NULL;
+ALTER TABLE feria CHANGE inicio fecha_inicio DATETIME NULL;
+ALTER TABLE feria CHANGE fin fecha_fin DATETIME NULL;
+CREATE INDEX idx_feria_fechas ON feria (fecha_inicio, fecha_fin);
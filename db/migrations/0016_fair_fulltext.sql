@@ -0,0 +1,6 @@
+-- Título y descripción con una intercalación que no distingue acentos ni
+-- mayúsculas, para que "tecnologia" encuentre "tecnología"
+ALTER TABLE feria CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- Índice de la búsqueda de texto de /api/fairs/search
+CREATE FULLTEXT INDEX ft_feria_texto ON feria (titulo, descripcion);
@@ -0,0 +1,16 @@
+-- Capacidad opcional de cada feria; NULL significa sin límite
+ALTER TABLE feria ADD COLUMN capacidad INT NULL;
+
+-- Inscripciones de los usuarios a las ferias. Las que no caben quedan en lista
+-- de espera y suben por orden de llegada (id_inscripcion) cuando hay lugar
+CREATE TABLE IF NOT EXISTS inscripcion (
+	id_inscripcion INT AUTO_INCREMENT PRIMARY KEY,
+	id_feria INT NOT NULL,
+	id_usuario INT NOT NULL,
+	estado VARCHAR(20) NOT NULL,
+	creado_en DATETIME NOT NULL,
+	confirmado_en DATETIME NULL,
+	UNIQUE KEY uq_inscripcion_feria_usuario (id_feria, id_usuario),
+	KEY idx_inscripcion_feria_estado (id_feria, estado, id_inscripcion),
+	KEY idx_inscripcion_usuario (id_usuario)
+);
@@ -0,0 +1,6 @@
+-- Boletos de las inscripciones confirmadas. codigo_boleto va dentro del boleto
+-- firmado; al cancelar la inscripción se borra y el boleto deja de servir.
+-- entrada_en es la hora en que se registró la entrada en la feria.
+ALTER TABLE inscripcion ADD COLUMN codigo_boleto CHAR(32) NULL;
+ALTER TABLE inscripcion ADD COLUMN entrada_en DATETIME NULL;
+ALTER TABLE inscripcion ADD UNIQUE KEY uq_inscripcion_codigo_boleto (codigo_boleto);
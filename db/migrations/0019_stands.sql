@@ -0,0 +1,18 @@
+-- Stands de cada feria. codigo identifica el stand dentro de la feria (por
+-- ejemplo "A-12") y ubicacion es una etiqueta libre ("Pabellón 2, pasillo B")
+CREATE TABLE IF NOT EXISTS stand (
+	id_stand INT AUTO_INCREMENT PRIMARY KEY,
+	id_feria INT NOT NULL,
+	codigo VARCHAR(20) NOT NULL,
+	ubicacion VARCHAR(100) NOT NULL DEFAULT '',
+	creado_en DATETIME NOT NULL,
+	UNIQUE KEY uq_stand_feria_codigo (id_feria, codigo)
+);
+
+-- Expositores asignados a cada stand
+CREATE TABLE IF NOT EXISTS stand_expositor (
+	id_stand INT NOT NULL,
+	id_usuario INT NOT NULL,
+	PRIMARY KEY (id_stand, id_usuario),
+	KEY idx_stand_expositor_usuario (id_usuario)
+);
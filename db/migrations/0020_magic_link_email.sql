@@ -0,0 +1,9 @@
+-- Correo al que se envió cada enlace de inicio de sesión. Si el usuario cambia
+-- de correo, los enlaces enviados a la dirección anterior dejan de valer. Los
+-- pendientes de antes de este cambio quedan sin correo y ya no se pueden usar
+ALTER TABLE magic_link_token ADD COLUMN email VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Distingue las cuentas que nunca demostraron ser dueñas de su correo de las
+-- que lo verificaron y después lo cambiaron
+ALTER TABLE usuario ADD COLUMN email_verificado_alguna_vez BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE usuario SET email_verificado_alguna_vez = email_verificado;
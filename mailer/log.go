@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogMailer no envía nada: escribe los correos en un archivo (o en el log si
+// Path está vacío) para poder seguir los enlaces en desarrollo y pruebas
+type LogMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	entry := fmt.Sprintf("--- %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+
+	if m.Path == "" {
+		log.Print("Correo (no enviado):\n" + entry)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error al abrir el archivo de correos: %v", err)
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(entry)
+	return err
+}
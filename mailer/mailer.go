@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"dbconnection/config"
+	"fmt"
+)
+
+// Message es un correo de texto plano
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer envía correos; la implementación se elige con MAIL_BACKEND
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New construye el Mailer configurado: "smtp" para producción o "log" para
+// desarrollo y pruebas, que no necesita un servidor de correo
+func New(cfg *config.Config) (Mailer, error) {
+	switch cfg.MailBackend {
+	case "", "log":
+		return &LogMailer{Path: cfg.MailLogPath}, nil
+	case "smtp":
+		return &SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUser,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}, nil
+	default:
+		return nil, fmt.Errorf("MAIL_BACKEND no soportado: %s", cfg.MailBackend)
+	}
+}
@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPMailer envía los correos a través de un servidor SMTP con autenticación PLAIN
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	headers := []string{
+		"From: " + headerValue(m.From),
+		"To: " + headerValue(msg.To),
+		"Subject: " + headerValue(msg.Subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	body := strings.Join(headers, "\r\n") + "\r\n\r\n" + msg.Body
+
+	if err := smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{msg.To}, []byte(body)); err != nil {
+		return fmt.Errorf("error al enviar el correo a %s: %v", msg.To, err)
+	}
+	return nil
+}
+
+// headerValue elimina los saltos de línea para evitar la inyección de cabeceras
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
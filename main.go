@@ -1,15 +1,20 @@
 package main
 
 import (
+	"dbconnection/auth"
 	"dbconnection/config"
 	"dbconnection/controllers"
 	"dbconnection/db"
+	"dbconnection/mailer"
+	"dbconnection/middleware"
 	"dbconnection/repositories"
 	"dbconnection/services"
 	"log"
 	"net/http"
+	_ "time/tzdata" // Zonas horarias de las ferias aunque el sistema no tenga la base IANA
 
 	"github.com/cloudinary/cloudinary-go/v2" // Asegúrate de que esta importación esté presente
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 )
@@ -17,63 +22,350 @@ import (
 func main() {
 	// Cargar configuración y conectar a la base de datos
 	cfg := config.LoadConfig()
+	log.Printf("Configuración cargada:\n%s", cfg)
 	database, err := db.Connect(cfg)
 	if err != nil {
 		log.Fatalf("Error de conexión a la base de datos: %v", err)
 	}
 	defer database.Close()
 
+	// Aplicar las migraciones pendientes del esquema
+	if err := db.Migrate(database); err != nil {
+		log.Fatalf("Error al migrar la base de datos: %v", err)
+	}
+
+	// Emisor y validador único de tokens JWT
+	tokenManager, err := auth.NewTokenManager(cfg)
+	if err != nil {
+		log.Fatalf("Error de configuración de los tokens JWT: %v", err)
+	}
+
 	// Inicializar repositorios, servicios y controladores
+	tokenRepo := &repositories.TokenRepository{DB: database}
 	userRepo := &repositories.UserRepository{DB: database}
-	userService := &services.UserService{UserRepo: userRepo}
-	userController := &controllers.UserController{UserService: userService}
+	tokenService := &services.TokenService{TokenRepo: tokenRepo, UserRepo: userRepo, Tokens: tokenManager, RefreshTTL: cfg.RefreshTTL}
+
+	// Correo saliente (SMTP o archivo/log en desarrollo)
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		log.Fatalf("Error de configuración del correo: %v", err)
+	}
+
+	// Registro de auditoría de las altas, cambios y bajas
+	auditService := &services.AuditService{AuditRepo: &repositories.AuditRepository{DB: database}}
+
+	// Historial de inicios de sesión y sesiones activas
+	sessionService := &services.SessionService{
+		SessionRepo:     &repositories.SessionRepository{DB: database},
+		TokenRepo:       tokenRepo,
+		UserRepo:        userRepo,
+		Mailer:          mail,
+		NewDeviceAlerts: cfg.NewDeviceAlerts,
+	}
+	tokenService.Sessions = sessionService
+	sessionController := &controllers.SessionController{SessionService: sessionService}
+
+	// Política de contraseñas para el registro, el cambio y el restablecimiento
+	passwordPolicy := &auth.PasswordPolicy{MinLength: cfg.PasswordMinLength, MinEntropy: float64(cfg.PasswordMinEntropy)}
+	if cfg.BreachedPasswordsFile != "" {
+		passwordPolicy.Breached, err = auth.LoadBreachedPasswords(cfg.BreachedPasswordsFile)
+		if err != nil {
+			log.Fatalf("Error al cargar las contraseñas filtradas: %v", err)
+		}
+		log.Printf("Lista de contraseñas filtradas cargada: %d hashes", passwordPolicy.Breached.Len())
+	}
+
+	passwordResetRepo := &repositories.PasswordResetRepository{DB: database}
+	passwordResetService := &services.PasswordResetService{
+		UserRepo:       userRepo,
+		ResetRepo:      passwordResetRepo,
+		TokenService:   tokenService,
+		Audit:          auditService,
+		PasswordPolicy: passwordPolicy,
+		Mailer:         mail,
+		BaseURL:        cfg.AppBaseURL,
+		TTL:            cfg.PasswordResetTTL,
+		ResendInterval: cfg.PasswordResetResendWait,
+		BcryptCost:     cfg.BcryptCost,
+	}
+	emailVerification := &services.EmailVerificationService{
+		UserRepo:       userRepo,
+		Tokens:         tokenManager,
+		Mailer:         mail,
+		BaseURL:        cfg.AppBaseURL,
+		TTL:            cfg.EmailVerificationTTL,
+		ResendInterval: cfg.VerificationResendWait,
+	}
+	// Modo de sesión con cookies para las páginas que no guardan los tokens en localStorage
+	sessionCookies := &controllers.SessionCookies{Secure: cfg.CookieSecure, RefreshTTL: cfg.RefreshTTL}
+	authController := &controllers.AuthController{
+		Tokens:               tokenManager,
+		TokenService:         tokenService,
+		PasswordResetService: passwordResetService,
+		EmailVerification:    emailVerification,
+		CookieSecure:         cfg.CookieSecure,
+		TrustProxyHeaders:    cfg.TrustProxyHeaders,
+		Cookies:              sessionCookies,
+	}
+
+	// Acciones que requieren el correo verificado
+	restricted := make([]auth.Action, 0, len(cfg.UnverifiedRestrictions))
+	for _, action := range cfg.UnverifiedRestrictions {
+		restricted = append(restricted, auth.Action(action))
+	}
+	auth.RestrictUnverified(restricted)
+
+	loginThrottle := &services.LoginThrottleService{
+		ThrottleRepo:  &repositories.LoginThrottleRepository{DB: database},
+		UserRepo:      userRepo,
+		MaxAttempts:   cfg.LoginMaxAttempts,
+		MaxAttemptsIP: cfg.LoginMaxAttemptsIP,
+		Window:        cfg.LoginWindow,
+		BaseLockout:   cfg.LoginLockout,
+		MaxLockout:    cfg.LoginMaxLockout,
+	}
+
+	mfaService := &services.MFAService{
+		MFARepo:       &repositories.MFARepository{DB: database},
+		UserRepo:      userRepo,
+		TokenService:  tokenService,
+		Tokens:        tokenManager,
+		LoginThrottle: loginThrottle,
+		Issuer:        cfg.MFAIssuer,
+		ChallengeTTL:  cfg.MFAChallengeTTL,
+	}
+	// Inicio de sesión sin contraseña con enlaces de un solo uso
+	magicLinkRepo := &repositories.MagicLinkRepository{DB: database}
+	authController.MagicLink = &services.MagicLinkService{
+		UserRepo:       userRepo,
+		MagicLinkRepo:  magicLinkRepo,
+		MFA:            mfaService,
+		TokenService:   tokenService,
+		Audit:          auditService,
+		BcryptCost:     cfg.BcryptCost,
+		Mailer:         mail,
+		BaseURL:        cfg.AppBaseURL,
+		TTL:            cfg.MagicLinkTTL,
+		ResendInterval: cfg.MagicLinkResendWait,
+	}
+	mfaController := &controllers.MFAController{MFAService: mfaService, TrustProxyHeaders: cfg.TrustProxyHeaders, Cookies: sessionCookies}
 
-	fairRepo := &repositories.FairRepository{DB: database}
-	fairService := &services.FairService{FairRepo: fairRepo}
+	userService := &services.UserService{
+		UserRepo:          userRepo,
+		MagicLinkRepo:     magicLinkRepo,
+		TokenService:      tokenService,
+		EmailVerification: emailVerification,
+		LoginThrottle:     loginThrottle,
+		MFA:               mfaService,
+		Audit:             auditService,
+		PasswordPolicy:    passwordPolicy,
+		BcryptCost:        cfg.BcryptCost,
+	}
+	userController := &controllers.UserController{UserService: userService, TrustProxyHeaders: cfg.TrustProxyHeaders, Cookies: sessionCookies}
+	// Inicio de sesión con proveedores OpenID Connect
+	oidcProviders := make(map[string]*auth.OIDCProvider, len(cfg.OIDCProviders))
+	for _, providerCfg := range cfg.OIDCProviders {
+		oidcProviders[providerCfg.Name] = auth.NewOIDCProvider(providerCfg)
+	}
+	oidcService := &services.OIDCService{
+		Providers:    oidcProviders,
+		OIDCRepo:     &repositories.OIDCRepository{DB: database},
+		UserRepo:     userRepo,
+		TokenService: tokenService,
+		MFA:          mfaService,
+		Audit:        auditService,
+		RedirectURL:  cfg.OIDCRedirectURL,
+		StateTTL:     cfg.OIDCStateTTL,
+		BcryptCost:   cfg.BcryptCost,
+	}
+	oidcController := &controllers.OIDCController{
+		OIDCService:       oidcService,
+		TrustProxyHeaders: cfg.TrustProxyHeaders,
+		CookieSecure:      cfg.CookieSecure,
+		Cookies:           sessionCookies,
+	}
+
+	// Passkeys (WebAuthn)
+	webAuthnTimeout := webauthn.TimeoutConfig{Enforce: true, Timeout: cfg.WebAuthnTimeout, TimeoutUVD: cfg.WebAuthnTimeout}
+	relyingParty, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: cfg.WebAuthnRPName,
+		RPOrigins:     cfg.WebAuthnOrigins,
+		Timeouts:      webauthn.TimeoutsConfig{Login: webAuthnTimeout, Registration: webAuthnTimeout},
+	})
+	if err != nil {
+		log.Fatalf("Error de configuración de WebAuthn: %v", err)
+	}
+	webAuthnService := &services.WebAuthnService{
+		WebAuthn:     relyingParty,
+		WebAuthnRepo: &repositories.WebAuthnRepository{DB: database},
+		UserRepo:     userRepo,
+		TokenService: tokenService,
+		SessionTTL:   cfg.WebAuthnTimeout,
+	}
+	webAuthnController := &controllers.WebAuthnController{WebAuthnService: webAuthnService, TrustProxyHeaders: cfg.TrustProxyHeaders, Cookies: sessionCookies}
+
+	// Suplantación de usuarios para soporte
+	impersonationService := &services.ImpersonationService{
+		UserRepo: userRepo,
+		Tokens:   tokenManager,
+		Audit:    auditService,
+		TTL:      cfg.ImpersonationTTL,
+	}
+	adminController := &controllers.AdminController{
+		UserService:   userService,
+		LoginThrottle: loginThrottle,
+		Audit:         auditService,
+		Impersonation: impersonationService,
+	}
+
+	fairRepo := &repositories.FairRepository{DB: database, FullTextSearch: cfg.FairSearchBackend == "fulltext"}
+	registrationService := &services.RegistrationService{
+		RegistrationRepo: &repositories.RegistrationRepository{DB: database},
+		FairRepo:         fairRepo,
+		UserRepo:         userRepo,
+		Mailer:           mail,
+	}
+	fairService := &services.FairService{FairRepo: fairRepo, Audit: auditService, Registrations: registrationService}
 	fairController := &controllers.FairController{FairService: fairService}
+	ticketService := &services.TicketService{
+		RegistrationRepo: registrationService.RegistrationRepo,
+		FairRepo:         fairRepo,
+		Tokens:           tokenManager,
+	}
+	registrationController := &controllers.RegistrationController{RegistrationService: registrationService, TicketService: ticketService}
+	standService := &services.StandService{
+		StandRepo: &repositories.StandRepository{DB: database},
+		FairRepo:  fairRepo,
+		UserRepo:  userRepo,
+		Audit:     auditService,
+	}
+	standController := &controllers.StandController{StandService: standService}
 
 	preferenceRepo := &repositories.PreferenceRepository{DB: database}
-	preferenceService := &services.PreferenceService{PreferenceRepo: preferenceRepo}
+	preferenceService := &services.PreferenceService{PreferenceRepo: preferenceRepo, Audit: auditService}
 	preferenceController := &controllers.PreferenceController{PreferenceService: preferenceService}
 
-	// Configurar Cloudinary
-	cld, err := cloudinary.NewFromParams("drlf5ytmk", "241212669924127", "kJQMb-K02kyMVnSjGSWPMd_Vpgs")
-	if err != nil {
-		log.Fatalf("Error de configuración de Cloudinary: %v", err)
+	// Configurar Cloudinary; con MEDIA_BACKEND=none se rechazan las subidas de imágenes
+	if cfg.MediaBackend == "cloudinary" {
+		cld, err := cloudinary.NewFromParams(cfg.CloudinaryCloudName, cfg.CloudinaryAPIKey, cfg.CloudinaryAPISecret)
+		if err != nil {
+			log.Fatalf("Error de configuración de Cloudinary: %v", err)
+		}
+		log.Println("Cloudinary configurado correctamente")
+
+		// Pasar la instancia de Cloudinary al controlador
+		userController.Cloudinary = cld
+		fairController.Cloudinary = cld
+	} else {
+		log.Println("Subida de imágenes deshabilitada (MEDIA_BACKEND=none)")
 	}
-	log.Println("Cloudinary configurado correctamente")
 
-	// Pasar la instancia de Cloudinary al controlador
-	userController.Cloudinary = cld
-	fairController.Cloudinary = cld
+	// API keys para integraciones externas
+	apiKeyService := &services.APIKeyService{APIKeyRepo: &repositories.APIKeyRepository{DB: database}, UserRepo: userRepo}
+	apiKeyController := &controllers.APIKeyController{APIKeyService: apiKeyService}
+
+	// Middleware que valida el token JWT (o la API key, en las rutas de ferias)
+	authMiddleware := &middleware.AuthMiddleware{
+		Tokens:        tokenManager,
+		TokenService:  tokenService,
+		APIKeys:       apiKeyService,
+		Impersonation: impersonationService,
+	}
+	protected := func(handler http.HandlerFunc) http.Handler {
+		return authMiddleware.RequireAuth(handler)
+	}
+	withAPIKey := func(handler http.HandlerFunc) http.Handler {
+		return authMiddleware.RequireAuthOrAPIKey(handler)
+	}
+	optionalAPIKey := func(handler http.HandlerFunc) http.Handler {
+		return authMiddleware.OptionalAuthOrAPIKey(handler)
+	}
 
 	// Configurar las rutas de la API
 	mux := mux.NewRouter()
+	mux.HandleFunc("/.well-known/jwks.json", authController.JWKS)
 	mux.HandleFunc("/api/login", userController.Login)
+	mux.HandleFunc("/api/auth/refresh", authController.Refresh)
+	mux.Handle("/api/auth/logout", protected(authController.Logout))
+	mux.Handle("/api/auth/sessions", protected(sessionController.ListSessions)).Methods("GET")
+	mux.Handle("/api/auth/sessions/{id}", protected(sessionController.RevokeSession)).Methods("DELETE")
+	mux.Handle("/api/auth/login-history", protected(sessionController.LoginHistory)).Methods("GET")
+	mux.Handle("/api/auth/change-password", protected(userController.ChangePassword)).Methods("POST")
+	mux.HandleFunc("/api/auth/forgot-password", authController.ForgotPassword)
+	mux.HandleFunc("/api/auth/reset-password", authController.ResetPassword)
+	mux.HandleFunc("/api/auth/verify-email", authController.VerifyEmail)
+	mux.HandleFunc("/api/auth/magic-link", authController.RequestMagicLink)
+	mux.HandleFunc("/api/auth/magic-link/login", authController.MagicLinkLogin)
+	mux.Handle("/api/auth/resend-verification", protected(authController.ResendVerification))
+	mux.Handle("/api/auth/mfa/enroll", protected(mfaController.Enroll))
+	mux.Handle("/api/auth/mfa/confirm", protected(mfaController.Confirm))
+	mux.Handle("/api/auth/mfa/disable", protected(mfaController.Disable))
+	mux.HandleFunc("/api/auth/mfa/verify", mfaController.Verify)
+	mux.HandleFunc("/api/auth/oidc/providers", oidcController.Providers).Methods("GET")
+	mux.HandleFunc("/api/auth/oidc/{provider}/authorize", oidcController.Authorize).Methods("GET")
+	mux.HandleFunc("/api/auth/oidc/callback", oidcController.Callback)
+	mux.Handle("/api/auth/webauthn/register/begin", protected(webAuthnController.BeginRegistration))
+	mux.Handle("/api/auth/webauthn/register/finish", protected(webAuthnController.FinishRegistration))
+	mux.HandleFunc("/api/auth/webauthn/login/begin", webAuthnController.BeginLogin)
+	mux.HandleFunc("/api/auth/webauthn/login/finish", webAuthnController.FinishLogin)
+	mux.Handle("/api/auth/webauthn/credentials", protected(webAuthnController.ListCredentials)).Methods("GET")
+	mux.Handle("/api/auth/webauthn/credentials/{id}", protected(webAuthnController.DeleteCredential)).Methods("DELETE")
 	mux.HandleFunc("/api/users", userController.CreateUser)
 	mux.HandleFunc("/api/users/get", userController.GetUser)
-	mux.HandleFunc("/api/users/update/{id}", userController.UpdateUserProfile)
-	mux.HandleFunc("/api/fairs", fairController.CreateFair)
-	mux.HandleFunc("/api/fairs/get", fairController.GetFair)
-	mux.HandleFunc("/api/fairs/getAll", fairController.GetAllFairs)
-	mux.HandleFunc("/api/fairs/update/{id}", fairController.UpdateFair)
-	mux.HandleFunc("/api/fairs/delete/{id}", fairController.DeleteFair)
+	mux.Handle("/api/users/update/{id}", protected(userController.UpdateUserProfile))
+	mux.Handle("/api/fairs", withAPIKey(fairController.CreateFair))
+	mux.Handle("/api/fairs/get", optionalAPIKey(fairController.GetFair))
+	mux.Handle("/api/fairs/getAll", optionalAPIKey(fairController.GetAllFairs))
+	mux.Handle("/api/fairs/search", optionalAPIKey(fairController.SearchFairs)).Methods("GET")
+	mux.Handle("/api/fairs/update/{id}", withAPIKey(fairController.UpdateFair))
+	mux.Handle("/api/fairs/delete/{id}", withAPIKey(fairController.DeleteFair))
+	mux.Handle("/api/fairs/{id}/registration", protected(registrationController.Register)).Methods("POST")
+	mux.Handle("/api/fairs/{id}/registration", protected(registrationController.Unregister)).Methods("DELETE")
+	mux.Handle("/api/fairs/{id}/registration", protected(registrationController.GetRegistration)).Methods("GET")
+	mux.Handle("/api/fairs/{id}/registrations", protected(registrationController.ListRegistrations)).Methods("GET")
+	mux.Handle("/api/fairs/{id}/registration/ticket", protected(registrationController.GetTicket)).Methods("GET")
+	mux.Handle("/api/fairs/{id}/registration/ticket.png", protected(registrationController.GetTicketQR)).Methods("GET")
+	mux.Handle("/api/fairs/{id}/check-in", protected(registrationController.CheckIn)).Methods("POST")
+	mux.Handle("/api/fairs/{id}/attendance", protected(registrationController.Attendance)).Methods("GET")
+	mux.Handle("/api/fairs/{id}/stands", optionalAPIKey(standController.ListStands)).Methods("GET")
+	mux.Handle("/api/fairs/{id}/stands", withAPIKey(standController.CreateStand)).Methods("POST")
+	mux.Handle("/api/fairs/{id}/stands/{standId}", optionalAPIKey(standController.GetStand)).Methods("GET")
+	mux.Handle("/api/fairs/{id}/stands/{standId}", withAPIKey(standController.UpdateStand)).Methods("PUT")
+	mux.Handle("/api/fairs/{id}/stands/{standId}", withAPIKey(standController.DeleteStand)).Methods("DELETE")
 	mux.HandleFunc("/api/preferences", preferenceController.GetPreferences)
-	mux.HandleFunc("/api/preferences/update", preferenceController.UpdatePreferences)
-	mux.HandleFunc("/api/preferences/create", preferenceController.CreatePreferences)
+	mux.Handle("/api/preferences/update", protected(preferenceController.UpdatePreferences))
+	mux.Handle("/api/preferences/create", protected(preferenceController.CreatePreferences))
+	mux.Handle("/api/api-keys", protected(apiKeyController.CreateKey)).Methods("POST")
+	mux.Handle("/api/api-keys", protected(apiKeyController.ListKeys)).Methods("GET")
+	mux.Handle("/api/api-keys/{id}", protected(apiKeyController.RevokeKey)).Methods("DELETE")
+	mux.Handle("/api/admin/users/{id}/role", protected(adminController.GrantRole)).Methods("PUT")
+	mux.Handle("/api/admin/users/{id}/role", protected(adminController.RevokeRole)).Methods("DELETE")
+	mux.Handle("/api/admin/users/{id}/unlock", protected(adminController.UnlockUser)).Methods("POST")
+	mux.Handle("/api/admin/users/{id}/impersonate", protected(adminController.ImpersonateUser)).Methods("POST")
+	mux.Handle("/api/admin/audit", protected(adminController.AuditLog)).Methods("GET")
 
 	// Configurar el middleware CORS
 	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"}, // Permitir solicitudes desde el frontend
+		AllowedOrigins:   cfg.CORSAllowedOrigins, // Permitir solicitudes desde el frontend
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
-		AllowCredentials: true, // Si necesitas enviar cookies o cabeceras de autenticación
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-API-Key", auth.CSRFHeader, middleware.RequestIDHeader},
+		ExposedHeaders:   []string{middleware.RequestIDHeader, middleware.ImpersonatorHeader},
+		AllowCredentials: cfg.CORSAllowCredentials, // Necesario para el modo de sesión con cookies; la configuración rechaza "*" en ese caso
 	})
 
-	// Envolver el servidor mux con CORS
-	handler := c.Handler(mux)
+	// Envolver el servidor mux con CORS; cada solicitud recibe un X-Request-ID
+	handler := middleware.RequestID(c.Handler(mux))
 
-	// Iniciar el servidor
-	log.Println("Servidor escuchando en http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", handler))
+	// Iniciar el servidor con tiempos límite para no quedar expuesto a clientes lentos
+	server := &http.Server{
+		Addr:              cfg.ListenAddr,
+		Handler:           handler,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
+	log.Printf("Servidor escuchando en %s", cfg.ListenAddr)
+	log.Fatal(server.ListenAndServe())
 }
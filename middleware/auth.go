@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"dbconnection/auth"
+	"dbconnection/services"
+	"dbconnection/utils"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiKeyHeader es la cabecera alternativa para enviar una API key
+const apiKeyHeader = "X-API-Key"
+
+// ImpersonatorHeader marca las respuestas a solicitudes hechas con un token de
+// suplantación; lleva el ID del administrador
+const ImpersonatorHeader = "X-Impersonated-By"
+
+type AuthMiddleware struct {
+	Tokens        *auth.TokenManager
+	TokenService  *services.TokenService
+	APIKeys       *services.APIKeyService
+	Impersonation *services.ImpersonationService
+}
+
+// authError es una credencial rechazada, con el código y el mensaje a responder
+type authError struct {
+	status  int
+	message string
+}
+
+// credentialKind indica de dónde salió la credencial de la solicitud
+type credentialKind int
+
+const (
+	credentialBearer credentialKind = iota
+	credentialAPIKey
+	credentialCookie
+)
+
+// RequireAuth valida el token Bearer de la cabecera Authorization (o el de la
+// cookie de sesión) y guarda el usuario autenticado en el contexto de la
+// solicitud. Responde 401 si el token falta, está mal firmado, ha expirado o fue
+// revocado. Las API keys no se aceptan: solo sirven en las rutas envueltas con
+// RequireAuthOrAPIKey.
+func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
+	return m.authenticate(next, true, false)
+}
+
+// RequireAuthOrAPIKey acepta un token Bearer o una API key (en Authorization
+// como Bearer o en X-API-Key). Los alcances de la llave se revisan en la
+// política de autorización de cada acción.
+func (m *AuthMiddleware) RequireAuthOrAPIKey(next http.Handler) http.Handler {
+	return m.authenticate(next, true, true)
+}
+
+// OptionalAuthOrAPIKey es para las rutas públicas: si la solicitud trae
+// credenciales se validan igual que en RequireAuthOrAPIKey, y si no trae
+// ninguna pasa sin usuario autenticado
+func (m *AuthMiddleware) OptionalAuthOrAPIKey(next http.Handler) http.Handler {
+	return m.authenticate(next, false, true)
+}
+
+func (m *AuthMiddleware) authenticate(next http.Handler, required, allowAPIKey bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credential, kind := credentialFromRequest(r)
+		if credential == "" {
+			if required {
+				utils.RespondWithError(w, http.StatusUnauthorized, "Token de autenticación no proporcionado")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var principal *auth.Principal
+		var failure *authError
+		switch kind {
+		case credentialAPIKey:
+			if !allowAPIKey {
+				utils.RespondWithError(w, http.StatusUnauthorized, "Esta ruta no acepta API keys")
+				return
+			}
+			principal, failure = m.apiKeyPrincipal(credential)
+		case credentialCookie:
+			// El navegador envía la cookie solo; el token CSRF demuestra que la
+			// solicitud salió de nuestras páginas
+			if !auth.ValidCSRF(r) {
+				utils.RespondWithError(w, http.StatusForbidden, "Token CSRF inválido o ausente")
+				return
+			}
+			principal, failure = m.tokenPrincipal(credential)
+		default:
+			principal, failure = m.tokenPrincipal(credential)
+		}
+		if failure != nil {
+			utils.RespondWithError(w, failure.status, failure.message)
+			return
+		}
+
+		// Cada solicitud suplantada queda en el log con las dos identidades
+		if principal.IsImpersonated() {
+			log.Printf("Suplantación: administrador %d como usuario %d: %s %s (request %s)",
+				principal.ImpersonatorID, principal.UserID, r.Method, r.URL.Path, utils.RequestIDFromContext(r.Context()))
+			w.Header().Set(ImpersonatorHeader, strconv.Itoa(principal.ImpersonatorID))
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// credentialFromRequest devuelve la credencial enviada y de dónde salió. Las
+// cabeceras tienen prioridad sobre la cookie de sesión.
+func credentialFromRequest(r *http.Request) (string, credentialKind) {
+	if key := strings.TrimSpace(r.Header.Get(apiKeyHeader)); key != "" {
+		return key, credentialAPIKey
+	}
+	if header := r.Header.Get("Authorization"); header != "" {
+		credential := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+		if services.LooksLikeAPIKey(credential) {
+			return credential, credentialAPIKey
+		}
+		return credential, credentialBearer
+	}
+	if cookie, err := r.Cookie(auth.SessionCookie); err == nil && cookie.Value != "" {
+		return cookie.Value, credentialCookie
+	}
+	return "", credentialBearer
+}
+
+func (m *AuthMiddleware) tokenPrincipal(tokenString string) (*auth.Principal, *authError) {
+	claims, err := m.Tokens.ParseAccessToken(tokenString)
+	if err != nil {
+		log.Printf("Token rechazado: %v", err)
+		return nil, &authError{http.StatusUnauthorized, "Token de autenticación inválido o expirado"}
+	}
+
+	revoked, err := m.TokenService.IsRevoked(claims)
+	if err != nil {
+		log.Printf("Error al verificar la revocación del token: %v", err)
+		return nil, &authError{http.StatusInternalServerError, "Error al verificar el token"}
+	}
+	if revoked {
+		return nil, &authError{http.StatusUnauthorized, "Token de autenticación revocado"}
+	}
+
+	impersonatorID := 0
+	if claims.Actor != nil {
+		active, err := m.Impersonation.IsActiveImpersonator(claims.Actor.UserID)
+		if err != nil {
+			log.Printf("Error al verificar al administrador de la suplantación: %v", err)
+			return nil, &authError{http.StatusInternalServerError, "Error al verificar el token"}
+		}
+		if !active {
+			return nil, &authError{http.StatusUnauthorized, "Token de suplantación revocado"}
+		}
+		impersonatorID = claims.Actor.UserID
+	}
+
+	return &auth.Principal{
+		UserID:        claims.UserID,
+		Role:          claims.Role,
+		EmailVerified: claims.EmailVerified,
+		TokenID:       claims.Id,
+		SessionID:     claims.SessionID,
+		ExpiresAt:     time.Unix(claims.ExpiresAt, 0),
+
+		ImpersonatorID: impersonatorID,
+	}, nil
+}
+
+func (m *AuthMiddleware) apiKeyPrincipal(key string) (*auth.Principal, *authError) {
+	principal, err := m.APIKeys.Authenticate(key)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKey) {
+			return nil, &authError{http.StatusUnauthorized, "API key inválida o revocada"}
+		}
+		log.Printf("Error al verificar la API key: %v", err)
+		return nil, &authError{http.StatusInternalServerError, "Error al verificar la API key"}
+	}
+	return principal, nil
+}
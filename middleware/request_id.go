@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"dbconnection/utils"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader es la cabecera con la que se recibe y se devuelve el
+// identificador de la solicitud
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 64
+
+// RequestID asigna un identificador a cada solicitud para relacionar los logs y
+// el registro de auditoría. Se respeta el que envía un proxy o el cliente si es
+// razonable; si no, se genera uno nuevo. El identificador se devuelve en la respuesta.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(utils.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// validRequestID acepta solo caracteres que no puedan alterar los logs
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range requestID {
+		isAlphanumeric := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlphanumeric && c != '-' && c != '_' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(raw)
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// APIKey es una llave con la que una integración accede a la API a nombre de un usuario
+type APIKey struct {
+	ID          int        `json:"id"`
+	IdUsuario   int        `json:"id_usuario"`
+	Nombre      string     `json:"nombre"`
+	Prefijo     string     `json:"prefijo"` // Inicio de la llave, para reconocerla
+	KeyHash     string     `json:"-"`
+	Scopes      []string   `json:"scopes"`
+	CreadoEn    time.Time  `json:"creado_en"`
+	UltimoUsoEn *time.Time `json:"ultimo_uso_en"`
+	RevocadoEn  *time.Time `json:"revocado_en"`
+}
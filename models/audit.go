@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry es un cambio registrado en el log de auditoría
+type AuditEntry struct {
+	ID       int64 `json:"id"`
+	IdActor  *int  `json:"id_actor"`   // nil si la acción no la hizo un usuario autenticado
+	IdAPIKey *int  `json:"id_api_key"` // API key con la que actuó, si fue el caso
+	// Administrador que actuaba como id_actor durante una suplantación
+	IdImpersonador *int            `json:"id_impersonador"`
+	Accion         string          `json:"accion"`
+	Entidad        string          `json:"entidad"`
+	IdEntidad      int             `json:"id_entidad"`
+	Antes          json.RawMessage `json:"antes"`
+	Despues        json.RawMessage `json:"despues"`
+	RequestID      string          `json:"request_id"`
+	CreadoEn       time.Time       `json:"creado_en"`
+}
+
+// AuditFilter son los filtros de la consulta del log de auditoría; los valores
+// cero no filtran
+type AuditFilter struct {
+	ActorID        int
+	ImpersonatorID int
+	Entidad        string
+	EntityID       int
+	Desde          time.Time
+	Hasta          time.Time
+	BeforeID       int64 // Para paginar: solo registros anteriores a este ID
+	Limit          int
+}
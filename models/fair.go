@@ -1,12 +1,118 @@
 package models
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
 
 type Fair struct {
 	ID          int            `json:"id_feria"`
 	Titulo      string         `json:"titulo"`
 	Descripcion string         `json:"descripcion"`
-	FechaInicio string         `json:"fecha_inicio"` // Usa `time.Time` si prefieres manejar fechas
+	FechaInicio time.Time      `json:"fecha_inicio"` // RFC 3339, con el desfase de la zona de la feria
+	FechaFin    time.Time      `json:"fecha_fin"`
+	ZonaHoraria string         `json:"zona_horaria"` // Zona IANA, por ejemplo America/Mexico_City
+	Capacidad   *int           `json:"capacidad"`    // Máximo de inscritos; nil si no tiene límite
 	IdUsuario   int            `json:"id_usuario"`   // FK para relacionar el usuario creador
 	FotoFeria   sql.NullString `json:"foto_feria"`   // Nueva propiedad para la foto de la feria
 }
+
+// LocalizeDates expresa las fechas en la zona horaria de la feria, para que el
+// JSON muestre la hora local junto con su desfase. En la base de datos se
+// guardan en UTC.
+func (f *Fair) LocalizeDates() {
+	location, err := time.LoadLocation(f.ZonaHoraria)
+	if err != nil {
+		location = time.UTC
+	}
+	if !f.FechaInicio.IsZero() {
+		f.FechaInicio = f.FechaInicio.In(location)
+	}
+	if !f.FechaFin.IsZero() {
+		f.FechaFin = f.FechaFin.In(location)
+	}
+}
+
+// Estados de una feria según la hora actual, para filtrar el listado
+const (
+	FairUpcoming = "upcoming" // Todavía no empieza
+	FairOngoing  = "ongoing"  // Ya empezó y no ha terminado
+	FairPast     = "past"     // Ya terminó
+)
+
+// ValidFairStatus indica si el estado es uno de los que admite el filtro
+func ValidFairStatus(status string) bool {
+	switch status {
+	case FairUpcoming, FairOngoing, FairPast:
+		return true
+	}
+	return false
+}
+
+// Criterios de orden del listado de ferias
+const (
+	FairSortStart   = "start"   // Fecha de inicio
+	FairSortTitle   = "title"   // Título
+	FairSortCreated = "created" // Orden de creación
+)
+
+// ValidFairSort indica si el criterio de orden es uno de los admitidos
+func ValidFairSort(sort string) bool {
+	switch sort {
+	case FairSortStart, FairSortTitle, FairSortCreated:
+		return true
+	}
+	return false
+}
+
+// FairFilter son los filtros del listado de ferias; los valores cero no filtran.
+// Desde y Hasta seleccionan las ferias que se cruzan con ese intervalo.
+type FairFilter struct {
+	Status    string
+	Desde     time.Time
+	Hasta     time.Time
+	IdUsuario int    // Organizador
+	Texto     string // Se busca en el título y la descripción
+
+	Sort   string      // Criterio de orden, ver FairSortStart
+	Order  string      // "asc" o "desc"
+	Cursor *FairCursor // Posición después de la cual empieza la página
+	Limit  int
+}
+
+// Descending indica si el listado va en orden descendente
+func (f FairFilter) Descending() bool {
+	return f.Order == "desc"
+}
+
+// FairCursor es la posición de la última feria de una página: el valor del
+// criterio de orden y el ID, que desempata. Guarda también el orden para
+// rechazar un cursor usado con otro criterio.
+type FairCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v,omitempty"`
+	ID    int    `json:"id"`
+}
+
+// FairPage es una página del listado de ferias
+type FairPage struct {
+	Items      []Fair  `json:"items"`
+	NextCursor *string `json:"next_cursor"` // nil en la última página
+	Total      int     `json:"total"`       // Ferias que cumplen los filtros, en todas las páginas
+}
+
+// FairSearchResult es una feria encontrada por la búsqueda de texto. Los campos
+// resaltados son HTML escapado con las coincidencias dentro de <mark>.
+type FairSearchResult struct {
+	Feria           Fair    `json:"feria"`
+	Relevancia      float64 `json:"relevancia"`
+	TituloResaltado string  `json:"titulo_resaltado"`
+	Fragmento       string  `json:"fragmento"` // Extracto de la descripción alrededor de la coincidencia
+}
+
+// FairSearchPage son los resultados de una búsqueda, del más al menos relevante
+type FairSearchPage struct {
+	Items []FairSearchResult `json:"items"`
+	Total int                `json:"total"` // Ferias que coinciden, aunque no quepan en la página
+}
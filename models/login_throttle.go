@@ -0,0 +1,14 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// LoginThrottle lleva la cuenta de los intentos fallidos de una cuenta o una IP
+type LoginThrottle struct {
+	Clave          string
+	Fallos         int
+	UltimoFallo    time.Time
+	BloqueadoHasta sql.NullTime
+}
@@ -0,0 +1,14 @@
+package models
+
+// MFA es la configuración TOTP de un usuario
+type MFA struct {
+	IdUsuario  int
+	Secreto    string
+	Confirmado bool
+	UltimoPaso int64 // Último paso TOTP aceptado, para impedir reutilizar un código
+}
+
+// Confirmed indica si el usuario terminó la inscripción del segundo factor
+func (m *MFA) Confirmed() bool {
+	return m != nil && m.Confirmado
+}
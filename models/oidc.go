@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// OIDCState es una solicitud de inicio de sesión con un proveedor OIDC que
+// todavía no volvió con el código de autorización
+type OIDCState struct {
+	StateHash   string
+	Proveedor   string
+	Verificador string // code_verifier de PKCE
+	Nonce       string
+	ExpiraEn    time.Time
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type RefreshToken struct {
+	ID         int
+	IdUsuario  int
+	Familia    string // Identificador compartido por todos los tokens de una misma sesión
+	ExpiraEn   time.Time
+	UsadoEn    sql.NullTime
+	RevocadoEn sql.NullTime
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// Estados de una inscripción a una feria
+const (
+	RegistrationConfirmed = "confirmed" // Tiene lugar en la feria
+	RegistrationWaitlist  = "waitlist"  // Espera a que se libere un lugar
+)
+
+// Registration es la inscripción de un usuario a una feria
+type Registration struct {
+	ID           int        `json:"id_inscripcion"`
+	IdFeria      int        `json:"id_feria"`
+	IdUsuario    int        `json:"id_usuario"`
+	Estado       string     `json:"estado"`
+	Posicion     int        `json:"posicion,omitempty"` // Lugar en la lista de espera, desde 1
+	CreadoEn     time.Time  `json:"creado_en"`
+	ConfirmadoEn *time.Time `json:"confirmado_en"`
+	EntradaEn    *time.Time `json:"entrada_en"` // Hora en que se registró su entrada
+	CodigoBoleto string     `json:"-"`          // Va dentro del boleto firmado
+
+	// Datos del usuario, solo en el listado para el organizador
+	Nombre string `json:"nombre,omitempty"`
+	Email  string `json:"email,omitempty"`
+}
+
+// RegistrationSummary resume las inscripciones de una feria
+type RegistrationSummary struct {
+	Capacidad     *int           `json:"capacidad"` // nil si no tiene límite
+	Confirmadas   int            `json:"confirmadas"`
+	EnEspera      int            `json:"en_espera"`
+	Presentes     int            `json:"presentes"`
+	Inscripciones []Registration `json:"inscripciones"`
+}
+
+// Attendance es la asistencia a una feria
+type Attendance struct {
+	IdFeria     int  `json:"id_feria"`
+	Capacidad   *int `json:"capacidad"`
+	Confirmadas int  `json:"confirmadas"`
+	Presentes   int  `json:"presentes"`  // Confirmadas que ya entraron
+	Pendientes  int  `json:"pendientes"` // Confirmadas que todavía no llegan
+}
+
+// Ticket es el boleto de una inscripción confirmada; Boleto es el valor firmado
+// que va en el código QR
+type Ticket struct {
+	IdInscripcion int        `json:"id_inscripcion"`
+	IdFeria       int        `json:"id_feria"`
+	Boleto        string     `json:"boleto"`
+	EntradaEn     *time.Time `json:"entrada_en"`
+}
+
+// CheckIn es el resultado de registrar la entrada de un boleto
+type CheckIn struct {
+	Inscripcion Registration `json:"inscripcion"`
+	Asistencia  Attendance   `json:"asistencia"`
+}
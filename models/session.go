@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Session es un inicio de sesión exitoso y la sesión que abrió
+type Session struct {
+	ID               string    `json:"id"` // Familia de tokens de actualización
+	IdUsuario        int       `json:"-"`
+	IP               string    `json:"ip"`
+	UserAgent        string    `json:"user_agent"`
+	DispositivoNuevo bool      `json:"dispositivo_nuevo"`
+	CreadoEn         time.Time `json:"creado_en"`
+	UltimoUsoEn      time.Time `json:"ultimo_uso_en"`
+	Activa           bool      `json:"activa"`
+	Actual           bool      `json:"actual"` // La sesión de la solicitud en curso
+}
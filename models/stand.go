@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Stand es el espacio de un equipo o expositor dentro de una feria
+type Stand struct {
+	ID          int              `json:"id_stand"`
+	IdFeria     int              `json:"id_feria"`
+	Codigo      string           `json:"codigo"`
+	Ubicacion   string           `json:"ubicacion"`
+	Expositores []StandExhibitor `json:"expositores"`
+	CreadoEn    time.Time        `json:"creado_en"`
+}
+
+// StandExhibitor es un usuario expositor asignado a un stand
+type StandExhibitor struct {
+	IdUsuario int    `json:"id_usuario"`
+	Nombre    string `json:"nombre"`
+}
+
+// StandConflict es una asignación existente que impide asignar al expositor a
+// otro stand: está en la misma feria o en una feria con fechas que se cruzan
+type StandConflict struct {
+	IdUsuario int    `json:"id_usuario"`
+	IdStand   int    `json:"id_stand"`
+	Codigo    string `json:"codigo"`
+	IdFeria   int    `json:"id_feria"`
+	Titulo    string `json:"titulo"`
+}
@@ -7,4 +7,10 @@ type User struct {
 	Password   string `json:"contraseña"`
 	Email      string `json:"email"`
 	FotoPerfil string `json:"foto_perfil"`
+	Rol        string `json:"rol"`
+
+	EmailVerificado bool `json:"email_verificado"`
+	// VerificadoAlgunaVez indica si el usuario demostró alguna vez ser dueño de
+	// su correo, aunque después lo haya cambiado por uno sin verificar
+	VerificadoAlgunaVez bool `json:"-"`
 }
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// WebAuthnCredential es una passkey registrada por un usuario
+type WebAuthnCredential struct {
+	ID           int        `json:"id"`
+	IdUsuario    int        `json:"-"`
+	CredentialID []byte     `json:"-"`
+	Nombre       string     `json:"nombre"`
+	Datos        []byte     `json:"-"` // Registro de la credencial serializado en JSON
+	Contador     uint32     `json:"-"` // Último contador de firmas visto, para detectar clones
+	CreadoEn     time.Time  `json:"creado_en"`
+	UltimoUsoEn  *time.Time `json:"ultimo_uso_en"`
+}
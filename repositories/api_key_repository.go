@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"database/sql"
+	"dbconnection/models"
+	"log"
+	"strings"
+	"time"
+)
+
+type APIKeyRepository struct {
+	DB *sql.DB
+}
+
+const apiKeyColumns = "id_api_key, id_usuario, nombre, prefijo, key_hash, scopes, creado_en, ultimo_uso_en, revocado_en"
+
+// CreateKey guarda una API key nueva
+func (repo *APIKeyRepository) CreateKey(key *models.APIKey) error {
+	key.CreadoEn = time.Now().UTC()
+	result, err := repo.DB.Exec("INSERT INTO api_key (id_usuario, nombre, prefijo, key_hash, scopes, creado_en) VALUES (?, ?, ?, ?, ?, ?)",
+		key.IdUsuario, key.Nombre, key.Prefijo, key.KeyHash, strings.Join(key.Scopes, ","), key.CreadoEn)
+	if err != nil {
+		log.Printf("Error al ejecutar INSERT en api_key: %v", err)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	key.ID = int(id)
+	return nil
+}
+
+// FindActiveByHash busca una llave que no esté revocada a partir de su hash
+func (repo *APIKeyRepository) FindActiveByHash(keyHash string) (*models.APIKey, error) {
+	row := repo.DB.QueryRow("SELECT "+apiKeyColumns+" FROM api_key WHERE key_hash = ? AND revocado_en IS NULL", keyHash)
+	return scanAPIKey(row)
+}
+
+// GetKeyByID busca una llave por su ID, esté revocada o no
+func (repo *APIKeyRepository) GetKeyByID(id int) (*models.APIKey, error) {
+	row := repo.DB.QueryRow("SELECT "+apiKeyColumns+" FROM api_key WHERE id_api_key = ?", id)
+	return scanAPIKey(row)
+}
+
+// ListKeys devuelve las llaves de un usuario, incluidas las revocadas
+func (repo *APIKeyRepository) ListKeys(userID int) ([]models.APIKey, error) {
+	rows, err := repo.DB.Query("SELECT "+apiKeyColumns+" FROM api_key WHERE id_usuario = ? ORDER BY creado_en DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeKey marca la llave como revocada; devuelve sql.ErrNoRows si no existe
+// o ya estaba revocada
+func (repo *APIKeyRepository) RevokeKey(id int) error {
+	result, err := repo.DB.Exec("UPDATE api_key SET revocado_en = ? WHERE id_api_key = ? AND revocado_en IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		log.Printf("Error al revocar la API key %d: %v", id, err)
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// TouchLastUsed registra el uso de la llave. Para no escribir en cada
+// solicitud, solo se actualiza si el último uso guardado es anterior a minInterval.
+func (repo *APIKeyRepository) TouchLastUsed(id int, minInterval time.Duration) error {
+	now := time.Now().UTC()
+	_, err := repo.DB.Exec("UPDATE api_key SET ultimo_uso_en = ? WHERE id_api_key = ? AND (ultimo_uso_en IS NULL OR ultimo_uso_en < ?)",
+		now, id, now.Add(-minInterval))
+	if err != nil {
+		log.Printf("Error al registrar el uso de la API key %d: %v", id, err)
+	}
+	return err
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	var scopes string
+	var ultimoUso, revocado sql.NullTime
+	err := row.Scan(&key.ID, &key.IdUsuario, &key.Nombre, &key.Prefijo, &key.KeyHash, &scopes, &key.CreadoEn, &ultimoUso, &revocado)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Scopes = []string{}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	if ultimoUso.Valid {
+		key.UltimoUsoEn = &ultimoUso.Time
+	}
+	if revocado.Valid {
+		key.RevocadoEn = &revocado.Time
+	}
+	return key, nil
+}
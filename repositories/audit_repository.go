@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"database/sql"
+	"dbconnection/models"
+	"log"
+	"strings"
+)
+
+// AuditRepository solo inserta y consulta: el log de auditoría no se modifica
+type AuditRepository struct {
+	DB *sql.DB
+}
+
+// CreateEntry agrega un registro al log de auditoría
+func (repo *AuditRepository) CreateEntry(entry *models.AuditEntry) error {
+	result, err := repo.DB.Exec("INSERT INTO auditoria (id_actor, id_api_key, id_impersonador, accion, entidad, id_entidad, antes, despues, request_id, creado_en) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		entry.IdActor, entry.IdAPIKey, entry.IdImpersonador, entry.Accion, entry.Entidad, entry.IdEntidad, nullJSON(entry.Antes), nullJSON(entry.Despues), entry.RequestID, entry.CreadoEn)
+	if err != nil {
+		log.Printf("Error al ejecutar INSERT en auditoria: %v", err)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	entry.ID = id
+	return nil
+}
+
+// ListEntries devuelve los registros que cumplen el filtro, del más reciente al más antiguo
+func (repo *AuditRepository) ListEntries(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	if filter.ActorID != 0 {
+		conditions = append(conditions, "id_actor = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.ImpersonatorID != 0 {
+		conditions = append(conditions, "id_impersonador = ?")
+		args = append(args, filter.ImpersonatorID)
+	}
+	if filter.Entidad != "" {
+		conditions = append(conditions, "entidad = ?")
+		args = append(args, filter.Entidad)
+	}
+	if filter.EntityID != 0 {
+		conditions = append(conditions, "id_entidad = ?")
+		args = append(args, filter.EntityID)
+	}
+	if !filter.Desde.IsZero() {
+		conditions = append(conditions, "creado_en >= ?")
+		args = append(args, filter.Desde.UTC())
+	}
+	if !filter.Hasta.IsZero() {
+		conditions = append(conditions, "creado_en < ?")
+		args = append(args, filter.Hasta.UTC())
+	}
+	if filter.BeforeID != 0 {
+		conditions = append(conditions, "id_auditoria < ?")
+		args = append(args, filter.BeforeID)
+	}
+
+	query := "SELECT id_auditoria, id_actor, id_api_key, id_impersonador, accion, entidad, id_entidad, antes, despues, request_id, creado_en FROM auditoria"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id_auditoria DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := repo.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var actor, apiKey, impersonator sql.NullInt64
+		var antes, despues sql.NullString
+		err := rows.Scan(&entry.ID, &actor, &apiKey, &impersonator, &entry.Accion, &entry.Entidad, &entry.IdEntidad, &antes, &despues, &entry.RequestID, &entry.CreadoEn)
+		if err != nil {
+			return nil, err
+		}
+		if actor.Valid {
+			id := int(actor.Int64)
+			entry.IdActor = &id
+		}
+		if apiKey.Valid {
+			id := int(apiKey.Int64)
+			entry.IdAPIKey = &id
+		}
+		if impersonator.Valid {
+			id := int(impersonator.Int64)
+			entry.IdImpersonador = &id
+		}
+		if antes.Valid {
+			entry.Antes = []byte(antes.String)
+		}
+		if despues.Valid {
+			entry.Despues = []byte(despues.String)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func nullJSON(value []byte) interface{} {
+	if len(value) == 0 {
+		return nil
+	}
+	return string(value)
+}
@@ -3,47 +3,210 @@ package repositories
 import (
 	"database/sql"
 	"dbconnection/models"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 )
 
 type FairRepository struct {
 	DB *sql.DB
+	// FullTextSearch usa el índice FULLTEXT de MySQL en SearchFairs; sin él la
+	// búsqueda revisa las ferias en Go
+	FullTextSearch bool
 }
 
-// GetAllFairs obtiene todas las ferias de la base de datos
-func (repo *FairRepository) GetAllFairs() ([]models.Fair, error) {
-	var fairs []models.Fair
-	query := "SELECT id_feria, titulo, descripcion, fecha_inicio, id_usuario, foto_feria FROM feria"
-	rows, err := repo.DB.Query(query)
+// fairColumns son las columnas que lee scanFair, en el mismo orden
+const fairColumns = "id_feria, titulo, descripcion, fecha_inicio, fecha_fin, zona_horaria, capacidad, id_usuario, foto_feria"
+
+// scanFair lee una feria; las fechas que quedaron en NULL al migrar el texto
+// libre anterior se dejan en cero. extra recibe las columnas que la consulta
+// agregue después de fairColumns.
+func scanFair(row rowScanner, extra ...interface{}) (*models.Fair, error) {
+	fair := &models.Fair{}
+	var inicio, fin sql.NullTime
+	var capacidad sql.NullInt64
+	// Usar Scan para asignar el valor nulo a FotoFeria como sql.NullString
+	dest := []interface{}{&fair.ID, &fair.Titulo, &fair.Descripcion, &inicio, &fin, &fair.ZonaHoraria, &capacidad, &fair.IdUsuario, &fair.FotoFeria}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if capacidad.Valid {
+		value := int(capacidad.Int64)
+		fair.Capacidad = &value
+	}
+	fair.FechaInicio = inicio.Time
+	fair.FechaFin = fin.Time
+	fair.LocalizeDates()
+	return fair, nil
+}
+
+// fairNoDate ocupa el lugar de las fechas en NULL al ordenar, para que el
+// cursor pueda compararlas
+var fairNoDate = time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fairSortColumns son las expresiones de cada criterio de orden
+var fairSortColumns = map[string]string{
+	models.FairSortStart:   "COALESCE(fecha_inicio, CAST('1000-01-01' AS DATETIME))",
+	models.FairSortTitle:   "titulo",
+	models.FairSortCreated: "id_feria",
+}
+
+// fairConditions arma el WHERE de los filtros del listado, sin el cursor
+func fairConditions(filter models.FairFilter, now time.Time) ([]string, []interface{}) {
+	conditions := []string{}
+	args := []interface{}{}
+	now = now.UTC()
+	switch filter.Status {
+	case models.FairUpcoming:
+		conditions = append(conditions, "fecha_inicio > ?")
+		args = append(args, now)
+	case models.FairOngoing:
+		conditions = append(conditions, "fecha_inicio <= ? AND fecha_fin > ?")
+		args = append(args, now, now)
+	case models.FairPast:
+		conditions = append(conditions, "fecha_fin <= ?")
+		args = append(args, now)
+	}
+	if !filter.Desde.IsZero() {
+		conditions = append(conditions, "fecha_fin > ?")
+		args = append(args, filter.Desde.UTC())
+	}
+	if !filter.Hasta.IsZero() {
+		conditions = append(conditions, "fecha_inicio < ?")
+		args = append(args, filter.Hasta.UTC())
+	}
+	if filter.IdUsuario != 0 {
+		conditions = append(conditions, "id_usuario = ?")
+		args = append(args, filter.IdUsuario)
+	}
+	if filter.Texto != "" {
+		pattern := "%" + escapeLike(filter.Texto) + "%"
+		conditions = append(conditions, "(titulo LIKE ? OR descripcion LIKE ?)")
+		args = append(args, pattern, pattern)
+	}
+	return conditions, args
+}
+
+// escapeLike escapa los comodines de LIKE para buscar el texto tal cual
+func escapeLike(text string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(text)
+}
+
+// ListFairs obtiene una página de ferias que cumplen el filtro, en el orden
+// pedido y a partir del cursor; now es la hora con la que se decide el estado
+// de cada feria. Devuelve el cursor de la página siguiente, o nil si no hay más.
+func (repo *FairRepository) ListFairs(filter models.FairFilter, now time.Time) ([]models.Fair, *models.FairCursor, error) {
+	column, ok := fairSortColumns[filter.Sort]
+	if !ok {
+		column, filter.Sort = fairSortColumns[models.FairSortStart], models.FairSortStart
+	}
+	direction, comparison := "ASC", ">"
+	if filter.Descending() {
+		direction, comparison = "DESC", "<"
+	}
+
+	conditions, args := fairConditions(filter, now)
+	if filter.Cursor != nil {
+		// Paginación por llave: las filas que van después de la última entregada
+		if filter.Sort == models.FairSortCreated {
+			conditions = append(conditions, "id_feria "+comparison+" ?")
+			args = append(args, filter.Cursor.ID)
+		} else {
+			value, err := fairCursorArg(filter.Sort, filter.Cursor.Value)
+			if err != nil {
+				return nil, nil, err
+			}
+			conditions = append(conditions, fmt.Sprintf("(%s %s ? OR (%s = ? AND id_feria %s ?))", column, comparison, column, comparison))
+			args = append(args, value, value, filter.Cursor.ID)
+		}
+	}
+
+	query := "SELECT " + fairColumns + " FROM feria"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if filter.Sort == models.FairSortCreated {
+		query += " ORDER BY id_feria " + direction
+	} else {
+		query += fmt.Sprintf(" ORDER BY %s %s, id_feria %s", column, direction, direction)
+	}
+	// Se pide una fila de más para saber si hay otra página
+	query += " LIMIT ?"
+	args = append(args, filter.Limit+1)
+
+	rows, err := repo.DB.Query(query, args...)
 	if err != nil {
 		log.Printf("Error al obtener las ferias: %v", err)
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
+	fairs := []models.Fair{}
 	for rows.Next() {
-		var fair models.Fair
-		// Usar Scan para asignar el valor nulo a FotoFeria como sql.NullString
-		if err := rows.Scan(&fair.ID, &fair.Titulo, &fair.Descripcion, &fair.FechaInicio, &fair.IdUsuario, &fair.FotoFeria); err != nil {
+		fair, err := scanFair(rows)
+		if err != nil {
 			log.Printf("Error al escanear la feria: %v", err)
-			return nil, err
+			return nil, nil, err
 		}
-		fairs = append(fairs, fair)
+		fairs = append(fairs, *fair)
 	}
 
 	if err := rows.Err(); err != nil {
 		log.Printf("Error al leer las filas: %v", err)
-		return nil, err
+		return nil, nil, err
 	}
 
-	return fairs, nil
+	if len(fairs) <= filter.Limit {
+		return fairs, nil, nil
+	}
+	fairs = fairs[:filter.Limit]
+	last := fairs[len(fairs)-1]
+	next := &models.FairCursor{Sort: filter.Sort, Desc: filter.Descending(), ID: last.ID}
+	switch filter.Sort {
+	case models.FairSortStart:
+		start := last.FechaInicio
+		if start.IsZero() {
+			start = fairNoDate
+		}
+		next.Value = start.UTC().Format(time.RFC3339Nano)
+	case models.FairSortTitle:
+		next.Value = last.Titulo
+	}
+	return fairs, next, nil
+}
+
+// fairCursorArg convierte el valor guardado en el cursor al tipo de la columna
+func fairCursorArg(sort, value string) (interface{}, error) {
+	if sort == models.FairSortStart {
+		start, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, fmt.Errorf("cursor con fecha inválida: %v", err)
+		}
+		return start.UTC(), nil
+	}
+	return value, nil
+}
+
+// CountFairs cuenta las ferias que cumplen el filtro, sin tener en cuenta el cursor
+func (repo *FairRepository) CountFairs(filter models.FairFilter, now time.Time) (int, error) {
+	conditions, args := fairConditions(filter, now)
+	query := "SELECT COUNT(*) FROM feria"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := repo.DB.QueryRow(query, args...).Scan(&total); err != nil {
+		log.Printf("Error al contar las ferias: %v", err)
+		return 0, err
+	}
+	return total, nil
 }
 
 // GetFairByID obtiene una feria por su ID
 func (repo *FairRepository) GetFairByID(id int) (*models.Fair, error) {
-	fair := &models.Fair{}
-	query := "SELECT id_feria, titulo, descripcion, fecha_inicio, id_usuario, foto_feria FROM feria WHERE id_feria = ?"
-	err := repo.DB.QueryRow(query, id).Scan(&fair.ID, &fair.Titulo, &fair.Descripcion, &fair.FechaInicio, &fair.IdUsuario, &fair.FotoFeria)
+	fair, err := scanFair(repo.DB.QueryRow("SELECT "+fairColumns+" FROM feria WHERE id_feria = ?", id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.Printf("No se encontró una feria con ID %d", id)
@@ -58,8 +221,8 @@ func (repo *FairRepository) GetFairByID(id int) (*models.Fair, error) {
 
 // CreateFair inserta una nueva feria en la base de datos y la devuelve
 func (repo *FairRepository) CreateFair(fair *models.Fair) (*models.Fair, error) {
-	result, err := repo.DB.Exec("INSERT INTO feria (titulo, descripcion, fecha_inicio, id_usuario, foto_feria) VALUES (?, ?, ?, ?, ?)",
-		fair.Titulo, fair.Descripcion, fair.FechaInicio, fair.IdUsuario, fair.FotoFeria)
+	result, err := repo.DB.Exec("INSERT INTO feria (titulo, descripcion, fecha_inicio, fecha_fin, zona_horaria, capacidad, id_usuario, foto_feria) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		fair.Titulo, fair.Descripcion, fair.FechaInicio.UTC(), fair.FechaFin.UTC(), fair.ZonaHoraria, fair.Capacidad, fair.IdUsuario, fair.FotoFeria)
 	if err != nil {
 		log.Printf("Error al ejecutar INSERT en feria: %v", err)
 		return nil, err
@@ -73,9 +236,7 @@ func (repo *FairRepository) CreateFair(fair *models.Fair) (*models.Fair, error)
 	}
 
 	// Recuperar la feria recién creada para devolverla completa
-	newFair := &models.Fair{}
-	query := "SELECT id_feria, titulo, descripcion, fecha_inicio, id_usuario, foto_feria FROM feria WHERE id_feria = ?"
-	err = repo.DB.QueryRow(query, fairID).Scan(&newFair.ID, &newFair.Titulo, &newFair.Descripcion, &newFair.FechaInicio, &newFair.IdUsuario, &newFair.FotoFeria)
+	newFair, err := scanFair(repo.DB.QueryRow("SELECT "+fairColumns+" FROM feria WHERE id_feria = ?", fairID))
 	if err != nil {
 		log.Printf("Error al ejecutar SELECT en feria para recuperar la nueva feria: %v", err)
 		return nil, err
@@ -84,9 +245,26 @@ func (repo *FairRepository) CreateFair(fair *models.Fair) (*models.Fair, error)
 	return newFair, nil
 }
 
-func (repo *FairRepository) UpdateFair(id int, fair *models.Fair) (*models.Fair, error) {
+// UpdateFair actualiza la feria. Si con las fechas nuevas algún expositor
+// quedaría en stands que se cruzan, no guarda nada y devuelve esas asignaciones.
+func (repo *FairRepository) UpdateFair(id int, fair *models.Fair) (*models.Fair, []models.StandConflict, error) {
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	// Se bloquean la feria y sus expositores en el mismo orden que SaveStand,
+	// así una asignación simultánea no puede cruzarse con las fechas nuevas
+	if _, err := lockFair(tx, id); err != nil {
+		return nil, nil, err
+	}
+	if err := lockFairExhibitors(tx, id); err != nil {
+		return nil, nil, err
+	}
+
 	// Preparar la consulta de actualización
-	query := `UPDATE feria SET titulo = ?, descripcion = ?, fecha_inicio = ?, id_usuario = ?, foto_feria = ? WHERE id_feria = ?`
+	query := `UPDATE feria SET titulo = ?, descripcion = ?, fecha_inicio = ?, fecha_fin = ?, zona_horaria = ?, capacidad = ?, id_usuario = ?, foto_feria = ? WHERE id_feria = ?`
 
 	// Aquí utilizamos .String si FotoFeria tiene valor, y "" si es nulo
 	fotoFeriaValue := ""
@@ -94,34 +272,61 @@ func (repo *FairRepository) UpdateFair(id int, fair *models.Fair) (*models.Fair,
 		fotoFeriaValue = fair.FotoFeria.String
 	}
 
-	_, err := repo.DB.Exec(query, fair.Titulo, fair.Descripcion, fair.FechaInicio, fair.IdUsuario, fotoFeriaValue, id)
+	_, err = tx.Exec(query, fair.Titulo, fair.Descripcion, fair.FechaInicio.UTC(), fair.FechaFin.UTC(), fair.ZonaHoraria, fair.Capacidad, fair.IdUsuario, fotoFeriaValue, id)
 	if err != nil {
 		log.Printf("Error al ejecutar UPDATE en feria: %v", err)
-		return nil, err
+		return nil, nil, err
+	}
+
+	// Con las fechas nuevas, ningún expositor puede quedar en dos stands que se cruzan
+	conflicts, err := findFairStandConflicts(tx, id, fair.FechaInicio.UTC(), fair.FechaFin.UTC())
+	if err != nil || len(conflicts) > 0 {
+		return nil, conflicts, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
 	}
 
 	// Recuperar la feria actualizada
-	updatedFair := &models.Fair{}
-	query = "SELECT id_feria, titulo, descripcion, fecha_inicio, id_usuario, foto_feria FROM feria WHERE id_feria = ?"
-	err = repo.DB.QueryRow(query, id).Scan(&updatedFair.ID, &updatedFair.Titulo, &updatedFair.Descripcion, &updatedFair.FechaInicio, &updatedFair.IdUsuario, &updatedFair.FotoFeria)
+	updatedFair, err := scanFair(repo.DB.QueryRow("SELECT "+fairColumns+" FROM feria WHERE id_feria = ?", id))
 	if err != nil {
 		log.Printf("Error al ejecutar SELECT en feria para recuperar la feria actualizada: %v", err)
-		return nil, err
+		return nil, nil, err
 	}
 
-	return updatedFair, nil
+	return updatedFair, nil, nil
 }
 
 // DeleteFair elimina una feria de la base de datos
 func (repo *FairRepository) DeleteFair(id int) error {
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Las inscripciones y los stands se borran junto con la feria
+	if _, err := tx.Exec("DELETE FROM inscripcion WHERE id_feria = ?", id); err != nil {
+		log.Printf("Error al borrar las inscripciones de la feria %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE se FROM stand_expositor se JOIN stand s ON s.id_stand = se.id_stand WHERE s.id_feria = ?", id); err != nil {
+		log.Printf("Error al borrar los expositores de los stands de la feria %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM stand WHERE id_feria = ?", id); err != nil {
+		log.Printf("Error al borrar los stands de la feria %d: %v", id, err)
+		return err
+	}
+
 	// Preparar la consulta para eliminar la feria por ID
 	query := "DELETE FROM feria WHERE id_feria = ?"
-	_, err := repo.DB.Exec(query, id)
+	_, err = tx.Exec(query, id)
 	if err != nil {
 		log.Printf("Error al ejecutar DELETE en feria: %v", err)
 		return err
 	}
 
 	// Si se elimina correctamente, no devolvemos error
-	return nil
+	return tx.Commit()
 }
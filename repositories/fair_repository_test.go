@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"dbconnection/auth"
+	"dbconnection/db/dbtest"
+	"dbconnection/models"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestListFairsKeysetPagination(t *testing.T) {
+	database := dbtest.Open(t)
+	repo := &FairRepository{DB: database}
+	owner := dbtest.CreateUser(t, database, "organizador", auth.RoleOrganizer)
+
+	// Títulos y fechas repetidos para que el orden dependa del desempate por
+	// id_feria; "expo" y "Expo" son iguales con la intercalación de la tabla
+	day := time.Date(2030, 3, 1, 9, 0, 0, 0, time.UTC)
+	fixtures := []struct {
+		title string
+		start time.Time
+	}{
+		{"Expo", day},
+		{"Expo", day},
+		{"Artesanía", day.Add(48 * time.Hour)},
+		{"expo", day},
+		{"Zeta", day.Add(-48 * time.Hour)},
+		{"Expo", day.Add(48 * time.Hour)},
+		{"Artesanía", day},
+		{"Zeta", day},
+		{"Sin fecha", time.Time{}},
+		{"Expo", time.Time{}},
+		{"Sin fecha", time.Time{}},
+	}
+	for _, fixture := range fixtures {
+		id := createFair(t, database, owner, fixture.title, day, nil)
+		if fixture.start.IsZero() {
+			// Fechas que quedaron en NULL al migrar el texto libre
+			if _, err := database.Exec("UPDATE feria SET fecha_inicio = NULL, fecha_fin = NULL WHERE id_feria = ?", id); err != nil {
+				t.Fatal(err)
+			}
+		} else if _, err := database.Exec("UPDATE feria SET fecha_inicio = ?, fecha_fin = ? WHERE id_feria = ?", fixture.start, fixture.start.Add(8*time.Hour), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sort := range []string{models.FairSortStart, models.FairSortTitle, models.FairSortCreated} {
+		for _, order := range []string{"asc", "desc"} {
+			all, next, err := repo.ListFairs(models.FairFilter{Sort: sort, Order: order, Limit: len(fixtures) + 1}, day)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next != nil || len(all) != len(fixtures) {
+				t.Fatalf("%s %s: una sola página devolvió %d ferias y cursor %v", sort, order, len(all), next)
+			}
+			checkFairOrder(t, sort, order, all)
+
+			for _, limit := range []int{1, 2, 3, 4} {
+				t.Run(fmt.Sprintf("%s_%s_%d", sort, order, limit), func(t *testing.T) {
+					var paged []models.Fair
+					var cursor *models.FairCursor
+					for pages := 0; ; pages++ {
+						if pages > len(fixtures) {
+							t.Fatal("la paginación no termina")
+						}
+						page, next, err := repo.ListFairs(models.FairFilter{Sort: sort, Order: order, Cursor: cursor, Limit: limit}, day)
+						if err != nil {
+							t.Fatal(err)
+						}
+						if len(page) > limit {
+							t.Fatalf("la página tiene %d ferias, el límite es %d", len(page), limit)
+						}
+						paged = append(paged, page...)
+						if next == nil {
+							break
+						}
+						cursor = next
+					}
+
+					if len(paged) != len(all) {
+						t.Fatalf("se recorrieron %d ferias, se esperaban %d", len(paged), len(all))
+					}
+					for i := range all {
+						if paged[i].ID != all[i].ID {
+							t.Fatalf("posición %d: feria %d, se esperaba %d (páginas %v, completo %v)", i, paged[i].ID, all[i].ID, fairIDs(paged), fairIDs(all))
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// checkFairOrder verifica el orden de un listado completo: el criterio pedido
+// y, en los empates, id_feria en el mismo sentido. El título lo compara MySQL
+// con su intercalación, así que aquí solo se revisa el desempate de títulos
+// idénticos.
+func checkFairOrder(t *testing.T, sort, order string, fairs []models.Fair) {
+	t.Helper()
+	desc := order == "desc"
+	for i := 1; i < len(fairs); i++ {
+		prev, cur := fairs[i-1], fairs[i]
+		tie := true
+		switch sort {
+		case models.FairSortStart:
+			// Las ferias sin fecha van al principio en orden ascendente
+			if !prev.FechaInicio.Equal(cur.FechaInicio) {
+				tie = false
+				if prev.FechaInicio.After(cur.FechaInicio) != desc {
+					t.Errorf("%s %s: la feria %d va antes que la %d", sort, order, prev.ID, cur.ID)
+				}
+			}
+		case models.FairSortTitle:
+			tie = prev.Titulo == cur.Titulo
+		}
+		if tie && (prev.ID > cur.ID) != desc {
+			t.Errorf("%s %s: empate entre %d y %d desordenado", sort, order, prev.ID, cur.ID)
+		}
+	}
+}
+
+func fairIDs(fairs []models.Fair) []int {
+	ids := make([]int, len(fairs))
+	for i, fair := range fairs {
+		ids[i] = fair.ID
+	}
+	return ids
+}
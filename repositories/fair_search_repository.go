@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"dbconnection/models"
+	"dbconnection/utils"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// maxSearchScan es el máximo de ferias que revisa la búsqueda sin FULLTEXT
+const maxSearchScan = 2000
+
+// errNoFullTextIndex es el código de MySQL cuando falta el índice FULLTEXT
+const errNoFullTextIndex = 1191
+
+// SearchFairs busca los términos (normalizados con utils.SearchTerms) en el
+// título y la descripción de las ferias que cumplen el filtro. Devuelve hasta
+// limit resultados, del más al menos relevante, y el total de coincidencias.
+func (repo *FairRepository) SearchFairs(filter models.FairFilter, terms []string, limit int, now time.Time) ([]models.FairSearchResult, int, error) {
+	if repo.FullTextSearch {
+		results, total, err := repo.searchFullText(filter, terms, limit, now)
+		var mysqlErr *mysql.MySQLError
+		if !errors.As(err, &mysqlErr) || mysqlErr.Number != errNoFullTextIndex {
+			return results, total, err
+		}
+		log.Printf("No existe el índice FULLTEXT de feria; se usa la búsqueda sin índice")
+	}
+	return repo.searchByScan(filter, terms, limit, now)
+}
+
+// searchFullText usa MATCH ... AGAINST en modo booleano; cada término se busca
+// también como prefijo ("tecno" encuentra "tecnología"). Que no distinga
+// acentos depende de la intercalación de las columnas.
+func (repo *FairRepository) searchFullText(filter models.FairFilter, terms []string, limit int, now time.Time) ([]models.FairSearchResult, int, error) {
+	// Los términos solo tienen letras y números, así que no traen operadores
+	words := make([]string, len(terms))
+	for i, term := range terms {
+		words[i] = term + "*"
+	}
+	against := strings.Join(words, " ")
+	match := "MATCH(titulo, descripcion) AGAINST (? IN BOOLEAN MODE)"
+
+	filter.Texto = ""
+	conditions, args := fairConditions(filter, now)
+	conditions = append(conditions, match)
+	args = append(args, against)
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := repo.DB.QueryRow("SELECT COUNT(*) FROM feria"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + fairColumns + ", " + match + " AS relevancia FROM feria" + where +
+		" ORDER BY relevancia DESC, id_feria LIMIT ?"
+	rows, err := repo.DB.Query(query, append(append([]interface{}{against}, args...), limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := []models.FairSearchResult{}
+	for rows.Next() {
+		var relevance float64
+		fair, err := scanFair(rows, &relevance)
+		if err != nil {
+			log.Printf("Error al escanear la feria: %v", err)
+			return nil, 0, err
+		}
+		results = append(results, models.FairSearchResult{Feria: *fair, Relevancia: relevance})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+// searchByScan es la búsqueda portable: lee las ferias que cumplen el filtro
+// (hasta maxSearchScan, las más recientes) y compara el texto sin acentos en Go,
+// por prefijo de palabra como FULLTEXT. Cada coincidencia en el título vale el
+// doble que en la descripción.
+func (repo *FairRepository) searchByScan(filter models.FairFilter, terms []string, limit int, now time.Time) ([]models.FairSearchResult, int, error) {
+	filter.Texto = ""
+	conditions, args := fairConditions(filter, now)
+	query := "SELECT " + fairColumns + " FROM feria"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id_feria DESC LIMIT ?"
+
+	rows, err := repo.DB.Query(query, append(args, maxSearchScan)...)
+	if err != nil {
+		log.Printf("Error al buscar las ferias: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := []models.FairSearchResult{}
+	for rows.Next() {
+		fair, err := scanFair(rows)
+		if err != nil {
+			log.Printf("Error al escanear la feria: %v", err)
+			return nil, 0, err
+		}
+		relevance := 0
+		for _, term := range terms {
+			relevance += 2*utils.CountMatches(fair.Titulo, term) + utils.CountMatches(fair.Descripcion, term)
+		}
+		if relevance > 0 {
+			results = append(results, models.FairSearchResult{Feria: *fair, Relevancia: float64(relevance)})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Relevancia != results[j].Relevancia {
+			return results[i].Relevancia > results[j].Relevancia
+		}
+		return results[i].Feria.ID < results[j].Feria.ID
+	})
+	total := len(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, total, nil
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"database/sql"
+	"dbconnection/models"
+	"log"
+	"time"
+)
+
+type LoginThrottleRepository struct {
+	DB *sql.DB
+}
+
+// GetThrottle devuelve el estado de una clave; sql.ErrNoRows si no tiene fallos registrados
+func (repo *LoginThrottleRepository) GetThrottle(clave string) (*models.LoginThrottle, error) {
+	throttle := &models.LoginThrottle{}
+	query := "SELECT clave, fallos, ultimo_fallo, bloqueado_hasta FROM login_throttle WHERE clave = ?"
+	err := repo.DB.QueryRow(query, clave).Scan(&throttle.Clave, &throttle.Fallos, &throttle.UltimoFallo, &throttle.BloqueadoHasta)
+	if err != nil {
+		return nil, err
+	}
+	return throttle, nil
+}
+
+// RecordFailure suma un fallo a la clave de forma atómica y devuelve el total.
+// Si el último fallo es anterior a windowStart el contador vuelve a empezar.
+func (repo *LoginThrottleRepository) RecordFailure(clave string, now, windowStart time.Time) (int, error) {
+	_, err := repo.DB.Exec(`INSERT INTO login_throttle (clave, fallos, ultimo_fallo) VALUES (?, 1, ?)
+		ON DUPLICATE KEY UPDATE fallos = IF(ultimo_fallo < ?, 1, fallos + 1), ultimo_fallo = VALUES(ultimo_fallo)`,
+		clave, now, windowStart)
+	if err != nil {
+		log.Printf("Error al registrar el intento fallido de %s: %v", clave, err)
+		return 0, err
+	}
+
+	var fallos int
+	if err := repo.DB.QueryRow("SELECT fallos FROM login_throttle WHERE clave = ?", clave).Scan(&fallos); err != nil {
+		return 0, err
+	}
+	return fallos, nil
+}
+
+// LockUntil bloquea la clave hasta el momento indicado
+func (repo *LoginThrottleRepository) LockUntil(clave string, until time.Time) error {
+	_, err := repo.DB.Exec("UPDATE login_throttle SET bloqueado_hasta = ? WHERE clave = ?", until, clave)
+	if err != nil {
+		log.Printf("Error al bloquear %s: %v", clave, err)
+		return err
+	}
+	return nil
+}
+
+// Reset elimina los fallos y el bloqueo de la clave
+func (repo *LoginThrottleRepository) Reset(clave string) error {
+	_, err := repo.DB.Exec("DELETE FROM login_throttle WHERE clave = ?", clave)
+	if err != nil {
+		log.Printf("Error al desbloquear %s: %v", clave, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+type MagicLinkRepository struct {
+	DB *sql.DB
+}
+
+// CreateToken guarda un enlace de inicio de sesión enviado a email e invalida
+// los anteriores que el usuario no llegó a usar
+func (repo *MagicLinkRepository) CreateToken(userID int, email, tokenHash, navegadorHash string, expiraEn time.Time) error {
+	now := time.Now().UTC()
+	if _, err := repo.DB.Exec("UPDATE magic_link_token SET usado_en = ? WHERE id_usuario = ? AND usado_en IS NULL", now, userID); err != nil {
+		log.Printf("Error al invalidar los enlaces de inicio de sesión anteriores: %v", err)
+		return err
+	}
+
+	_, err := repo.DB.Exec("INSERT INTO magic_link_token (id_usuario, email, token_hash, navegador_hash, expira_en, creado_en) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, email, tokenHash, navegadorHash, expiraEn, now)
+	if err != nil {
+		log.Printf("Error al ejecutar INSERT en magic_link_token: %v", err)
+		return err
+	}
+	return nil
+}
+
+// LastCreatedAt devuelve cuándo se generó el último enlace del usuario
+func (repo *MagicLinkRepository) LastCreatedAt(userID int) (sql.NullTime, error) {
+	var createdAt sql.NullTime
+	err := repo.DB.QueryRow("SELECT MAX(creado_en) FROM magic_link_token WHERE id_usuario = ?", userID).Scan(&createdAt)
+	return createdAt, err
+}
+
+// ConsumeToken marca el enlace como usado y devuelve el usuario al que
+// pertenece y el correo al que se envió. Solo se consume si lo presenta el
+// mismo navegador que lo pidió, de modo que abrirlo en otro navegador no lo
+// invalida; devuelve sql.ErrNoRows si el enlace no existe, expiró, ya se usó o
+// el navegador no coincide.
+func (repo *MagicLinkRepository) ConsumeToken(tokenHash, navegadorHash string) (userID int, email string, err error) {
+	now := time.Now().UTC()
+	result, err := repo.DB.Exec("UPDATE magic_link_token SET usado_en = ? WHERE token_hash = ? AND navegador_hash = ? AND usado_en IS NULL AND expira_en > ?",
+		now, tokenHash, navegadorHash, now)
+	if err != nil {
+		log.Printf("Error al consumir el enlace de inicio de sesión: %v", err)
+		return 0, "", err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, "", err
+	}
+	if rows == 0 {
+		return 0, "", sql.ErrNoRows
+	}
+
+	err = repo.DB.QueryRow("SELECT id_usuario, email FROM magic_link_token WHERE token_hash = ?", tokenHash).Scan(&userID, &email)
+	if err != nil {
+		return 0, "", err
+	}
+	return userID, email, nil
+}
+
+// DeleteUnusedTokens borra los enlaces del usuario que todavía no se usaron;
+// se llama cuando cambia su correo
+func (repo *MagicLinkRepository) DeleteUnusedTokens(userID int) error {
+	if _, err := repo.DB.Exec("DELETE FROM magic_link_token WHERE id_usuario = ? AND usado_en IS NULL", userID); err != nil {
+		log.Printf("Error al borrar los enlaces de inicio de sesión del usuario %d: %v", userID, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"database/sql"
+	"dbconnection/auth"
+	"dbconnection/db/dbtest"
+	"testing"
+	"time"
+)
+
+func TestMagicLinkTokenRecipient(t *testing.T) {
+	database := dbtest.Open(t)
+	repo := &MagicLinkRepository{DB: database}
+	userID := dbtest.CreateUser(t, database, "visitante", auth.RoleVisitor)
+	expires := time.Now().UTC().Add(time.Hour)
+
+	if err := repo.CreateToken(userID, "anterior@example.com", "token-1", "navegador", expires); err != nil {
+		t.Fatal(err)
+	}
+	gotUser, gotEmail, err := repo.ConsumeToken("token-1", "navegador")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotUser != userID || gotEmail != "anterior@example.com" {
+		t.Errorf("ConsumeToken = (%d, %q), se esperaba (%d, %q)", gotUser, gotEmail, userID, "anterior@example.com")
+	}
+
+	// Al cambiar el correo se borran los enlaces pendientes
+	if err := repo.CreateToken(userID, "anterior@example.com", "token-2", "navegador", expires); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteUnusedTokens(userID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := repo.ConsumeToken("token-2", "navegador"); err != sql.ErrNoRows {
+		t.Errorf("enlace borrado: err = %v, se esperaba sql.ErrNoRows", err)
+	}
+}
+
+func TestSetEmailVerifiedRemembersPastVerification(t *testing.T) {
+	database := dbtest.Open(t)
+	repo := &UserRepository{DB: database}
+	userID := dbtest.CreateUser(t, database, "visitante", auth.RoleVisitor)
+
+	steps := []struct {
+		verified     bool
+		wantVerified bool
+		wantEver     bool
+	}{
+		{true, true, true},
+		{false, false, true}, // Cambió de correo: hay que verificarlo otra vez
+		{true, true, true},
+	}
+	for i, step := range steps {
+		if err := repo.SetEmailVerified(userID, step.verified); err != nil {
+			t.Fatal(err)
+		}
+		user, err := repo.GetUserByID(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.EmailVerificado != step.wantVerified || user.VerificadoAlgunaVez != step.wantEver {
+			t.Errorf("paso %d: verificado = %v, alguna vez = %v; se esperaba %v, %v",
+				i+1, user.EmailVerificado, user.VerificadoAlgunaVez, step.wantVerified, step.wantEver)
+		}
+	}
+}
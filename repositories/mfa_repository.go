@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"database/sql"
+	"dbconnection/models"
+	"log"
+	"time"
+)
+
+type MFARepository struct {
+	DB *sql.DB
+}
+
+// GetMFA devuelve la configuración TOTP del usuario; sql.ErrNoRows si no tiene
+func (repo *MFARepository) GetMFA(userID int) (*models.MFA, error) {
+	mfa := &models.MFA{}
+	query := "SELECT id_usuario, secreto, confirmado, ultimo_paso FROM usuario_mfa WHERE id_usuario = ?"
+	err := repo.DB.QueryRow(query, userID).Scan(&mfa.IdUsuario, &mfa.Secreto, &mfa.Confirmado, &mfa.UltimoPaso)
+	if err != nil {
+		return nil, err
+	}
+	return mfa, nil
+}
+
+// SavePendingSecret guarda un secreto sin confirmar, reemplazando uno anterior sin confirmar
+func (repo *MFARepository) SavePendingSecret(userID int, secret string) error {
+	_, err := repo.DB.Exec(`INSERT INTO usuario_mfa (id_usuario, secreto, confirmado, ultimo_paso) VALUES (?, ?, FALSE, 0)
+		ON DUPLICATE KEY UPDATE secreto = VALUES(secreto), confirmado = FALSE, ultimo_paso = 0`, userID, secret)
+	if err != nil {
+		log.Printf("Error al guardar el secreto TOTP del usuario %d: %v", userID, err)
+		return err
+	}
+	return nil
+}
+
+// Confirm activa el segundo factor y reemplaza los códigos de recuperación
+func (repo *MFARepository) Confirm(userID int, step int64, recoveryHashes []string) error {
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE usuario_mfa SET confirmado = TRUE, ultimo_paso = ? WHERE id_usuario = ?", step, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM codigo_recuperacion WHERE id_usuario = ?", userID); err != nil {
+		return err
+	}
+	for _, hash := range recoveryHashes {
+		if _, err := tx.Exec("INSERT INTO codigo_recuperacion (id_usuario, codigo_hash) VALUES (?, ?)", userID, hash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UpdateLastStep registra el último paso TOTP usado. La condición evita que
+// dos solicitudes simultáneas acepten el mismo código; devuelve false si otro
+// uso ya registró ese paso.
+func (repo *MFARepository) UpdateLastStep(userID int, step int64) (bool, error) {
+	result, err := repo.DB.Exec("UPDATE usuario_mfa SET ultimo_paso = ? WHERE id_usuario = ? AND ultimo_paso < ?", step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+// UseRecoveryCode marca como usado un código de recuperación; devuelve false si no existe o ya se usó
+func (repo *MFARepository) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	result, err := repo.DB.Exec("UPDATE codigo_recuperacion SET usado_en = ? WHERE id_usuario = ? AND codigo_hash = ? AND usado_en IS NULL",
+		time.Now().UTC(), userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+// Delete desactiva el segundo factor y borra los códigos de recuperación
+func (repo *MFARepository) Delete(userID int) error {
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM usuario_mfa WHERE id_usuario = ?", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM codigo_recuperacion WHERE id_usuario = ?", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
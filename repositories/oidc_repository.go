@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"database/sql"
+	"dbconnection/models"
+	"log"
+	"time"
+)
+
+type OIDCRepository struct {
+	DB *sql.DB
+}
+
+// CreateState guarda una solicitud de inicio de sesión y limpia las que expiraron
+func (repo *OIDCRepository) CreateState(state *models.OIDCState) error {
+	if _, err := repo.DB.Exec("DELETE FROM oidc_estado WHERE expira_en < ?", time.Now().UTC()); err != nil {
+		log.Printf("Error al limpiar oidc_estado: %v", err)
+	}
+
+	_, err := repo.DB.Exec("INSERT INTO oidc_estado (state_hash, proveedor, verificador, nonce, expira_en) VALUES (?, ?, ?, ?, ?)",
+		state.StateHash, state.Proveedor, state.Verificador, state.Nonce, state.ExpiraEn)
+	if err != nil {
+		log.Printf("Error al ejecutar INSERT en oidc_estado: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ConsumeState devuelve la solicitud y la borra. Si dos solicitudes llegan con
+// el mismo state solo una logra borrarla; la otra recibe sql.ErrNoRows, igual
+// que si el state no existe o expiró.
+func (repo *OIDCRepository) ConsumeState(stateHash string) (*models.OIDCState, error) {
+	state := &models.OIDCState{}
+	query := "SELECT state_hash, proveedor, verificador, nonce, expira_en FROM oidc_estado WHERE state_hash = ? AND expira_en > ?"
+	err := repo.DB.QueryRow(query, stateHash, time.Now().UTC()).Scan(&state.StateHash, &state.Proveedor, &state.Verificador, &state.Nonce, &state.ExpiraEn)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := repo.DB.Exec("DELETE FROM oidc_estado WHERE state_hash = ?", stateHash)
+	if err != nil {
+		log.Printf("Error al consumir el state OIDC: %v", err)
+		return nil, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return state, nil
+}
+
+// FindIdentity devuelve el usuario vinculado a la identidad externa
+func (repo *OIDCRepository) FindIdentity(proveedor, sujeto string) (int, error) {
+	var userID int
+	err := repo.DB.QueryRow("SELECT id_usuario FROM usuario_identidad WHERE proveedor = ? AND sujeto = ?", proveedor, sujeto).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// LinkIdentity vincula una identidad externa a un usuario
+func (repo *OIDCRepository) LinkIdentity(userID int, proveedor, sujeto, email string) error {
+	_, err := repo.DB.Exec("INSERT INTO usuario_identidad (id_usuario, proveedor, sujeto, email) VALUES (?, ?, ?, ?)",
+		userID, proveedor, sujeto, email)
+	if err != nil {
+		log.Printf("Error al ejecutar INSERT en usuario_identidad: %v", err)
+		return err
+	}
+	return nil
+}
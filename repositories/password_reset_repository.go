@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+type PasswordResetRepository struct {
+	DB *sql.DB
+}
+
+// CreateToken guarda un token de restablecimiento e invalida los anteriores
+// que el usuario no llegó a usar
+func (repo *PasswordResetRepository) CreateToken(userID int, tokenHash string, expiraEn time.Time) error {
+	now := time.Now().UTC()
+	if _, err := repo.DB.Exec("UPDATE password_reset_token SET usado_en = ? WHERE id_usuario = ? AND usado_en IS NULL", now, userID); err != nil {
+		log.Printf("Error al invalidar los tokens de restablecimiento anteriores: %v", err)
+		return err
+	}
+
+	_, err := repo.DB.Exec("INSERT INTO password_reset_token (id_usuario, token_hash, expira_en, creado_en) VALUES (?, ?, ?, ?)", userID, tokenHash, expiraEn, now)
+	if err != nil {
+		log.Printf("Error al ejecutar INSERT en password_reset_token: %v", err)
+		return err
+	}
+	return nil
+}
+
+// LastCreatedAt devuelve cuándo se generó el último token del usuario
+func (repo *PasswordResetRepository) LastCreatedAt(userID int) (sql.NullTime, error) {
+	var createdAt sql.NullTime
+	err := repo.DB.QueryRow("SELECT MAX(creado_en) FROM password_reset_token WHERE id_usuario = ?", userID).Scan(&createdAt)
+	return createdAt, err
+}
+
+// FindValidToken devuelve el usuario de un token que todavía se puede usar, sin
+// consumirlo; devuelve sql.ErrNoRows si no existe, expiró o ya se usó
+func (repo *PasswordResetRepository) FindValidToken(tokenHash string) (int, error) {
+	var userID int
+	err := repo.DB.QueryRow("SELECT id_usuario FROM password_reset_token WHERE token_hash = ? AND usado_en IS NULL AND expira_en > ?", tokenHash, time.Now().UTC()).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// ConsumeToken marca el token como usado y devuelve el usuario al que pertenece.
+// La actualización condicional garantiza que un token solo se pueda usar una
+// vez; devuelve sql.ErrNoRows si el token no existe, expiró o ya se usó.
+func (repo *PasswordResetRepository) ConsumeToken(tokenHash string) (int, error) {
+	now := time.Now().UTC()
+	result, err := repo.DB.Exec("UPDATE password_reset_token SET usado_en = ? WHERE token_hash = ? AND usado_en IS NULL AND expira_en > ?", now, tokenHash, now)
+	if err != nil {
+		log.Printf("Error al consumir el token de restablecimiento: %v", err)
+		return 0, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rows == 0 {
+		return 0, sql.ErrNoRows
+	}
+
+	var userID int
+	err = repo.DB.QueryRow("SELECT id_usuario FROM password_reset_token WHERE token_hash = ?", tokenHash).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
@@ -13,7 +13,7 @@ type PreferenceRepository struct {
 	DB *sql.DB
 }
 
-func (repo *PreferenceRepository) GetPreferencesByUserID(idUsuario int, token string) (*models.Preference, error) {
+func (repo *PreferenceRepository) GetPreferencesByUserID(idUsuario int) (*models.Preference, error) {
 
 	pref := &models.Preference{}
 	query := "SELECT id_pref, linkedinlink, instagramlink, xlink FROM preferenciasusuarios WHERE id_usuario = ?"
@@ -33,7 +33,7 @@ func (repo *PreferenceRepository) GetPreferencesByUserID(idUsuario int, token st
 }
 
 // UpdatePreferences - Actualiza las preferencias de un usuario
-func (repo *PreferenceRepository) UpdatePreferences(pref *models.Preference, token string) (*models.Preference, error) {
+func (repo *PreferenceRepository) UpdatePreferences(pref *models.Preference) (*models.Preference, error) {
 	// Verificar si el id_usuario existe
 	var count int
 	query := "SELECT COUNT(*) FROM usuario WHERE id_usuario = ?"
@@ -66,7 +66,7 @@ func (repo *PreferenceRepository) UpdatePreferences(pref *models.Preference, tok
 	return updatedPref, nil
 }
 
-func (repo *PreferenceRepository) CreatePreferences(pref *models.Preference, token string) (*models.Preference, error) {
+func (repo *PreferenceRepository) CreatePreferences(pref *models.Preference) (*models.Preference, error) {
 	// Verificar si el usuario existe antes de intentar insertar las preferencias
 	query := "SELECT COUNT(*) FROM usuario WHERE id_usuario = ?"
 	var count int
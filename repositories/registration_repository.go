@@ -0,0 +1,352 @@
+package repositories
+
+import (
+	"database/sql"
+	"dbconnection/models"
+	"log"
+	"time"
+)
+
+type RegistrationRepository struct {
+	DB *sql.DB
+}
+
+const registrationColumns = "id_inscripcion, id_feria, id_usuario, estado, creado_en, confirmado_en, entrada_en, codigo_boleto"
+
+func scanRegistration(row rowScanner, extra ...interface{}) (*models.Registration, error) {
+	registration := &models.Registration{}
+	var confirmed, checkedIn sql.NullTime
+	var code sql.NullString
+	dest := []interface{}{&registration.ID, &registration.IdFeria, &registration.IdUsuario, &registration.Estado,
+		&registration.CreadoEn, &confirmed, &checkedIn, &code}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if confirmed.Valid {
+		registration.ConfirmadoEn = &confirmed.Time
+	}
+	if checkedIn.Valid {
+		registration.EntradaEn = &checkedIn.Time
+	}
+	registration.CodigoBoleto = code.String
+	return registration, nil
+}
+
+// lockFair bloquea la fila de la feria hasta el fin de la transacción y
+// devuelve su capacidad. Todas las operaciones que cambian inscripciones pasan
+// por este bloqueo, así dos inscripciones simultáneas no pueden tomar el mismo
+// lugar. Devuelve sql.ErrNoRows si la feria no existe.
+func lockFair(tx *sql.Tx, fairID int) (sql.NullInt64, error) {
+	var capacity sql.NullInt64
+	err := tx.QueryRow("SELECT capacidad FROM feria WHERE id_feria = ? FOR UPDATE", fairID).Scan(&capacity)
+	return capacity, err
+}
+
+// Register inscribe al usuario en la feria: confirmado si queda lugar y en la
+// lista de espera si no. Si ya estaba inscrito devuelve su inscripción con
+// created en false.
+func (repo *RegistrationRepository) Register(fairID, userID int) (registration *models.Registration, created bool, err error) {
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	capacity, err := lockFair(tx, fairID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	existing, err := scanRegistration(tx.QueryRow("SELECT "+registrationColumns+" FROM inscripcion WHERE id_feria = ? AND id_usuario = ?", fairID, userID))
+	if err == nil {
+		return existing, false, tx.Commit()
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, err
+	}
+
+	var confirmed int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM inscripcion WHERE id_feria = ? AND estado = ?", fairID, models.RegistrationConfirmed).Scan(&confirmed); err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now().UTC()
+	registration = &models.Registration{IdFeria: fairID, IdUsuario: userID, Estado: models.RegistrationWaitlist, CreadoEn: now}
+	if !capacity.Valid || int64(confirmed) < capacity.Int64 {
+		registration.Estado = models.RegistrationConfirmed
+		registration.ConfirmadoEn = &now
+	}
+
+	result, err := tx.Exec("INSERT INTO inscripcion (id_feria, id_usuario, estado, creado_en, confirmado_en) VALUES (?, ?, ?, ?, ?)",
+		fairID, userID, registration.Estado, now, registration.ConfirmadoEn)
+	if err != nil {
+		log.Printf("Error al inscribir al usuario %d en la feria %d: %v", userID, fairID, err)
+		return nil, false, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, false, err
+	}
+	registration.ID = int(id)
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return registration, true, nil
+}
+
+// Cancel borra la inscripción del usuario y, si liberó un lugar, confirma en
+// la misma transacción a los primeros de la lista de espera. Devuelve las
+// inscripciones confirmadas; sql.ErrNoRows si el usuario no estaba inscrito.
+func (repo *RegistrationRepository) Cancel(fairID, userID int) ([]models.Registration, error) {
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	capacity, err := lockFair(tx, fairID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec("DELETE FROM inscripcion WHERE id_feria = ? AND id_usuario = ?", fairID, userID)
+	if err != nil {
+		log.Printf("Error al cancelar la inscripción del usuario %d en la feria %d: %v", userID, fairID, err)
+		return nil, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	promoted, err := promoteWaitlist(tx, fairID, capacity)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return promoted, nil
+}
+
+// PromoteWaitlist confirma a los primeros de la lista de espera mientras haya
+// lugar; se usa cuando cambia la capacidad de la feria
+func (repo *RegistrationRepository) PromoteWaitlist(fairID int) ([]models.Registration, error) {
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	capacity, err := lockFair(tx, fairID)
+	if err != nil {
+		return nil, err
+	}
+	promoted, err := promoteWaitlist(tx, fairID, capacity)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return promoted, nil
+}
+
+// promoteWaitlist confirma por orden de llegada a los que caben; la feria ya
+// debe estar bloqueada con lockFair
+func promoteWaitlist(tx *sql.Tx, fairID int, capacity sql.NullInt64) ([]models.Registration, error) {
+	query := "SELECT " + registrationColumns + " FROM inscripcion WHERE id_feria = ? AND estado = ? ORDER BY id_inscripcion"
+	args := []interface{}{fairID, models.RegistrationWaitlist}
+	if capacity.Valid {
+		var confirmed int64
+		if err := tx.QueryRow("SELECT COUNT(*) FROM inscripcion WHERE id_feria = ? AND estado = ?", fairID, models.RegistrationConfirmed).Scan(&confirmed); err != nil {
+			return nil, err
+		}
+		free := capacity.Int64 - confirmed
+		if free <= 0 {
+			return nil, nil
+		}
+		query += " LIMIT ?"
+		args = append(args, free)
+	}
+
+	rows, err := tx.Query(query+" FOR UPDATE", args...)
+	if err != nil {
+		return nil, err
+	}
+	var promoted []models.Registration
+	for rows.Next() {
+		registration, err := scanRegistration(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		promoted = append(promoted, *registration)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	for i := range promoted {
+		if _, err := tx.Exec("UPDATE inscripcion SET estado = ?, confirmado_en = ? WHERE id_inscripcion = ?", models.RegistrationConfirmed, now, promoted[i].ID); err != nil {
+			log.Printf("Error al confirmar la inscripción %d: %v", promoted[i].ID, err)
+			return nil, err
+		}
+		promoted[i].Estado = models.RegistrationConfirmed
+		promoted[i].ConfirmadoEn = &now
+	}
+	return promoted, nil
+}
+
+// GetRegistration devuelve la inscripción del usuario en la feria, con su
+// lugar en la lista de espera si es el caso
+func (repo *RegistrationRepository) GetRegistration(fairID, userID int) (*models.Registration, error) {
+	registration, err := scanRegistration(repo.DB.QueryRow("SELECT "+registrationColumns+" FROM inscripcion WHERE id_feria = ? AND id_usuario = ?", fairID, userID))
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.FillPosition(registration); err != nil {
+		return nil, err
+	}
+	return registration, nil
+}
+
+// FillPosition calcula el lugar de la inscripción en la lista de espera
+func (repo *RegistrationRepository) FillPosition(registration *models.Registration) error {
+	registration.Posicion = 0
+	if registration.Estado != models.RegistrationWaitlist {
+		return nil
+	}
+	return repo.DB.QueryRow("SELECT COUNT(*) FROM inscripcion WHERE id_feria = ? AND estado = ? AND id_inscripcion <= ?",
+		registration.IdFeria, models.RegistrationWaitlist, registration.ID).Scan(&registration.Posicion)
+}
+
+// ListRegistrations devuelve las inscripciones de la feria con el nombre y el
+// correo de cada usuario: primero las confirmadas y después la lista de espera
+// en orden. estado vacío las incluye todas.
+func (repo *RegistrationRepository) ListRegistrations(fairID int, estado string) ([]models.Registration, error) {
+	query := "SELECT i.id_inscripcion, i.id_feria, i.id_usuario, i.estado, i.creado_en, i.confirmado_en, i.entrada_en, i.codigo_boleto, u.nombre, u.email " +
+		"FROM inscripcion i JOIN usuario u ON u.id_usuario = i.id_usuario WHERE i.id_feria = ?"
+	args := []interface{}{fairID}
+	if estado != "" {
+		query += " AND i.estado = ?"
+		args = append(args, estado)
+	}
+	query += " ORDER BY i.estado = ?, i.id_inscripcion"
+	args = append(args, models.RegistrationWaitlist)
+
+	rows, err := repo.DB.Query(query, args...)
+	if err != nil {
+		log.Printf("Error al listar las inscripciones de la feria %d: %v", fairID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	registrations := []models.Registration{}
+	position := 0
+	for rows.Next() {
+		var nombre, email string
+		registration, err := scanRegistration(rows, &nombre, &email)
+		if err != nil {
+			return nil, err
+		}
+		registration.Nombre, registration.Email = nombre, email
+		if registration.Estado == models.RegistrationWaitlist {
+			position++
+			registration.Posicion = position
+		}
+		registrations = append(registrations, *registration)
+	}
+	return registrations, rows.Err()
+}
+
+// CountRegistrations cuenta las inscripciones confirmadas y en espera de la feria
+func (repo *RegistrationRepository) CountRegistrations(fairID int) (confirmed, waitlist int, err error) {
+	rows, err := repo.DB.Query("SELECT estado, COUNT(*) FROM inscripcion WHERE id_feria = ? GROUP BY estado", fairID)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var estado string
+		var count int
+		if err := rows.Scan(&estado, &count); err != nil {
+			return 0, 0, err
+		}
+		switch estado {
+		case models.RegistrationConfirmed:
+			confirmed = count
+		case models.RegistrationWaitlist:
+			waitlist = count
+		}
+	}
+	return confirmed, waitlist, rows.Err()
+}
+
+// GetRegistrationByID devuelve una inscripción por su ID
+func (repo *RegistrationRepository) GetRegistrationByID(id int) (*models.Registration, error) {
+	return scanRegistration(repo.DB.QueryRow("SELECT "+registrationColumns+" FROM inscripcion WHERE id_inscripcion = ?", id))
+}
+
+// EnsureTicketCode guarda el código del boleto de la inscripción si todavía no
+// tiene uno y devuelve el código vigente
+func (repo *RegistrationRepository) EnsureTicketCode(id int, code string) (string, error) {
+	if _, err := repo.DB.Exec("UPDATE inscripcion SET codigo_boleto = ? WHERE id_inscripcion = ? AND codigo_boleto IS NULL", code, id); err != nil {
+		log.Printf("Error al guardar el código del boleto de la inscripción %d: %v", id, err)
+		return "", err
+	}
+	var current sql.NullString
+	if err := repo.DB.QueryRow("SELECT codigo_boleto FROM inscripcion WHERE id_inscripcion = ?", id).Scan(&current); err != nil {
+		return "", err
+	}
+	return current.String, nil
+}
+
+// CheckIn registra la entrada de la inscripción con el código del boleto. La
+// fila queda bloqueada durante la transacción, así un boleto leído dos veces al
+// mismo tiempo solo entra una vez. Si la inscripción no se pudo marcar (ya había
+// entrado o no está confirmada) la devuelve sin cambios con checkedIn en false;
+// sql.ErrNoRows si no existe en esa feria con ese código.
+func (repo *RegistrationRepository) CheckIn(fairID, id int, code string) (registration *models.Registration, checkedIn bool, err error) {
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	registration, err = scanRegistration(tx.QueryRow("SELECT "+registrationColumns+" FROM inscripcion WHERE id_inscripcion = ? AND id_feria = ? FOR UPDATE", id, fairID))
+	if err != nil {
+		return nil, false, err
+	}
+	if registration.CodigoBoleto == "" || registration.CodigoBoleto != code {
+		return nil, false, sql.ErrNoRows
+	}
+	if registration.EntradaEn != nil || registration.Estado != models.RegistrationConfirmed {
+		return registration, false, nil
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec("UPDATE inscripcion SET entrada_en = ? WHERE id_inscripcion = ?", now, id); err != nil {
+		log.Printf("Error al registrar la entrada de la inscripción %d: %v", id, err)
+		return nil, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	registration.EntradaEn = &now
+	return registration, true, nil
+}
+
+// CountAttendance cuenta las inscripciones confirmadas de la feria y cuántas ya entraron
+func (repo *RegistrationRepository) CountAttendance(fairID int) (confirmed, present int, err error) {
+	err = repo.DB.QueryRow("SELECT COUNT(*), COUNT(entrada_en) FROM inscripcion WHERE id_feria = ? AND estado = ?",
+		fairID, models.RegistrationConfirmed).Scan(&confirmed, &present)
+	return confirmed, present, err
+}
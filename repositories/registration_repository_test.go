@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"database/sql"
+	"dbconnection/auth"
+	"dbconnection/db/dbtest"
+	"dbconnection/models"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createFair crea una feria del organizador con la capacidad indicada (nil sin límite)
+func createFair(t *testing.T, database *sql.DB, ownerID int, title string, start time.Time, capacity *int) int {
+	t.Helper()
+	fair, err := (&FairRepository{DB: database}).CreateFair(&models.Fair{
+		Titulo:      title,
+		Descripcion: title,
+		FechaInicio: start,
+		FechaFin:    start.Add(8 * time.Hour),
+		ZonaHoraria: "UTC",
+		Capacidad:   capacity,
+		IdUsuario:   ownerID,
+	})
+	if err != nil {
+		t.Fatalf("Error al crear la feria %q: %v", title, err)
+	}
+	return fair.ID
+}
+
+func TestRegisterConcurrentRespectsCapacity(t *testing.T) {
+	database := dbtest.Open(t)
+	database.SetMaxOpenConns(20)
+	repo := &RegistrationRepository{DB: database}
+
+	const capacity = 5
+	const visitors = 20
+	limit := capacity
+	owner := dbtest.CreateUser(t, database, "organizador", auth.RoleOrganizer)
+	fairID := createFair(t, database, owner, "Feria con cupo", time.Now().Add(24*time.Hour), &limit)
+
+	userIDs := make([]int, visitors)
+	for i := range userIDs {
+		userIDs[i] = dbtest.CreateUser(t, database, fmt.Sprintf("visitante%d", i), auth.RoleVisitor)
+	}
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, visitors)
+	for _, userID := range userIDs {
+		wg.Add(1)
+		go func(userID int) {
+			defer wg.Done()
+			<-start
+			if _, _, err := repo.Register(fairID, userID); err != nil {
+				errs <- fmt.Errorf("usuario %d: %v", userID, err)
+			}
+		}(userID)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	confirmed, waitlist, err := repo.CountRegistrations(fairID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed != capacity {
+		t.Errorf("confirmadas = %d, se esperaban %d", confirmed, capacity)
+	}
+	if waitlist != visitors-capacity {
+		t.Errorf("en espera = %d, se esperaban %d", waitlist, visitors-capacity)
+	}
+}
+
+func TestCancelPromotesFirstInWaitlist(t *testing.T) {
+	database := dbtest.Open(t)
+	repo := &RegistrationRepository{DB: database}
+
+	limit := 1
+	owner := dbtest.CreateUser(t, database, "organizador", auth.RoleOrganizer)
+	fairID := createFair(t, database, owner, "Feria de un lugar", time.Now().Add(24*time.Hour), &limit)
+	first := dbtest.CreateUser(t, database, "primero", auth.RoleVisitor)
+	second := dbtest.CreateUser(t, database, "segundo", auth.RoleVisitor)
+	third := dbtest.CreateUser(t, database, "tercero", auth.RoleVisitor)
+
+	for _, userID := range []int{first, second, third} {
+		if _, _, err := repo.Register(fairID, userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	registration, created, err := repo.Register(fairID, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || registration.Estado != models.RegistrationWaitlist {
+		t.Fatalf("repetir la inscripción: created = %v, estado = %s", created, registration.Estado)
+	}
+
+	promoted, err := repo.Cancel(fairID, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(promoted) != 1 || promoted[0].IdUsuario != second {
+		t.Fatalf("confirmadas al cancelar = %+v, se esperaba el usuario %d", promoted, second)
+	}
+
+	registration, err = repo.GetRegistration(fairID, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registration.Estado != models.RegistrationConfirmed || registration.ConfirmadoEn == nil {
+		t.Errorf("el segundo quedó en %s, se esperaba confirmado", registration.Estado)
+	}
+
+	registration, err = repo.GetRegistration(fairID, third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registration.Estado != models.RegistrationWaitlist || registration.Posicion != 1 {
+		t.Errorf("el tercero quedó en %s posición %d, se esperaba el primer lugar de la espera", registration.Estado, registration.Posicion)
+	}
+
+	if _, err := repo.Cancel(fairID, first); err != sql.ErrNoRows {
+		t.Errorf("cancelar dos veces: err = %v, se esperaba sql.ErrNoRows", err)
+	}
+}
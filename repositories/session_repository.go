@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"database/sql"
+	"dbconnection/models"
+	"log"
+	"time"
+)
+
+type SessionRepository struct {
+	DB *sql.DB
+}
+
+// activeSessionCondition indica si la sesión todavía tiene un token de
+// actualización que se pueda usar
+const activeSessionCondition = "EXISTS (SELECT 1 FROM refresh_token t WHERE t.familia = s.familia AND t.revocado_en IS NULL AND t.usado_en IS NULL AND t.expira_en > ?)"
+
+const sessionColumns = "s.familia, s.id_usuario, s.ip, s.user_agent, s.dispositivo_nuevo, s.creado_en, s.ultimo_uso_en, " + activeSessionCondition
+
+// CreateSession registra un inicio de sesión
+func (repo *SessionRepository) CreateSession(session *models.Session) error {
+	_, err := repo.DB.Exec("INSERT INTO sesion (familia, id_usuario, ip, user_agent, dispositivo_nuevo, creado_en, ultimo_uso_en) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		session.ID, session.IdUsuario, session.IP, session.UserAgent, session.DispositivoNuevo, session.CreadoEn, session.UltimoUsoEn)
+	if err != nil {
+		log.Printf("Error al ejecutar INSERT en sesion: %v", err)
+		return err
+	}
+	return nil
+}
+
+// HasLoggedIn indica si el usuario ya había iniciado sesión alguna vez y si lo
+// hizo desde el mismo user agent
+func (repo *SessionRepository) HasLoggedIn(userID int, userAgent string) (hasHistory, sameDevice bool, err error) {
+	var total, same int
+	err = repo.DB.QueryRow("SELECT COUNT(*), COALESCE(SUM(user_agent = ?), 0) FROM sesion WHERE id_usuario = ?", userAgent, userID).Scan(&total, &same)
+	if err != nil {
+		return false, false, err
+	}
+	return total > 0, same > 0, nil
+}
+
+// TouchSession actualiza el último uso de la sesión al rotar sus tokens
+func (repo *SessionRepository) TouchSession(familia string) error {
+	_, err := repo.DB.Exec("UPDATE sesion SET ultimo_uso_en = ? WHERE familia = ?", time.Now().UTC(), familia)
+	if err != nil {
+		log.Printf("Error al actualizar la sesión %s: %v", familia, err)
+	}
+	return err
+}
+
+// GetSession busca una sesión por su familia
+func (repo *SessionRepository) GetSession(familia string) (*models.Session, error) {
+	row := repo.DB.QueryRow("SELECT "+sessionColumns+" FROM sesion s WHERE s.familia = ?", time.Now().UTC(), familia)
+	return scanSession(row)
+}
+
+// ListActiveSessions devuelve las sesiones del usuario que siguen abiertas
+func (repo *SessionRepository) ListActiveSessions(userID int) ([]models.Session, error) {
+	now := time.Now().UTC()
+	return repo.querySessions("SELECT "+sessionColumns+" FROM sesion s WHERE s.id_usuario = ? AND "+activeSessionCondition+" ORDER BY s.ultimo_uso_en DESC",
+		now, userID, now)
+}
+
+// ListHistory devuelve los últimos inicios de sesión del usuario, del más reciente al más antiguo
+func (repo *SessionRepository) ListHistory(userID, limit int) ([]models.Session, error) {
+	return repo.querySessions("SELECT "+sessionColumns+" FROM sesion s WHERE s.id_usuario = ? ORDER BY s.creado_en DESC LIMIT ?",
+		time.Now().UTC(), userID, limit)
+}
+
+func (repo *SessionRepository) querySessions(query string, args ...interface{}) ([]models.Session, error) {
+	rows, err := repo.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, rows.Err()
+}
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	session := &models.Session{}
+	err := row.Scan(&session.ID, &session.IdUsuario, &session.IP, &session.UserAgent, &session.DispositivoNuevo,
+		&session.CreadoEn, &session.UltimoUsoEn, &session.Activa)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
@@ -0,0 +1,269 @@
+package repositories
+
+import (
+	"database/sql"
+	"dbconnection/models"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrStandCodeTaken indica que la feria ya tiene un stand con ese código
+var ErrStandCodeTaken = errors.New("el código del stand ya existe en la feria")
+
+// errDuplicateEntry es el código de MySQL al violar un índice UNIQUE
+const errDuplicateEntry = 1062
+
+type StandRepository struct {
+	DB *sql.DB
+}
+
+// ListStands devuelve los stands de la feria ordenados por código, con sus expositores
+func (repo *StandRepository) ListStands(fairID int) ([]models.Stand, error) {
+	rows, err := repo.DB.Query("SELECT id_stand, id_feria, codigo, ubicacion, creado_en FROM stand WHERE id_feria = ? ORDER BY codigo", fairID)
+	if err != nil {
+		log.Printf("Error al listar los stands de la feria %d: %v", fairID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	stands := []models.Stand{}
+	index := map[int]int{}
+	for rows.Next() {
+		stand := models.Stand{Expositores: []models.StandExhibitor{}}
+		if err := rows.Scan(&stand.ID, &stand.IdFeria, &stand.Codigo, &stand.Ubicacion, &stand.CreadoEn); err != nil {
+			return nil, err
+		}
+		index[stand.ID] = len(stands)
+		stands = append(stands, stand)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	exhibitors, err := repo.DB.Query("SELECT se.id_stand, u.id_usuario, u.nombre FROM stand_expositor se "+
+		"JOIN stand s ON s.id_stand = se.id_stand JOIN usuario u ON u.id_usuario = se.id_usuario "+
+		"WHERE s.id_feria = ? ORDER BY u.nombre, u.id_usuario", fairID)
+	if err != nil {
+		return nil, err
+	}
+	defer exhibitors.Close()
+	for exhibitors.Next() {
+		var standID int
+		var exhibitor models.StandExhibitor
+		if err := exhibitors.Scan(&standID, &exhibitor.IdUsuario, &exhibitor.Nombre); err != nil {
+			return nil, err
+		}
+		if i, ok := index[standID]; ok {
+			stands[i].Expositores = append(stands[i].Expositores, exhibitor)
+		}
+	}
+	return stands, exhibitors.Err()
+}
+
+// GetStand devuelve el stand de la feria con sus expositores; sql.ErrNoRows si
+// no existe o es de otra feria
+func (repo *StandRepository) GetStand(fairID, standID int) (*models.Stand, error) {
+	stand := &models.Stand{Expositores: []models.StandExhibitor{}}
+	err := repo.DB.QueryRow("SELECT id_stand, id_feria, codigo, ubicacion, creado_en FROM stand WHERE id_stand = ? AND id_feria = ?", standID, fairID).
+		Scan(&stand.ID, &stand.IdFeria, &stand.Codigo, &stand.Ubicacion, &stand.CreadoEn)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.DB.Query("SELECT u.id_usuario, u.nombre FROM stand_expositor se JOIN usuario u ON u.id_usuario = se.id_usuario "+
+		"WHERE se.id_stand = ? ORDER BY u.nombre, u.id_usuario", standID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var exhibitor models.StandExhibitor
+		if err := rows.Scan(&exhibitor.IdUsuario, &exhibitor.Nombre); err != nil {
+			return nil, err
+		}
+		stand.Expositores = append(stand.Expositores, exhibitor)
+	}
+	return stand, rows.Err()
+}
+
+// SaveStand crea el stand (si stand.ID es 0) o lo actualiza, y reemplaza sus
+// expositores por userIDs. Si algún expositor ya está en otro stand de la misma
+// feria o de una feria con fechas que se cruzan, no guarda nada y devuelve esas
+// asignaciones. Las filas de los expositores quedan bloqueadas durante la
+// transacción, así dos asignaciones simultáneas del mismo usuario no pueden
+// cruzarse. Devuelve sql.ErrNoRows si la feria o el stand no existen y
+// ErrStandCodeTaken si el código está repetido.
+func (repo *StandRepository) SaveStand(stand *models.Stand, userIDs []int) ([]models.StandConflict, error) {
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// La feria se bloquea antes que los expositores, en el mismo orden que
+	// FairRepository.UpdateFair, para que un cambio de fechas simultáneo no se
+	// salte la revisión
+	var start, end sql.NullTime
+	if err := tx.QueryRow("SELECT fecha_inicio, fecha_fin FROM feria WHERE id_feria = ? FOR UPDATE", stand.IdFeria).Scan(&start, &end); err != nil {
+		return nil, err
+	}
+	if stand.ID != 0 {
+		var id int
+		if err := tx.QueryRow("SELECT id_stand FROM stand WHERE id_stand = ? AND id_feria = ? FOR UPDATE", stand.ID, stand.IdFeria).Scan(&id); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(userIDs) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(userIDs)), ", ")
+		ids := make([]interface{}, len(userIDs))
+		for i, id := range userIDs {
+			ids[i] = id
+		}
+
+		locked, err := tx.Query("SELECT id_usuario FROM usuario WHERE id_usuario IN ("+placeholders+") FOR UPDATE", ids...)
+		if err != nil {
+			return nil, err
+		}
+		locked.Close()
+
+		conflicts, err := findStandConflicts(tx, stand, start, end, placeholders, ids)
+		if err != nil || len(conflicts) > 0 {
+			return conflicts, err
+		}
+	}
+
+	if stand.ID == 0 {
+		stand.CreadoEn = time.Now().UTC()
+		result, err := tx.Exec("INSERT INTO stand (id_feria, codigo, ubicacion, creado_en) VALUES (?, ?, ?, ?)",
+			stand.IdFeria, stand.Codigo, stand.Ubicacion, stand.CreadoEn)
+		if err != nil {
+			return nil, standWriteError(err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		stand.ID = int(id)
+	} else {
+		if _, err := tx.Exec("UPDATE stand SET codigo = ?, ubicacion = ? WHERE id_stand = ?", stand.Codigo, stand.Ubicacion, stand.ID); err != nil {
+			return nil, standWriteError(err)
+		}
+		if _, err := tx.Exec("DELETE FROM stand_expositor WHERE id_stand = ?", stand.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, userID := range userIDs {
+		if _, err := tx.Exec("INSERT INTO stand_expositor (id_stand, id_usuario) VALUES (?, ?)", stand.ID, userID); err != nil {
+			log.Printf("Error al asignar al usuario %d al stand %d: %v", userID, stand.ID, err)
+			return nil, err
+		}
+	}
+	return nil, tx.Commit()
+}
+
+// findStandConflicts busca otros stands de los usuarios en la misma feria o en
+// ferias cuyas fechas se cruzan con las de la feria del stand
+func findStandConflicts(tx *sql.Tx, stand *models.Stand, start, end sql.NullTime, placeholders string, ids []interface{}) ([]models.StandConflict, error) {
+	query := "SELECT se.id_usuario, s.id_stand, s.codigo, f.id_feria, f.titulo FROM stand_expositor se " +
+		"JOIN stand s ON s.id_stand = se.id_stand JOIN feria f ON f.id_feria = s.id_feria " +
+		"WHERE se.id_usuario IN (" + placeholders + ") AND s.id_stand <> ? AND (f.id_feria = ?"
+	args := append(append([]interface{}{}, ids...), stand.ID, stand.IdFeria)
+	if start.Valid && end.Valid {
+		query += " OR (f.fecha_inicio < ? AND f.fecha_fin > ?)"
+		args = append(args, end.Time, start.Time)
+	}
+	query += ") ORDER BY se.id_usuario, f.id_feria, s.codigo"
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conflicts []models.StandConflict
+	for rows.Next() {
+		var conflict models.StandConflict
+		if err := rows.Scan(&conflict.IdUsuario, &conflict.IdStand, &conflict.Codigo, &conflict.IdFeria, &conflict.Titulo); err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts, rows.Err()
+}
+
+// lockFairExhibitors bloquea las filas de los usuarios asignados a los stands de la feria
+func lockFairExhibitors(tx *sql.Tx, fairID int) error {
+	rows, err := tx.Query("SELECT u.id_usuario FROM usuario u JOIN stand_expositor se ON se.id_usuario = u.id_usuario "+
+		"JOIN stand s ON s.id_stand = se.id_stand WHERE s.id_feria = ? FOR UPDATE", fairID)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// findFairStandConflicts busca expositores de la feria que también están en
+// stands de otras ferias cuyas fechas se cruzan con start y end
+func findFairStandConflicts(tx *sql.Tx, fairID int, start, end time.Time) ([]models.StandConflict, error) {
+	rows, err := tx.Query("SELECT DISTINCT se.id_usuario, s.id_stand, s.codigo, f.id_feria, f.titulo FROM stand_expositor mine "+
+		"JOIN stand ms ON ms.id_stand = mine.id_stand "+
+		"JOIN stand_expositor se ON se.id_usuario = mine.id_usuario "+
+		"JOIN stand s ON s.id_stand = se.id_stand JOIN feria f ON f.id_feria = s.id_feria "+
+		"WHERE ms.id_feria = ? AND s.id_feria <> ? AND f.fecha_inicio < ? AND f.fecha_fin > ? "+
+		"ORDER BY se.id_usuario, f.id_feria, s.codigo", fairID, fairID, end, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conflicts []models.StandConflict
+	for rows.Next() {
+		var conflict models.StandConflict
+		if err := rows.Scan(&conflict.IdUsuario, &conflict.IdStand, &conflict.Codigo, &conflict.IdFeria, &conflict.Titulo); err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts, rows.Err()
+}
+
+// standWriteError traduce el código repetido a ErrStandCodeTaken
+func standWriteError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry {
+		return ErrStandCodeTaken
+	}
+	log.Printf("Error al guardar el stand: %v", err)
+	return err
+}
+
+// DeleteStand borra el stand de la feria y sus asignaciones; sql.ErrNoRows si no existe
+func (repo *StandRepository) DeleteStand(fairID, standID int) error {
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM stand WHERE id_stand = ? AND id_feria = ?", standID, fairID)
+	if err != nil {
+		log.Printf("Error al borrar el stand %d: %v", standID, err)
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("DELETE FROM stand_expositor WHERE id_stand = ?", standID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"database/sql"
+	"dbconnection/models"
+	"errors"
+	"log"
+	"time"
+)
+
+// ErrRefreshTokenReused indica que se presentó un token de actualización que ya
+// había sido rotado; la familia completa queda revocada
+var ErrRefreshTokenReused = errors.New("token de actualización reutilizado")
+
+type TokenRepository struct {
+	DB *sql.DB
+}
+
+// CreateRefreshToken guarda el hash de un nuevo token de actualización
+func (repo *TokenRepository) CreateRefreshToken(userID int, tokenHash, familia string, expiraEn time.Time) error {
+	_, err := repo.DB.Exec("INSERT INTO refresh_token (id_usuario, token_hash, familia, expira_en) VALUES (?, ?, ?, ?)",
+		userID, tokenHash, familia, expiraEn)
+	if err != nil {
+		log.Printf("Error al ejecutar INSERT en refresh_token: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ConsumeRefreshToken marca el token como usado dentro de una transacción, de
+// forma que dos solicitudes simultáneas no puedan rotar el mismo token. Si el
+// token ya se había usado se revoca toda su familia y se devuelve
+// ErrRefreshTokenReused.
+func (repo *TokenRepository) ConsumeRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	token := &models.RefreshToken{}
+	query := "SELECT id_refresh, id_usuario, familia, expira_en, usado_en, revocado_en FROM refresh_token WHERE token_hash = ? FOR UPDATE"
+	err = tx.QueryRow(query, tokenHash).Scan(&token.ID, &token.IdUsuario, &token.Familia, &token.ExpiraEn, &token.UsadoEn, &token.RevocadoEn)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if token.UsadoEn.Valid {
+		if _, err := tx.Exec("UPDATE refresh_token SET revocado_en = ? WHERE familia = ? AND revocado_en IS NULL", now, token.Familia); err != nil {
+			log.Printf("Error al revocar la familia %s: %v", token.Familia, err)
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return token, ErrRefreshTokenReused
+	}
+
+	if _, err := tx.Exec("UPDATE refresh_token SET usado_en = ? WHERE id_refresh = ?", now, token.ID); err != nil {
+		log.Printf("Error al marcar como usado el token de actualización: %v", err)
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// FindRefreshToken busca un token de actualización por su hash
+func (repo *TokenRepository) FindRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	token := &models.RefreshToken{}
+	query := "SELECT id_refresh, id_usuario, familia, expira_en, usado_en, revocado_en FROM refresh_token WHERE token_hash = ?"
+	err := repo.DB.QueryRow(query, tokenHash).Scan(&token.ID, &token.IdUsuario, &token.Familia, &token.ExpiraEn, &token.UsadoEn, &token.RevocadoEn)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// RevokeFamily revoca todos los tokens de actualización de una sesión
+func (repo *TokenRepository) RevokeFamily(familia string) error {
+	_, err := repo.DB.Exec("UPDATE refresh_token SET revocado_en = ? WHERE familia = ? AND revocado_en IS NULL", time.Now().UTC(), familia)
+	if err != nil {
+		log.Printf("Error al revocar la familia %s: %v", familia, err)
+		return err
+	}
+	return nil
+}
+
+// RevokeAllForUser revoca todas las sesiones de un usuario
+func (repo *TokenRepository) RevokeAllForUser(userID int) error {
+	_, err := repo.DB.Exec("UPDATE refresh_token SET revocado_en = ? WHERE id_usuario = ? AND revocado_en IS NULL", time.Now().UTC(), userID)
+	if err != nil {
+		log.Printf("Error al revocar las sesiones del usuario %d: %v", userID, err)
+		return err
+	}
+	return nil
+}
+
+// RevokeAllForUserExcept revoca todas las sesiones del usuario menos la indicada
+func (repo *TokenRepository) RevokeAllForUserExcept(userID int, familia string) error {
+	_, err := repo.DB.Exec("UPDATE refresh_token SET revocado_en = ? WHERE id_usuario = ? AND familia <> ? AND revocado_en IS NULL", time.Now().UTC(), userID, familia)
+	if err != nil {
+		log.Printf("Error al revocar las otras sesiones del usuario %d: %v", userID, err)
+		return err
+	}
+	return nil
+}
+
+// IsFamilyRevoked indica si la sesión fue revocada
+func (repo *TokenRepository) IsFamilyRevoked(familia string) (bool, error) {
+	var count int
+	err := repo.DB.QueryRow("SELECT COUNT(*) FROM refresh_token WHERE familia = ? AND revocado_en IS NOT NULL", familia).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RevokeAccessToken agrega el jti de un token de acceso a la lista de revocados
+// y aprovecha para limpiar los que ya expiraron
+func (repo *TokenRepository) RevokeAccessToken(jti string, expiraEn time.Time) error {
+	now := time.Now().UTC()
+	if _, err := repo.DB.Exec("DELETE FROM token_revocado WHERE expira_en < ?", now); err != nil {
+		log.Printf("Error al limpiar token_revocado: %v", err)
+	}
+
+	_, err := repo.DB.Exec("INSERT IGNORE INTO token_revocado (jti, expira_en) VALUES (?, ?)", jti, expiraEn.UTC())
+	if err != nil {
+		log.Printf("Error al ejecutar INSERT en token_revocado: %v", err)
+		return err
+	}
+	return nil
+}
+
+// IsAccessTokenRevoked indica si el jti está en la lista de revocados
+func (repo *TokenRepository) IsAccessTokenRevoked(jti string) (bool, error) {
+	var count int
+	err := repo.DB.QueryRow("SELECT COUNT(*) FROM token_revocado WHERE jti = ?", jti).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
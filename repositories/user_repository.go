@@ -6,19 +6,23 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 )
 
+// ErrUserNotFound indica que no existe un usuario con el email buscado
+var ErrUserNotFound = errors.New("usuario no encontrado")
+
 type UserRepository struct {
 	DB *sql.DB
 }
 
 func (repo *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 	user := &models.User{}
-	query := "SELECT id_usuario, nombre, ocupacion, email, contraseña, foto_perfil FROM usuario WHERE email = ?"
-	err := repo.DB.QueryRow(query, email).Scan(&user.ID, &user.Nombre, &user.Ocupacion, &user.Email, &user.Password, &user.FotoPerfil)
+	query := "SELECT id_usuario, nombre, ocupacion, email, contraseña, foto_perfil, rol, email_verificado, email_verificado_alguna_vez FROM usuario WHERE email = ?"
+	err := repo.DB.QueryRow(query, email).Scan(&user.ID, &user.Nombre, &user.Ocupacion, &user.Email, &user.Password, &user.FotoPerfil, &user.Rol, &user.EmailVerificado, &user.VerificadoAlgunaVez)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("usuario no encontrado")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("error al ejecutar la consulta: %v", err) // Mejorar el error para obtener detalles
 	}
@@ -43,8 +47,8 @@ func (repo *UserRepository) CreateUser(user *models.User) (*models.User, error)
 
 	// Recuperar el usuario recién creado para devolverlo completo
 	newUser := &models.User{}
-	query := "SELECT id_usuario, nombre, ocupacion, email FROM usuario WHERE id_usuario = ?"
-	err = repo.DB.QueryRow(query, userID).Scan(&newUser.ID, &newUser.Nombre, &newUser.Ocupacion, &newUser.Email)
+	query := "SELECT id_usuario, nombre, ocupacion, email, rol, email_verificado FROM usuario WHERE id_usuario = ?"
+	err = repo.DB.QueryRow(query, userID).Scan(&newUser.ID, &newUser.Nombre, &newUser.Ocupacion, &newUser.Email, &newUser.Rol, &newUser.EmailVerificado)
 	if err != nil {
 		log.Printf("Error al ejecutar SELECT en usuario para recuperar el nuevo usuario: %v", err)
 		return nil, err
@@ -53,10 +57,81 @@ func (repo *UserRepository) CreateUser(user *models.User) (*models.User, error)
 	return newUser, nil
 }
 
+// GetPasswordHash devuelve el valor guardado de la contraseña del usuario
+func (repo *UserRepository) GetPasswordHash(id int) (string, error) {
+	var hash string
+	err := repo.DB.QueryRow("SELECT contraseña FROM usuario WHERE id_usuario = ?", id).Scan(&hash)
+	if err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// UpdatePassword reemplaza el valor guardado de la contraseña por un nuevo hash
+func (repo *UserRepository) UpdatePassword(id int, passwordHash string) error {
+	_, err := repo.DB.Exec("UPDATE usuario SET contraseña = ? WHERE id_usuario = ?", passwordHash, id)
+	if err != nil {
+		log.Printf("Error al actualizar la contraseña del usuario %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// UpdateRole cambia el rol del usuario
+func (repo *UserRepository) UpdateRole(id int, role string) error {
+	result, err := repo.DB.Exec("UPDATE usuario SET rol = ? WHERE id_usuario = ?", role, id)
+	if err != nil {
+		log.Printf("Error al actualizar el rol del usuario %d: %v", id, err)
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		// Sin filas afectadas: el usuario no existe o ya tenía ese rol
+		if _, err := repo.GetUserByID(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetEmailVerified cambia el estado de verificación del correo del usuario.
+// Una vez verificado, email_verificado_alguna_vez queda en verdadero aunque el
+// correo cambie después.
+func (repo *UserRepository) SetEmailVerified(id int, verified bool) error {
+	_, err := repo.DB.Exec("UPDATE usuario SET email_verificado = ?, email_verificado_alguna_vez = email_verificado_alguna_vez OR ? WHERE id_usuario = ?",
+		verified, verified, id)
+	if err != nil {
+		log.Printf("Error al actualizar la verificación del correo del usuario %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// GetVerificationSentAt devuelve cuándo se envió el último correo de verificación
+func (repo *UserRepository) GetVerificationSentAt(id int) (sql.NullTime, error) {
+	var sentAt sql.NullTime
+	err := repo.DB.QueryRow("SELECT verificacion_enviada_en FROM usuario WHERE id_usuario = ?", id).Scan(&sentAt)
+	return sentAt, err
+}
+
+// SetVerificationSentAt registra el envío de un correo de verificación
+func (repo *UserRepository) SetVerificationSentAt(id int, sentAt time.Time) error {
+	_, err := repo.DB.Exec("UPDATE usuario SET verificacion_enviada_en = ? WHERE id_usuario = ?", sentAt, id)
+	if err != nil {
+		log.Printf("Error al registrar el envío de la verificación del usuario %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
 func (repo *UserRepository) GetUserByID(id int) (*models.User, error) {
 	user := &models.User{}
-	query := "SELECT id_usuario, nombre, ocupacion, email, foto_perfil FROM usuario WHERE id_usuario = ?"
-	err := repo.DB.QueryRow(query, id).Scan(&user.ID, &user.Nombre, &user.Ocupacion, &user.Email, &user.FotoPerfil)
+	query := "SELECT id_usuario, nombre, ocupacion, email, foto_perfil, rol, email_verificado, email_verificado_alguna_vez FROM usuario WHERE id_usuario = ?"
+	err := repo.DB.QueryRow(query, id).Scan(&user.ID, &user.Nombre, &user.Ocupacion, &user.Email, &user.FotoPerfil, &user.Rol, &user.EmailVerificado, &user.VerificadoAlgunaVez)
 	if err != nil {
 		return nil, err
 	}
@@ -89,8 +164,8 @@ func (repo *UserRepository) UpdateUserProfile(id int, user *models.User) (*model
 
 	// Recuperar el usuario actualizado
 	updatedUser := &models.User{}
-	query = "SELECT id_usuario, nombre, ocupacion, email, foto_perfil FROM usuario WHERE id_usuario = ?"
-	err = repo.DB.QueryRow(query, id).Scan(&updatedUser.ID, &updatedUser.Nombre, &updatedUser.Ocupacion, &updatedUser.Email, &updatedUser.FotoPerfil)
+	query = "SELECT id_usuario, nombre, ocupacion, email, foto_perfil, rol, email_verificado FROM usuario WHERE id_usuario = ?"
+	err = repo.DB.QueryRow(query, id).Scan(&updatedUser.ID, &updatedUser.Nombre, &updatedUser.Ocupacion, &updatedUser.Email, &updatedUser.FotoPerfil, &updatedUser.Rol, &updatedUser.EmailVerificado)
 	if err != nil {
 		log.Printf("Error al recuperar el usuario actualizado: %v", err)
 		return nil, err
@@ -0,0 +1,164 @@
+package repositories
+
+import (
+	"database/sql"
+	"dbconnection/models"
+	"log"
+	"time"
+)
+
+type WebAuthnRepository struct {
+	DB *sql.DB
+}
+
+const webAuthnCredentialColumns = "id_credencial, id_usuario, credential_id, nombre, datos, contador, creado_en, ultimo_uso_en"
+
+// CreateSession guarda los datos de una ceremonia en curso y limpia las que expiraron
+func (repo *WebAuthnRepository) CreateSession(sesionHash string, userID int, datos []byte, expiraEn time.Time) error {
+	if _, err := repo.DB.Exec("DELETE FROM webauthn_sesion WHERE expira_en < ?", time.Now().UTC()); err != nil {
+		log.Printf("Error al limpiar webauthn_sesion: %v", err)
+	}
+
+	_, err := repo.DB.Exec("INSERT INTO webauthn_sesion (sesion_hash, id_usuario, datos, expira_en) VALUES (?, ?, ?, ?)",
+		sesionHash, userID, datos, expiraEn)
+	if err != nil {
+		log.Printf("Error al ejecutar INSERT en webauthn_sesion: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ConsumeSession devuelve los datos de la ceremonia y la borra para que no se
+// pueda repetir; devuelve sql.ErrNoRows si no existe, expiró o ya se usó
+func (repo *WebAuthnRepository) ConsumeSession(sesionHash string) (int, []byte, error) {
+	var userID int
+	var datos []byte
+	err := repo.DB.QueryRow("SELECT id_usuario, datos FROM webauthn_sesion WHERE sesion_hash = ? AND expira_en > ?",
+		sesionHash, time.Now().UTC()).Scan(&userID, &datos)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result, err := repo.DB.Exec("DELETE FROM webauthn_sesion WHERE sesion_hash = ?", sesionHash)
+	if err != nil {
+		log.Printf("Error al consumir la sesión WebAuthn: %v", err)
+		return 0, nil, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, nil, err
+	}
+	if rows == 0 {
+		return 0, nil, sql.ErrNoRows
+	}
+	return userID, datos, nil
+}
+
+// CreateCredential guarda una passkey nueva
+func (repo *WebAuthnRepository) CreateCredential(credential *models.WebAuthnCredential) error {
+	credential.CreadoEn = time.Now().UTC()
+	result, err := repo.DB.Exec("INSERT INTO webauthn_credencial (id_usuario, credential_id, nombre, datos, contador, creado_en) VALUES (?, ?, ?, ?, ?, ?)",
+		credential.IdUsuario, credential.CredentialID, credential.Nombre, credential.Datos, credential.Contador, credential.CreadoEn)
+	if err != nil {
+		log.Printf("Error al ejecutar INSERT en webauthn_credencial: %v", err)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	credential.ID = int(id)
+	return nil
+}
+
+// ListCredentials devuelve las passkeys de un usuario
+func (repo *WebAuthnRepository) ListCredentials(userID int) ([]models.WebAuthnCredential, error) {
+	rows, err := repo.DB.Query("SELECT "+webAuthnCredentialColumns+" FROM webauthn_credencial WHERE id_usuario = ? ORDER BY creado_en", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credentials := []models.WebAuthnCredential{}
+	for rows.Next() {
+		credential, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, *credential)
+	}
+	return credentials, rows.Err()
+}
+
+// FindCredential busca una passkey por el credential ID que envía el autenticador
+func (repo *WebAuthnRepository) FindCredential(credentialID []byte) (*models.WebAuthnCredential, error) {
+	row := repo.DB.QueryRow("SELECT "+webAuthnCredentialColumns+" FROM webauthn_credencial WHERE credential_id = ?", credentialID)
+	return scanWebAuthnCredential(row)
+}
+
+// UpdateAfterLogin guarda el nuevo contador de firmas. El contador actual se
+// lee con la fila bloqueada y se compara con el que se validó, así dos inicios
+// de sesión simultáneos con la misma firma no se aceptan; devuelve
+// sql.ErrNoRows si el contador ya cambió o la passkey ya no existe. No depende
+// de las filas afectadas por el UPDATE: un autenticador que siempre envía el
+// contador en 0 puede guardar exactamente los mismos datos.
+func (repo *WebAuthnRepository) UpdateAfterLogin(id int, contadorAnterior, contador uint32, datos []byte) error {
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var actual uint32
+	if err := tx.QueryRow("SELECT contador FROM webauthn_credencial WHERE id_credencial = ? FOR UPDATE", id).Scan(&actual); err != nil {
+		return err
+	}
+	if actual != contadorAnterior {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec("UPDATE webauthn_credencial SET contador = ?, datos = ?, ultimo_uso_en = ? WHERE id_credencial = ?",
+		contador, datos, time.Now().UTC(), id); err != nil {
+		log.Printf("Error al actualizar la passkey %d: %v", id, err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteCredential borra una passkey del usuario; devuelve sql.ErrNoRows si no
+// existe o pertenece a otro usuario
+func (repo *WebAuthnRepository) DeleteCredential(id, userID int) error {
+	result, err := repo.DB.Exec("DELETE FROM webauthn_credencial WHERE id_credencial = ? AND id_usuario = ?", id, userID)
+	if err != nil {
+		log.Printf("Error al borrar la passkey %d: %v", id, err)
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebAuthnCredential(row rowScanner) (*models.WebAuthnCredential, error) {
+	credential := &models.WebAuthnCredential{}
+	var ultimoUso sql.NullTime
+	err := row.Scan(&credential.ID, &credential.IdUsuario, &credential.CredentialID, &credential.Nombre,
+		&credential.Datos, &credential.Contador, &credential.CreadoEn, &ultimoUso)
+	if err != nil {
+		return nil, err
+	}
+	if ultimoUso.Valid {
+		credential.UltimoUsoEn = &ultimoUso.Time
+	}
+	return credential, nil
+}
@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"database/sql"
+	"dbconnection/auth"
+	"dbconnection/db/dbtest"
+	"dbconnection/models"
+	"testing"
+)
+
+func TestUpdateAfterLoginCounter(t *testing.T) {
+	database := dbtest.Open(t)
+	repo := &WebAuthnRepository{DB: database}
+	userID := dbtest.CreateUser(t, database, "usuario", auth.RoleVisitor)
+
+	// Autenticador sin contador: siempre firma con 0 y guarda los mismos datos
+	credential := &models.WebAuthnCredential{IdUsuario: userID, CredentialID: []byte("sin-contador"), Nombre: "Llave", Datos: []byte(`{}`)}
+	if err := repo.CreateCredential(credential); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := repo.UpdateAfterLogin(credential.ID, 0, 0, []byte(`{}`)); err != nil {
+			t.Fatalf("inicio de sesión %d con contador 0: %v", i+1, err)
+		}
+	}
+
+	counted := &models.WebAuthnCredential{IdUsuario: userID, CredentialID: []byte("con-contador"), Nombre: "Teléfono", Datos: []byte(`{}`), Contador: 5}
+	if err := repo.CreateCredential(counted); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateAfterLogin(counted.ID, 5, 6, []byte(`{"n":6}`)); err != nil {
+		t.Fatal(err)
+	}
+	// Otro inicio de sesión validado contra el contador anterior llegó tarde
+	if err := repo.UpdateAfterLogin(counted.ID, 5, 6, []byte(`{"n":6}`)); err != sql.ErrNoRows {
+		t.Errorf("contador desactualizado: err = %v, se esperaba sql.ErrNoRows", err)
+	}
+	stored, err := repo.FindCredential(counted.CredentialID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Contador != 6 || stored.UltimoUsoEn == nil {
+		t.Errorf("passkey guardada = %+v", stored)
+	}
+
+	if err := repo.UpdateAfterLogin(counted.ID+1, 0, 1, nil); err != sql.ErrNoRows {
+		t.Errorf("passkey inexistente: err = %v, se esperaba sql.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"dbconnection/auth"
+	"dbconnection/models"
+	"dbconnection/repositories"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidAPIKey indica que la llave no existe o fue revocada
+	ErrInvalidAPIKey = errors.New("API key inválida o revocada")
+	// ErrInvalidAPIKeyRequest indica que falta el nombre o los alcances pedidos no son válidos
+	ErrInvalidAPIKeyRequest = errors.New("la API key necesita un nombre y alcances válidos")
+)
+
+const (
+	// apiKeyPrefix distingue las API keys de los JWT en la cabecera Authorization
+	apiKeyPrefix = "np_"
+	// apiKeyDisplayLength es cuántos caracteres de la llave se guardan en claro para reconocerla
+	apiKeyDisplayLength = len(apiKeyPrefix) + 8
+	// apiKeyTouchInterval es cada cuánto, como mucho, se guarda el último uso de una llave
+	apiKeyTouchInterval = time.Minute
+	maxAPIKeyNameLength = 100
+)
+
+type APIKeyService struct {
+	APIKeyRepo *repositories.APIKeyRepository
+	UserRepo   *repositories.UserRepository
+}
+
+// CreatedAPIKey es la llave recién creada; Key solo se devuelve esta vez
+type CreatedAPIKey struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// LooksLikeAPIKey indica si el valor de una credencial tiene el formato de una API key
+func LooksLikeAPIKey(value string) bool {
+	return strings.HasPrefix(value, apiKeyPrefix)
+}
+
+// CreateKey genera una API key para el usuario autenticado con los alcances pedidos
+func (service *APIKeyService) CreateKey(ctx context.Context, name string, scopes []string) (*CreatedAPIKey, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Una API key no puede crear otras llaves
+	if !auth.Can(principal, auth.ActionManageAPIKeys, principal.UserID) {
+		return nil, ErrForbidden
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxAPIKeyNameLength {
+		return nil, ErrInvalidAPIKeyRequest
+	}
+	granted, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	token, _, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	key := apiKeyPrefix + token
+
+	stored := models.APIKey{
+		IdUsuario: principal.UserID,
+		Nombre:    name,
+		Prefijo:   key[:apiKeyDisplayLength],
+		KeyHash:   auth.HashOpaqueToken(key),
+		Scopes:    granted,
+	}
+	if err := service.APIKeyRepo.CreateKey(&stored); err != nil {
+		return nil, err
+	}
+	log.Printf("API key %d creada para el usuario %d con alcances %v", stored.ID, principal.UserID, granted)
+	return &CreatedAPIKey{APIKey: stored, Key: key}, nil
+}
+
+// ListKeys devuelve las llaves de un usuario; userID 0 es el usuario
+// autenticado. Solo un administrador puede ver las llaves de otro usuario.
+func (service *APIKeyService) ListKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if userID == 0 {
+		userID = principal.UserID
+	}
+	if !auth.Can(principal, auth.ActionManageAPIKeys, userID) {
+		return nil, ErrForbidden
+	}
+	return service.APIKeyRepo.ListKeys(userID)
+}
+
+// RevokeKey revoca una llave; solo su dueño o un administrador pueden hacerlo.
+// Revocar una llave ya revocada no es un error.
+func (service *APIKeyService) RevokeKey(ctx context.Context, id int) error {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := service.APIKeyRepo.GetKeyByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if !auth.Can(principal, auth.ActionManageAPIKeys, key.IdUsuario) {
+		return ErrForbidden
+	}
+
+	err = service.APIKeyRepo.RevokeKey(id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	log.Printf("API key %d del usuario %d revocada por el usuario %d", id, key.IdUsuario, principal.UserID)
+	return nil
+}
+
+// Authenticate valida una API key y devuelve el usuario a cuyo nombre actúa.
+// El rol y la verificación del correo se leen del usuario en cada solicitud,
+// así que un cambio de rol aplica de inmediato también a sus llaves.
+func (service *APIKeyService) Authenticate(key string) (*auth.Principal, error) {
+	stored, err := service.APIKeyRepo.FindActiveByHash(auth.HashOpaqueToken(key))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	user, err := service.UserRepo.GetUserByID(stored.IdUsuario)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	// El último uso es informativo: un error al guardarlo no rechaza la solicitud
+	service.APIKeyRepo.TouchLastUsed(stored.ID, apiKeyTouchInterval)
+
+	return &auth.Principal{
+		UserID:        user.ID,
+		Role:          user.Rol,
+		EmailVerified: user.EmailVerificado,
+		APIKeyID:      stored.ID,
+		Scopes:        stored.Scopes,
+	}, nil
+}
+
+// normalizeScopes valida los alcances pedidos y quita los repetidos
+func normalizeScopes(scopes []string) ([]string, error) {
+	granted := make([]string, 0, len(scopes))
+	seen := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !auth.ValidScope(scope) {
+			return nil, ErrInvalidAPIKeyRequest
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			granted = append(granted, scope)
+		}
+	}
+	if len(granted) == 0 {
+		return nil, ErrInvalidAPIKeyRequest
+	}
+	return granted, nil
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"dbconnection/auth"
+	"dbconnection/models"
+	"dbconnection/repositories"
+	"dbconnection/utils"
+	"encoding/json"
+	"log"
+	"reflect"
+	"time"
+)
+
+// Entidades y acciones del log de auditoría
+const (
+	AuditEntityUser       = "user"
+	AuditEntityFair       = "fair"
+	AuditEntityPreference = "preference"
+	AuditEntityStand      = "stand"
+
+	AuditCreate         = "create"
+	AuditUpdate         = "update"
+	AuditDelete         = "delete"
+	AuditRoleChange     = "role_change"
+	AuditPasswordReset  = "password_reset"
+	AuditPasswordChange = "password_change"
+	AuditImpersonate    = "impersonate"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+// auditRedactedFields son los campos que nunca se copian al log; solo se
+// registra que cambiaron
+var auditRedactedFields = map[string]bool{"contraseña": true}
+
+type AuditService struct {
+	AuditRepo *repositories.AuditRepository
+}
+
+// Record registra un cambio hecho en la solicitud actual. before es nil en las
+// altas y after es nil en las bajas; en los cambios solo se guardan los campos
+// que difieren. Un error al escribir el registro se informa en el log pero no
+// deshace la operación, que ya se completó.
+func (service *AuditService) Record(ctx context.Context, action, entity string, entityID int, before, after interface{}) {
+	if service == nil {
+		return
+	}
+
+	entry := &models.AuditEntry{
+		Accion:    action,
+		Entidad:   entity,
+		IdEntidad: entityID,
+		RequestID: utils.RequestIDFromContext(ctx),
+		CreadoEn:  time.Now().UTC(),
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		entry.IdActor = &principal.UserID
+		if principal.IsAPIKey() {
+			entry.IdAPIKey = &principal.APIKeyID
+		}
+		if principal.IsImpersonated() {
+			entry.IdImpersonador = &principal.ImpersonatorID
+		}
+	}
+
+	var err error
+	entry.Antes, entry.Despues, err = auditDiff(before, after)
+	if err == nil {
+		err = service.AuditRepo.CreateEntry(entry)
+	}
+	if err != nil {
+		log.Printf("AUDITORÍA NO REGISTRADA: %s %s %d (request %s): %v", action, entity, entityID, entry.RequestID, err)
+	}
+}
+
+// ListEntries consulta el log de auditoría; solo para administradores
+func (service *AuditService) ListEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !auth.Can(principal, auth.ActionReadAuditLog, 0) {
+		return nil, ErrForbidden
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditPageSize
+	}
+	if filter.Limit > maxAuditPageSize {
+		filter.Limit = maxAuditPageSize
+	}
+	return service.AuditRepo.ListEntries(filter)
+}
+
+// auditDiff convierte los dos estados a JSON y, si existen ambos, deja solo los
+// campos que cambiaron. Los campos sensibles se comparan antes de ocultarlos,
+// así el registro muestra que cambiaron sin exponer su valor.
+func auditDiff(before, after interface{}) (json.RawMessage, json.RawMessage, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if beforeFields != nil && afterFields != nil {
+		for key, value := range beforeFields {
+			if other, ok := afterFields[key]; ok && reflect.DeepEqual(value, other) {
+				delete(beforeFields, key)
+				delete(afterFields, key)
+			}
+		}
+	}
+
+	redactAuditFields(beforeFields)
+	redactAuditFields(afterFields)
+
+	beforeJSON, err := marshalAuditFields(beforeFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterJSON, err := marshalAuditFields(afterFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	return beforeJSON, afterJSON, nil
+}
+
+func auditFields(value interface{}) (map[string]interface{}, error) {
+	if value == nil || reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil() {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func redactAuditFields(fields map[string]interface{}) {
+	for key := range fields {
+		if auditRedactedFields[key] {
+			fields[key] = "[REDACTED]"
+		}
+	}
+}
+
+func marshalAuditFields(fields map[string]interface{}) (json.RawMessage, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"dbconnection/auth"
+	"dbconnection/mailer"
+	"dbconnection/models"
+	"dbconnection/repositories"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+var (
+	// ErrInvalidVerificationToken indica que el enlace de verificación es inválido, expiró o es de otro correo
+	ErrInvalidVerificationToken = errors.New("el enlace de verificación es inválido o expiró")
+	// ErrAlreadyVerified indica que el correo del usuario ya está verificado
+	ErrAlreadyVerified = errors.New("el correo ya está verificado")
+)
+
+// ThrottledError indica que hay que esperar RetryAfter antes de repetir la operación
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("demasiadas solicitudes, intente de nuevo en %d segundos", int(e.RetryAfter.Seconds())+1)
+}
+
+type EmailVerificationService struct {
+	UserRepo       *repositories.UserRepository
+	Tokens         *auth.TokenManager
+	Mailer         mailer.Mailer
+	BaseURL        string
+	TTL            time.Duration
+	ResendInterval time.Duration // Tiempo mínimo entre dos correos de verificación
+}
+
+// SendVerification envía al usuario el enlace firmado para verificar su correo.
+// El token incluye el correo, así que deja de servir si el usuario lo cambia.
+func (service *EmailVerificationService) SendVerification(user *models.User) error {
+	token, err := service.Tokens.IssuePurposeToken(auth.Claims{UserID: user.ID, Email: user.Email}, auth.PurposeEmailVerification, service.TTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", service.BaseURL, url.QueryEscape(token))
+	err = service.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verifica tu correo",
+		Body: fmt.Sprintf("Hola %s,\n\nConfirma tu correo abriendo este enlace:\n%s\n\nEl enlace vence en %s.",
+			user.Nombre, link, service.TTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return service.UserRepo.SetVerificationSentAt(user.ID, time.Now().UTC())
+}
+
+// ConfirmEmail marca el correo como verificado si el token es válido y sigue
+// correspondiendo al correo actual del usuario
+func (service *EmailVerificationService) ConfirmEmail(token string) error {
+	claims, err := service.Tokens.ParsePurposeToken(token, auth.PurposeEmailVerification)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	user, err := service.UserRepo.GetUserByID(claims.UserID)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+	if user.Email != claims.Email {
+		return ErrInvalidVerificationToken
+	}
+	if user.EmailVerificado {
+		return nil
+	}
+
+	return service.UserRepo.SetEmailVerified(user.ID, true)
+}
+
+// ResendVerification reenvía el enlace al usuario autenticado, como máximo una
+// vez cada ResendInterval
+func (service *EmailVerificationService) ResendVerification(ctx context.Context) error {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := service.UserRepo.GetUserByID(principal.UserID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerificado {
+		return ErrAlreadyVerified
+	}
+
+	sentAt, err := service.UserRepo.GetVerificationSentAt(user.ID)
+	if err != nil {
+		return err
+	}
+	if sentAt.Valid {
+		if wait := service.ResendInterval - time.Since(sentAt.Time); wait > 0 {
+			return &ThrottledError{RetryAfter: wait}
+		}
+	}
+
+	return service.SendVerification(user)
+}
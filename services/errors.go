@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"dbconnection/auth"
+	"errors"
+)
+
+var (
+	// ErrUnauthorized indica que la operación requiere un usuario autenticado
+	ErrUnauthorized = errors.New("se requiere autenticación")
+	// ErrForbidden indica que el usuario autenticado no tiene permiso sobre el recurso
+	ErrForbidden = errors.New("no tiene permiso para realizar esta acción")
+	// ErrNotFound indica que el recurso solicitado no existe
+	ErrNotFound = errors.New("recurso no encontrado")
+	// ErrInvalidRole indica que el rol solicitado no existe
+	ErrInvalidRole = errors.New("rol inválido")
+)
+
+// ValidationError indica que los datos enviados no son válidos; Fields tiene
+// los problemas de cada campo para mostrarlos junto al campo en el formulario
+type ValidationError struct {
+	Fields map[string][]string
+}
+
+func (e *ValidationError) Error() string {
+	return "datos inválidos"
+}
+
+// requirePrincipal obtiene el usuario autenticado del contexto o devuelve ErrUnauthorized
+func requirePrincipal(ctx context.Context) (*auth.Principal, error) {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, ErrUnauthorized
+	}
+	return principal, nil
+}
+
+// requireCredentialAccess obtiene el usuario autenticado para operar sobre las
+// credenciales de su propia cuenta; se rechaza durante una suplantación
+func requireCredentialAccess(ctx context.Context) (*auth.Principal, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !auth.Can(principal, auth.ActionManageCredentials, principal.UserID) {
+		return nil, ErrForbidden
+	}
+	return principal, nil
+}
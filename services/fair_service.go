@@ -1,62 +1,380 @@
 package services
 
 import (
+	"context"
+	"database/sql"
+	"dbconnection/auth"
 	"dbconnection/models"
 	"dbconnection/repositories"
+	"dbconnection/utils"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
-	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cloudinary/cloudinary-go"
 )
 
+// FairForm son los campos de una feria que llegan como texto del formulario y
+// que valida el servicio: instantes en RFC 3339, la zona horaria IANA en la que
+// se muestran y la capacidad
+type FairForm struct {
+	Inicio      string
+	Fin         string
+	ZonaHoraria string
+	Capacidad   *string // nil si no se envió; vacío quita el límite
+}
+
+const (
+	defaultFairPageSize = 20
+	maxFairPageSize     = 100
+	maxSearchTerms      = 10
+	searchSnippetLength = 160 // Caracteres del fragmento de la descripción
+)
+
 type FairService struct {
-	FairRepo   *repositories.FairRepository
-	Cloudinary *cloudinary.Cloudinary
+	FairRepo      *repositories.FairRepository
+	Cloudinary    *cloudinary.Cloudinary
+	Audit         *AuditService
+	Registrations *RegistrationService // Para llenar los lugares que abre un cambio de capacidad
 }
 
-// DeleteFair elimina una feria usando el repositorio
-func (service *FairService) DeleteFair(id int) error {
+// DeleteFair elimina una feria usando el repositorio; solo su dueño o un administrador pueden hacerlo
+func (service *FairService) DeleteFair(ctx context.Context, id int) error {
+	existing, err := service.authorize(ctx, id, auth.ActionDeleteFair)
+	if err != nil {
+		return err
+	}
+
 	// Llamar al repositorio para eliminar la feria de la base de datos
-	err := service.FairRepo.DeleteFair(id)
+	err = service.FairRepo.DeleteFair(id)
 	if err != nil {
 		log.Printf("Error al eliminar la feria en el repositorio: %v", err)
 		return err
 	}
+	service.Audit.Record(ctx, AuditDelete, AuditEntityFair, id, existing, nil)
 
 	// Si no hay error, la eliminación fue exitosa
 	return nil
 }
 
-func (service *FairService) UpdateFair(id int, fair *models.Fair) (*models.Fair, error) {
+// UpdateFair actualiza la feria; las fechas que no se envían conservan su valor
+func (service *FairService) UpdateFair(ctx context.Context, id int, fair *models.Fair, form FairForm) (*models.Fair, error) {
+	existing, err := service.authorize(ctx, id, auth.ActionUpdateFair)
+	if err != nil {
+		return nil, err
+	}
+
+	if form.Inicio == "" && !existing.FechaInicio.IsZero() {
+		form.Inicio = existing.FechaInicio.Format(time.RFC3339)
+	}
+	if form.Fin == "" && !existing.FechaFin.IsZero() {
+		form.Fin = existing.FechaFin.Format(time.RFC3339)
+	}
+	if form.ZonaHoraria == "" {
+		form.ZonaHoraria = existing.ZonaHoraria
+	}
+	fair.Capacidad = existing.Capacidad
+	if err := applyFairForm(fair, form); err != nil {
+		return nil, err
+	}
+
+	// El dueño de la feria no se puede cambiar desde una actualización
+	fair.IdUsuario = existing.IdUsuario
+
 	// Llamar al repositorio para actualizar la feria en la base de datos
-	updatedFair, err := service.FairRepo.UpdateFair(id, fair)
+	updatedFair, conflicts, err := service.FairRepo.UpdateFair(id, fair)
 	if err != nil {
 		log.Printf("Error al actualizar la feria en el repositorio: %v", err)
 		return nil, err
 	}
+	if len(conflicts) > 0 {
+		// Las fechas nuevas dejarían a un expositor en dos stands que se cruzan
+		return nil, &ValidationError{Fields: map[string][]string{"fecha_inicio": standConflictMessages(conflicts, id)}}
+	}
+	service.Audit.Record(ctx, AuditUpdate, AuditEntityFair, id, existing, updatedFair)
+
+	// Si la capacidad cambió puede haber lugar para la lista de espera
+	if !sameCapacity(existing.Capacidad, updatedFair.Capacidad) {
+		service.Registrations.FillFromWaitlist(id)
+	}
 
 	// Retornar la feria actualizada
 	return updatedFair, nil
 }
 
-// CreateFair - Servicio para crear una feria y devolver el objeto creado
-func (service *FairService) CreateFair(fair *models.Fair, r *http.Request) (*models.Fair, error) {
+// CreateFair - Servicio para crear una feria a nombre del usuario autenticado y devolver el objeto creado.
+// Solo los organizadores (y administradores) pueden crear ferias.
+func (service *FairService) CreateFair(ctx context.Context, fair *models.Fair, form FairForm) (*models.Fair, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !auth.Can(principal, auth.ActionCreateFair, 0) {
+		return nil, ErrForbidden
+	}
+	fair.IdUsuario = principal.UserID
+	if err := applyFairForm(fair, form); err != nil {
+		return nil, err
+	}
+
 	// Llamar al repositorio para crear la feria en la base de datos
 	createdFair, err := service.FairRepo.CreateFair(fair)
 	if err != nil {
 		log.Printf("Error al crear la feria en el repositorio: %v", err)
 		return nil, err
 	}
+	service.Audit.Record(ctx, AuditCreate, AuditEntityFair, createdFair.ID, nil, createdFair)
 
 	// Retornar la feria creada
 	return createdFair, nil
 }
 
-// GetAllFairs obtiene todas las ferias del repositorio
-func (service *FairService) GetAllFairs() ([]models.Fair, error) {
-	return service.FairRepo.GetAllFairs()
+// ListFairs obtiene una página de ferias que cumplen el filtro. cursor es el
+// next_cursor de la página anterior, o vacío para la primera.
+func (service *FairService) ListFairs(ctx context.Context, filter models.FairFilter, cursor string) (*models.FairPage, error) {
+	if err := authorizeRead(ctx); err != nil {
+		return nil, err
+	}
+
+	problems := checkFairFilter(&filter)
+
+	// Sin sort ni order se usa el orden del cursor; el cursor no sirve con otro orden
+	if cursor != "" {
+		decoded, err := decodeFairCursor(cursor)
+		if err != nil {
+			problems["cursor"] = append(problems["cursor"], "es inválido")
+		} else {
+			order := "asc"
+			if decoded.Desc {
+				order = "desc"
+			}
+			if (filter.Sort != "" && filter.Sort != decoded.Sort) || (filter.Order != "" && filter.Order != order) {
+				problems["cursor"] = append(problems["cursor"], "no corresponde al orden pedido")
+			}
+			filter.Sort, filter.Order, filter.Cursor = decoded.Sort, order, decoded
+		}
+	}
+	if filter.Sort == "" {
+		filter.Sort = models.FairSortStart
+	} else if !models.ValidFairSort(filter.Sort) {
+		problems["sort"] = append(problems["sort"], "debe ser start, title o created")
+	}
+	switch filter.Order {
+	case "asc", "desc":
+	case "":
+		// Las ferias pasadas se muestran de la más reciente a la más antigua
+		filter.Order = "asc"
+		if filter.Status == models.FairPast {
+			filter.Order = "desc"
+		}
+	default:
+		problems["order"] = append(problems["order"], "debe ser asc o desc")
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Fields: problems}
+	}
+
+	now := time.Now()
+	fairs, next, err := service.FairRepo.ListFairs(filter, now)
+	if err != nil {
+		return nil, err
+	}
+	total, err := service.FairRepo.CountFairs(filter, now)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.FairPage{Items: fairs, Total: total}
+	if next != nil {
+		encoded, err := encodeFairCursor(next)
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = &encoded
+	}
+	return page, nil
 }
 
-func (service *FairService) GetFairDetails(id int) (*models.Fair, error) {
+// SearchFairs busca el texto en el título y la descripción sin distinguir
+// acentos ni mayúsculas. Admite los mismos filtros que ListFairs salvo el
+// orden, que es por relevancia, y el cursor.
+func (service *FairService) SearchFairs(ctx context.Context, query string, filter models.FairFilter) (*models.FairSearchPage, error) {
+	if err := authorizeRead(ctx); err != nil {
+		return nil, err
+	}
+
+	problems := checkFairFilter(&filter)
+	terms := utils.SearchTerms(query)
+	if len(terms) == 0 {
+		problems["q"] = append(problems["q"], "debe tener al menos una palabra")
+	} else if len(terms) > maxSearchTerms {
+		problems["q"] = append(problems["q"], fmt.Sprintf("admite hasta %d palabras", maxSearchTerms))
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Fields: problems}
+	}
+
+	results, total, err := service.FairRepo.SearchFairs(filter, terms, filter.Limit, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		fair := &results[i].Feria
+		results[i].TituloResaltado = utils.Highlight(fair.Titulo, terms, 0)
+		results[i].Fragmento = utils.Highlight(fair.Descripcion, terms, searchSnippetLength)
+	}
+	return &models.FairSearchPage{Items: results, Total: total}, nil
+}
+
+// checkFairFilter revisa los filtros comunes del listado y la búsqueda y
+// completa el límite por defecto; devuelve los problemas por parámetro
+func checkFairFilter(filter *models.FairFilter) map[string][]string {
+	problems := map[string][]string{}
+	if filter.Status != "" && !models.ValidFairStatus(filter.Status) {
+		problems["status"] = append(problems["status"], "debe ser upcoming, ongoing o past")
+	}
+	if !filter.Desde.IsZero() && !filter.Hasta.IsZero() && !filter.Hasta.After(filter.Desde) {
+		problems["to"] = append(problems["to"], "debe ser posterior a from")
+	}
+	if filter.Limit < 0 || filter.Limit > maxFairPageSize {
+		problems["limit"] = append(problems["limit"], fmt.Sprintf("debe estar entre 1 y %d", maxFairPageSize))
+	} else if filter.Limit == 0 {
+		filter.Limit = defaultFairPageSize
+	}
+	return problems
+}
+
+// encodeFairCursor convierte el cursor en el valor opaco que recibe el cliente
+func encodeFairCursor(cursor *models.FairCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeFairCursor(value string) (*models.FairCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	cursor := &models.FairCursor{}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+func (service *FairService) GetFairDetails(ctx context.Context, id int) (*models.Fair, error) {
+	if err := authorizeRead(ctx); err != nil {
+		return nil, err
+	}
 	return service.FairRepo.GetFairByID(id)
 }
+
+// applyFairForm valida los campos del formulario y los asigna a la feria. Sin
+// zona horaria se usa UTC; si no se envía la capacidad, la feria conserva la
+// que tenga.
+func applyFairForm(fair *models.Fair, form FairForm) error {
+	problems := map[string][]string{}
+
+	zone := strings.TrimSpace(form.ZonaHoraria)
+	if zone == "" {
+		zone = "UTC"
+	}
+	// "Local" depende del servidor, así que no se acepta como zona de una feria
+	location, err := time.LoadLocation(zone)
+	if err != nil || zone == "Local" {
+		problems["zona_horaria"] = append(problems["zona_horaria"], "no es una zona horaria IANA válida")
+	}
+
+	parse := func(field, value string) time.Time {
+		if strings.TrimSpace(value) == "" {
+			problems[field] = append(problems[field], "es obligatoria")
+			return time.Time{}
+		}
+		parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+		if err != nil {
+			problems[field] = append(problems[field], "debe tener el formato RFC 3339, por ejemplo 2025-03-01T10:00:00-06:00")
+		}
+		return parsed
+	}
+	start := parse("fecha_inicio", form.Inicio)
+	end := parse("fecha_fin", form.Fin)
+	if !start.IsZero() && !end.IsZero() && !end.After(start) {
+		problems["fecha_fin"] = append(problems["fecha_fin"], "debe ser posterior a la fecha de inicio")
+	}
+
+	capacity := fair.Capacidad
+	if form.Capacidad != nil {
+		capacity = nil
+		if value := strings.TrimSpace(*form.Capacidad); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 {
+				problems["capacidad"] = append(problems["capacidad"], "debe ser un número entero mayor que cero")
+			}
+			capacity = &parsed
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Fields: problems}
+	}
+	fair.Capacidad = capacity
+	fair.FechaInicio = start.In(location)
+	fair.FechaFin = end.In(location)
+	fair.ZonaHoraria = zone
+	return nil
+}
+
+// sameCapacity compara dos capacidades; nil es sin límite
+func sameCapacity(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// authorizeRead permite leer ferias sin autenticarse; si la solicitud trae una
+// API key, esta necesita el alcance fairs:read
+func authorizeRead(ctx context.Context) error {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if ok && !auth.Can(principal, auth.ActionReadFairs, 0) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// CheckOwnership permite validar los permisos antes de hacer trabajo costoso
+// (por ejemplo, subir la foto a Cloudinary) en una actualización
+func (service *FairService) CheckOwnership(ctx context.Context, id int) error {
+	_, err := service.authorize(ctx, id, auth.ActionUpdateFair)
+	return err
+}
+
+// authorize verifica que la feria exista y que el usuario autenticado pueda
+// ejecutar la acción sobre ella según la política de roles
+func (service *FairService) authorize(ctx context.Context, id int, action auth.Action) (*models.Fair, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fair, err := service.FairRepo.GetFairByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if !auth.Can(principal, action, fair.IdUsuario) {
+		return nil, ErrForbidden
+	}
+	return fair, nil
+}
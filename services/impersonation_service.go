@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"dbconnection/auth"
+	"dbconnection/models"
+	"dbconnection/repositories"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// ErrImpersonationReasonRequired indica que no se indicó el motivo de la suplantación
+var ErrImpersonationReasonRequired = errors.New("se debe indicar el motivo de la suplantación")
+
+const maxImpersonationReasonLength = 255
+
+type ImpersonationService struct {
+	UserRepo *repositories.UserRepository
+	Tokens   *auth.TokenManager
+	Audit    *AuditService
+	TTL      time.Duration
+}
+
+// ImpersonationResponse es el token con el que el administrador ve la
+// aplicación como el usuario. Impersonation permite al frontend mostrar un
+// aviso mientras se usa.
+type ImpersonationResponse struct {
+	Token          string       `json:"token"`
+	ExpiresIn      int          `json:"expires_in"`
+	User           *models.User `json:"user"`
+	Impersonation  bool         `json:"impersonation"`
+	ImpersonatorID int          `json:"impersonator_id"`
+}
+
+// Start emite un token de suplantación del usuario indicado. Solo un
+// administrador puede pedirlo, no puede suplantar a otro administrador ni a sí
+// mismo y debe dejar el motivo, que queda en el registro de auditoría.
+func (service *ImpersonationService) Start(ctx context.Context, targetID int, reason string) (*ImpersonationResponse, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !auth.Can(principal, auth.ActionImpersonate, 0) {
+		return nil, ErrForbidden
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" || len([]rune(reason)) > maxImpersonationReasonLength {
+		return nil, ErrImpersonationReasonRequired
+	}
+
+	target, err := service.UserRepo.GetUserByID(targetID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if target.ID == principal.UserID || target.Rol == auth.RoleAdmin {
+		return nil, ErrForbidden
+	}
+
+	token, expiresAt, err := service.Tokens.IssueImpersonationToken(auth.Claims{
+		UserID:        target.ID,
+		Role:          target.Rol,
+		EmailVerified: target.EmailVerificado,
+	}, principal.UserID, service.TTL)
+	if err != nil {
+		return nil, err
+	}
+
+	service.Audit.Record(ctx, AuditImpersonate, AuditEntityUser, target.ID, nil, map[string]interface{}{
+		"motivo":    reason,
+		"expira_en": expiresAt.UTC(),
+	})
+	log.Printf("El administrador %d inició la suplantación del usuario %d: %s", principal.UserID, target.ID, reason)
+
+	return &ImpersonationResponse{
+		Token:          token,
+		ExpiresIn:      int(time.Until(expiresAt).Seconds()),
+		User:           target,
+		Impersonation:  true,
+		ImpersonatorID: principal.UserID,
+	}, nil
+}
+
+// IsActiveImpersonator indica si quien suplanta sigue siendo administrador; si
+// perdió el rol, sus tokens de suplantación dejan de aceptarse aunque no hayan vencido
+func (service *ImpersonationService) IsActiveImpersonator(actorID int) (bool, error) {
+	actor, err := service.UserRepo.GetUserByID(actorID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return actor.Rol == auth.RoleAdmin, nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"dbconnection/auth"
+	"dbconnection/repositories"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// LoginThrottleService limita los intentos de inicio de sesión por cuenta y por
+// IP. Al superar el máximo de fallos la clave queda bloqueada un tiempo que se
+// duplica con cada fallo adicional, hasta MaxLockout.
+type LoginThrottleService struct {
+	ThrottleRepo  *repositories.LoginThrottleRepository
+	UserRepo      *repositories.UserRepository
+	MaxAttempts   int           // Fallos permitidos por cuenta antes de bloquear
+	MaxAttemptsIP int           // Fallos permitidos por IP antes de bloquear
+	Window        time.Duration // Los fallos más antiguos que esto se olvidan
+	BaseLockout   time.Duration
+	MaxLockout    time.Duration
+}
+
+func accountKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check devuelve un *ThrottledError si la cuenta o la IP están bloqueadas
+func (service *LoginThrottleService) Check(email, ip string) error {
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		throttle, err := service.ThrottleRepo.GetThrottle(key)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return err
+		}
+		if throttle.BloqueadoHasta.Valid {
+			if wait := time.Until(throttle.BloqueadoHasta.Time); wait > 0 {
+				return &ThrottledError{RetryAfter: wait}
+			}
+		}
+	}
+	return nil
+}
+
+// RecordFailure registra un intento fallido para la cuenta y la IP, y las
+// bloquea si superaron su máximo
+func (service *LoginThrottleService) RecordFailure(email, ip string) {
+	service.recordFailure(accountKey(email), service.MaxAttempts)
+	service.recordFailure(ipKey(ip), service.MaxAttemptsIP)
+}
+
+func (service *LoginThrottleService) recordFailure(key string, maxAttempts int) {
+	now := time.Now().UTC()
+	fallos, err := service.ThrottleRepo.RecordFailure(key, now, now.Add(-service.Window))
+	if err != nil {
+		return
+	}
+	if fallos < maxAttempts {
+		return
+	}
+
+	lockout := service.BaseLockout
+	for i := maxAttempts; i < fallos && lockout < service.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > service.MaxLockout {
+		lockout = service.MaxLockout
+	}
+
+	log.Printf("Demasiados intentos fallidos para %s, bloqueado por %s", key, lockout)
+	service.ThrottleRepo.LockUntil(key, now.Add(lockout))
+}
+
+// RecordSuccess olvida los fallos de la cuenta tras un inicio de sesión correcto
+func (service *LoginThrottleService) RecordSuccess(email string) {
+	service.ThrottleRepo.Reset(accountKey(email))
+}
+
+// UnlockUser quita el bloqueo de la cuenta de un usuario; solo para administradores
+func (service *LoginThrottleService) UnlockUser(ctx context.Context, userID int) error {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return err
+	}
+	if !auth.Can(principal, auth.ActionManageUsers, 0) {
+		return ErrForbidden
+	}
+
+	user, err := service.UserRepo.GetUserByID(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return service.ThrottleRepo.Reset(accountKey(user.Email))
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"dbconnection/auth"
+	"dbconnection/mailer"
+	"dbconnection/repositories"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+)
+
+// ErrInvalidMagicLink indica que el enlace no existe, expiró, ya se usó o se
+// abrió en un navegador distinto al que lo pidió
+var ErrInvalidMagicLink = errors.New("el enlace de inicio de sesión es inválido o expiró")
+
+type MagicLinkService struct {
+	UserRepo       *repositories.UserRepository
+	MagicLinkRepo  *repositories.MagicLinkRepository
+	MFA            *MFAService
+	TokenService   *TokenService // Para cerrar las sesiones al reclamar una cuenta sin verificar
+	Audit          *AuditService
+	BcryptCost     int
+	Mailer         mailer.Mailer
+	BaseURL        string
+	TTL            time.Duration
+	ResendInterval time.Duration // Tiempo mínimo entre dos enlaces para la misma cuenta
+}
+
+// RequestLink envía en segundo plano un enlace de inicio de sesión de un solo
+// uso al correo. Devuelve de inmediato un valor para la cookie del navegador,
+// exista o no la cuenta, para que ni la respuesta ni el tiempo que tarda revelen
+// qué correos están registrados; el enlace solo funciona en el navegador que
+// guarde esa cookie.
+func (service *MagicLinkService) RequestLink(email string) (string, error) {
+	binding, bindingHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	go func() {
+		if err := service.sendLink(email, bindingHash); err != nil {
+			log.Printf("Error al procesar la solicitud de enlace de inicio de sesión: %v", err)
+		}
+	}()
+	return binding, nil
+}
+
+// sendLink genera el enlace ligado al navegador y envía el correo; si el correo
+// no está registrado o ya se envió un enlace hace poco no hace nada
+func (service *MagicLinkService) sendLink(email, bindingHash string) error {
+	user, err := service.UserRepo.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			log.Printf("Solicitud de enlace de inicio de sesión para un correo no registrado")
+			return nil
+		}
+		return err
+	}
+
+	// Evitar que se use el endpoint para llenar la bandeja de entrada de alguien
+	lastCreated, err := service.MagicLinkRepo.LastCreatedAt(user.ID)
+	if err != nil {
+		return err
+	}
+	if lastCreated.Valid && time.Since(lastCreated.Time) < service.ResendInterval {
+		log.Printf("Enlace de inicio de sesión para el usuario %d omitido: se pidió hace poco", user.ID)
+		return nil
+	}
+
+	token, tokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return err
+	}
+	if err := service.MagicLinkRepo.CreateToken(user.ID, user.Email, tokenHash, bindingHash, time.Now().UTC().Add(service.TTL)); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/magic-link?token=%s", service.BaseURL, url.QueryEscape(token))
+	return service.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Tu enlace para iniciar sesión",
+		Body: fmt.Sprintf("Hola %s,\n\nPara iniciar sesión abre este enlace en el mismo navegador donde lo pediste:\n%s\n\n"+
+			"El enlace vence en %s y solo se puede usar una vez. Si no lo solicitaste, ignora este correo.",
+			user.Nombre, link, service.TTL),
+	})
+}
+
+// Login canjea el enlace por los mismos tokens que el login con contraseña.
+// Abrir el enlace demuestra que el usuario controla el correo al que se envió,
+// así que, si sigue siendo su correo, también queda verificado.
+func (service *MagicLinkService) Login(ctx context.Context, token, binding string, client ClientInfo) (*LoginResponse, error) {
+	if token == "" || binding == "" {
+		return nil, ErrInvalidMagicLink
+	}
+
+	userID, email, err := service.MagicLinkRepo.ConsumeToken(auth.HashOpaqueToken(token), auth.HashOpaqueToken(binding))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidMagicLink
+		}
+		return nil, err
+	}
+
+	user, err := service.UserRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	// Un enlace enviado al correo anterior no demuestra nada sobre el actual
+	if email != user.Email {
+		log.Printf("Enlace de inicio de sesión del usuario %d rechazado: se envió a un correo que ya no es el suyo", user.ID)
+		return nil, ErrInvalidMagicLink
+	}
+	if err := confirmEmailOwnership(ctx, service.UserRepo, service.TokenService, service.Audit, service.BcryptCost, user); err != nil {
+		return nil, err
+	}
+
+	return service.MFA.StartLogin(user, client)
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"dbconnection/auth"
+	"dbconnection/models"
+	"dbconnection/repositories"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMFAAlreadyEnabled indica que el usuario ya tiene activada la verificación en dos pasos
+	ErrMFAAlreadyEnabled = errors.New("la verificación en dos pasos ya está activada")
+	// ErrMFANotEnrolled indica que no hay una inscripción TOTP pendiente o activa
+	ErrMFANotEnrolled = errors.New("la verificación en dos pasos no está configurada")
+	// ErrInvalidMFACode indica que el código TOTP o de recuperación no es válido
+	ErrInvalidMFACode = errors.New("código de verificación inválido")
+)
+
+const recoveryCodeCount = 10
+
+type MFAService struct {
+	MFARepo       *repositories.MFARepository
+	UserRepo      *repositories.UserRepository
+	TokenService  *TokenService
+	Tokens        *auth.TokenManager
+	LoginThrottle *LoginThrottleService
+	Issuer        string        // Nombre que muestran las aplicaciones autenticadoras
+	ChallengeTTL  time.Duration // Vigencia del token "mfa pendiente" entre los dos pasos del login
+}
+
+// MFAEnrollment es lo que necesita el usuario para registrar la aplicación autenticadora
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// IsEnabled indica si el usuario tiene la verificación en dos pasos confirmada
+func (service *MFAService) IsEnabled(userID int) (bool, error) {
+	mfa, err := service.MFARepo.GetMFA(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return mfa.Confirmed(), nil
+}
+
+// BeginChallenge responde al primer paso del login con un token de corta
+// duración que solo sirve para enviar el código en /api/auth/mfa/verify
+func (service *MFAService) BeginChallenge(user *models.User) (*LoginResponse, error) {
+	token, err := service.Tokens.IssuePurposeToken(auth.Claims{UserID: user.ID}, auth.PurposeMFA, service.ChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResponse{MFARequired: true, MFAToken: token}, nil
+}
+
+// StartLogin emite los tokens para un usuario que ya se autenticó por otro
+// medio (por ejemplo un proveedor externo), o pide el código TOTP si lo tiene activado
+func (service *MFAService) StartLogin(user *models.User, client ClientInfo) (*LoginResponse, error) {
+	enabled, err := service.IsEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return service.BeginChallenge(user)
+	}
+	return service.TokenService.NewLoginResponse(user, client)
+}
+
+// VerifyLogin completa el segundo paso del login con un código TOTP o de recuperación
+func (service *MFAService) VerifyLogin(mfaToken, code string, client ClientInfo) (*LoginResponse, error) {
+	claims, err := service.Tokens.ParsePurposeToken(mfaToken, auth.PurposeMFA)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	user, err := service.UserRepo.GetUserByID(claims.UserID)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Los códigos fallidos cuentan para el mismo bloqueo que las contraseñas
+	if err := service.LoginThrottle.Check(user.Email, client.IP); err != nil {
+		return nil, err
+	}
+
+	mfa, err := service.MFARepo.GetMFA(user.ID)
+	if err != nil || !mfa.Confirmed() {
+		return nil, ErrInvalidCredentials
+	}
+
+	ok, err := service.verifyCode(mfa, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		service.LoginThrottle.RecordFailure(user.Email, client.IP)
+		return nil, ErrInvalidMFACode
+	}
+	service.LoginThrottle.RecordSuccess(user.Email)
+
+	return service.TokenService.NewLoginResponse(user, client)
+}
+
+// Enroll genera un secreto TOTP pendiente de confirmar para el usuario autenticado
+func (service *MFAService) Enroll(ctx context.Context) (*MFAEnrollment, error) {
+	principal, err := requireCredentialAccess(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, err := service.IsEnabled(principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	user, err := service.UserRepo.GetUserByID(principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := service.MFARepo.SavePendingSecret(user.ID, secret); err != nil {
+		return nil, err
+	}
+
+	return &MFAEnrollment{Secret: secret, URI: auth.TOTPURI(service.Issuer, user.Email, secret)}, nil
+}
+
+// Confirm activa la verificación en dos pasos si el código es correcto y
+// devuelve los códigos de recuperación, que solo se muestran esta vez
+func (service *MFAService) Confirm(ctx context.Context, code string) ([]string, error) {
+	principal, err := requireCredentialAccess(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mfa, err := service.MFARepo.GetMFA(principal.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, err
+	}
+	if mfa.Confirmed() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := auth.ValidateTOTP(mfa.Secreto, code, time.Now(), mfa.UltimoPaso)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = newRecoveryCode(); err != nil {
+			return nil, err
+		}
+		hashes[i] = auth.HashOpaqueToken(normalizeRecoveryCode(codes[i]))
+	}
+
+	if err := service.MFARepo.Confirm(principal.UserID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable desactiva la verificación en dos pasos; exige un código válido
+func (service *MFAService) Disable(ctx context.Context, code string) error {
+	principal, err := requireCredentialAccess(ctx)
+	if err != nil {
+		return err
+	}
+
+	mfa, err := service.MFARepo.GetMFA(principal.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrMFANotEnrolled
+		}
+		return err
+	}
+
+	if mfa.Confirmed() {
+		ok, err := service.verifyCode(mfa, code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidMFACode
+		}
+	}
+	return service.MFARepo.Delete(principal.UserID)
+}
+
+// verifyCode acepta un código TOTP no usado antes o un código de recuperación
+func (service *MFAService) verifyCode(mfa *models.MFA, code string) (bool, error) {
+	if step, ok := auth.ValidateTOTP(mfa.Secreto, code, time.Now(), mfa.UltimoPaso); ok {
+		return service.MFARepo.UpdateLastStep(mfa.IdUsuario, step)
+	}
+	return service.MFARepo.UseRecoveryCode(mfa.IdUsuario, auth.HashOpaqueToken(normalizeRecoveryCode(code)))
+}
+
+// newRecoveryCode genera un código de 80 bits con el formato xxxx-xxxx-xxxx-xxxx
+func newRecoveryCode() (string, error) {
+	raw := make([]byte, 10)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	encoded := strings.ToLower(base32.StdEncoding.EncodeToString(raw))
+	return encoded[0:4] + "-" + encoded[4:8] + "-" + encoded[8:12] + "-" + encoded[12:16], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+}
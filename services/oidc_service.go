@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"dbconnection/auth"
+	"dbconnection/models"
+	"dbconnection/repositories"
+	"dbconnection/utils"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnknownProvider indica que el proveedor pedido no está configurado
+	ErrUnknownProvider = errors.New("proveedor de identidad desconocido")
+	// ErrInvalidOIDCState indica que la solicitud de inicio de sesión no existe, expiró o ya se usó
+	ErrInvalidOIDCState = errors.New("la solicitud de inicio de sesión expiró o ya se usó")
+	// ErrOIDCLoginFailed agrupa los errores al canjear el código o validar el ID token
+	ErrOIDCLoginFailed = errors.New("no se pudo validar la identidad con el proveedor")
+	// ErrOIDCEmailNotVerified indica que el proveedor no asegura que el correo sea del usuario
+	ErrOIDCEmailNotVerified = errors.New("el proveedor no confirmó el correo electrónico")
+)
+
+type OIDCService struct {
+	Providers    map[string]*auth.OIDCProvider
+	OIDCRepo     *repositories.OIDCRepository
+	UserRepo     *repositories.UserRepository
+	TokenService *TokenService
+	MFA          *MFAService
+	Audit        *AuditService
+	RedirectURL  string        // Página del frontend que recibe el código del proveedor
+	StateTTL     time.Duration // Tiempo para volver del proveedor
+	BcryptCost   int
+}
+
+// OIDCAuthorization es la URL del proveedor a la que el frontend envía al usuario
+type OIDCAuthorization struct {
+	URL   string `json:"authorization_url"`
+	State string `json:"state"`
+}
+
+// ProviderNames devuelve los proveedores configurados, ordenados
+func (service *OIDCService) ProviderNames() []string {
+	names := make([]string, 0, len(service.Providers))
+	for name := range service.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Begin inicia el flujo authorization code + PKCE: guarda el state, el nonce y
+// el code_verifier y devuelve la URL de autorización del proveedor
+func (service *OIDCService) Begin(ctx context.Context, providerName string) (*OIDCAuthorization, error) {
+	provider, ok := service.Providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	state, stateHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	nonce, _, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	verifier, challenge, err := auth.NewPKCE()
+	if err != nil {
+		return nil, err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, challenge, service.RedirectURL)
+	if err != nil {
+		log.Printf("Error al consultar el proveedor %s: %v", provider.Name, err)
+		return nil, ErrOIDCLoginFailed
+	}
+
+	err = service.OIDCRepo.CreateState(&models.OIDCState{
+		StateHash:   stateHash,
+		Proveedor:   provider.Name,
+		Verificador: verifier,
+		Nonce:       nonce,
+		ExpiraEn:    time.Now().UTC().Add(service.StateTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCAuthorization{URL: authURL, State: state}, nil
+}
+
+// Complete termina el flujo cuando el usuario vuelve del proveedor: canjea el
+// código, valida el ID token, busca o vincula la cuenta y emite nuestros tokens.
+// browserState es el state que guardó el navegador al iniciar el flujo; si no
+// coincide, el flujo lo inició otro navegador y se rechaza.
+func (service *OIDCService) Complete(ctx context.Context, state, browserState, code string, client ClientInfo) (*LoginResponse, error) {
+	if browserState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return nil, ErrInvalidOIDCState
+	}
+
+	stored, err := service.OIDCRepo.ConsumeState(auth.HashOpaqueToken(state))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidOIDCState
+		}
+		return nil, err
+	}
+
+	provider, ok := service.Providers[stored.Proveedor]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, code, stored.Verificador, service.RedirectURL)
+	if err != nil {
+		log.Printf("Error al canjear el código con %s: %v", provider.Name, err)
+		return nil, ErrOIDCLoginFailed
+	}
+	identity, err := provider.VerifyIDToken(ctx, rawIDToken, stored.Nonce)
+	if err != nil {
+		log.Printf("ID token de %s rechazado: %v", provider.Name, err)
+		return nil, ErrOIDCLoginFailed
+	}
+
+	user, err := service.resolveUser(ctx, provider.Name, identity)
+	if err != nil {
+		return nil, err
+	}
+	return service.MFA.StartLogin(user, client)
+}
+
+// resolveUser devuelve el usuario de la identidad externa. Si todavía no está
+// vinculada, se vincula con la cuenta que tenga el mismo correo (solo si el
+// proveedor lo verificó) o se crea una cuenta nueva.
+func (service *OIDCService) resolveUser(ctx context.Context, providerName string, identity *auth.OIDCIdentity) (*models.User, error) {
+	userID, err := service.OIDCRepo.FindIdentity(providerName, identity.Subject)
+	if err == nil {
+		return service.UserRepo.GetUserByID(userID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if !identity.EmailVerified || identity.Email == "" {
+		return nil, ErrOIDCEmailNotVerified
+	}
+
+	user, err := service.UserRepo.GetUserByEmail(identity.Email)
+	switch {
+	case err == nil:
+		// Si quien creó la cuenta nunca demostró ser dueño del correo, se descarta
+		// su contraseña para que no conserve acceso a la cuenta vinculada
+		if err := confirmEmailOwnership(ctx, service.UserRepo, service.TokenService, service.Audit, service.BcryptCost, user); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, repositories.ErrUserNotFound):
+		user, err = service.createUser(ctx, identity)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if err := service.OIDCRepo.LinkIdentity(user.ID, providerName, identity.Subject, identity.Email); err != nil {
+		return nil, err
+	}
+	log.Printf("Identidad de %s vinculada al usuario %d", providerName, user.ID)
+	return user, nil
+}
+
+// confirmEmailOwnership marca como verificado el correo del usuario cuando
+// alguien demuestra controlarlo. Si la cuenta nunca había verificado un correo,
+// se reclama con claimUnverifiedAccount; si lo verificó antes y después lo
+// cambió, la contraseña y las sesiones son del mismo dueño y se conservan.
+func confirmEmailOwnership(ctx context.Context, users *repositories.UserRepository, tokens *TokenService, audit *AuditService, bcryptCost int, user *models.User) error {
+	if user.EmailVerificado {
+		return nil
+	}
+	if !user.VerificadoAlgunaVez {
+		return claimUnverifiedAccount(ctx, users, tokens, audit, bcryptCost, user)
+	}
+
+	before := *user
+	if err := users.SetEmailVerified(user.ID, true); err != nil {
+		return err
+	}
+	user.EmailVerificado = true
+	audit.Record(ctx, AuditUpdate, AuditEntityUser, user.ID, &before, user)
+	return nil
+}
+
+// claimUnverifiedAccount se usa cuando alguien demuestra ser dueño del correo de
+// una cuenta sin verificar (por OIDC o con un enlace de inicio de sesión).
+// Quien creó la cuenta nunca lo demostró, así que se reemplaza la contraseña por
+// una inutilizable y se cierran las sesiones abiertas antes de marcar el correo
+// como verificado.
+func claimUnverifiedAccount(ctx context.Context, users *repositories.UserRepository, tokens *TokenService, audit *AuditService, bcryptCost int, user *models.User) error {
+	before := *user
+	hash, err := unusablePasswordHash(bcryptCost)
+	if err != nil {
+		return err
+	}
+	if err := users.UpdatePassword(user.ID, hash); err != nil {
+		return err
+	}
+	if err := tokens.RevokeAllSessions(user.ID); err != nil {
+		return err
+	}
+	if err := users.SetEmailVerified(user.ID, true); err != nil {
+		return err
+	}
+	user.Password = hash
+	user.EmailVerificado = true
+	user.VerificadoAlgunaVez = true
+	audit.Record(ctx, AuditUpdate, AuditEntityUser, user.ID, &before, user)
+	return nil
+}
+
+// createUser crea una cuenta sin contraseña utilizable para la identidad externa
+func (service *OIDCService) createUser(ctx context.Context, identity *auth.OIDCIdentity) (*models.User, error) {
+	hash, err := unusablePasswordHash(service.BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	name := identity.Name
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+	user, err := service.UserRepo.CreateUser(&models.User{Nombre: name, Email: identity.Email, Password: hash})
+	if err != nil {
+		return nil, err
+	}
+	if err := service.UserRepo.SetEmailVerified(user.ID, true); err != nil {
+		return nil, err
+	}
+	user.EmailVerificado = true
+	user.VerificadoAlgunaVez = true
+	service.Audit.Record(ctx, AuditCreate, AuditEntityUser, user.ID, nil, user)
+	return user, nil
+}
+
+// unusablePasswordHash genera el hash de una contraseña aleatoria que nadie
+// conoce; el usuario puede fijar una propia con el flujo de restablecimiento
+func unusablePasswordHash(cost int) (string, error) {
+	random, _, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	hash, err := utils.HashPassword(random, cost)
+	if err != nil {
+		return "", fmt.Errorf("error al generar el hash de la contraseña: %v", err)
+	}
+	return hash, nil
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"dbconnection/auth"
+	"dbconnection/mailer"
+	"dbconnection/repositories"
+	"dbconnection/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+)
+
+var (
+	// ErrInvalidResetToken indica que el enlace de restablecimiento no existe, expiró o ya se usó
+	ErrInvalidResetToken = errors.New("el enlace para restablecer la contraseña es inválido o expiró")
+	// ErrEmptyPassword indica que no se envió una contraseña
+	ErrEmptyPassword = errors.New("la contraseña no puede estar vacía")
+)
+
+type PasswordResetService struct {
+	UserRepo       *repositories.UserRepository
+	ResetRepo      *repositories.PasswordResetRepository
+	TokenService   *TokenService
+	Audit          *AuditService
+	PasswordPolicy *auth.PasswordPolicy
+	Mailer         mailer.Mailer
+	BaseURL        string
+	TTL            time.Duration
+	ResendInterval time.Duration // Tiempo mínimo entre dos enlaces para la misma cuenta
+	BcryptCost     int
+}
+
+// ForgotPassword envía en segundo plano un enlace de un solo uso al correo del
+// usuario. Vuelve de inmediato exista o no la cuenta, para que ni la respuesta
+// ni el tiempo que tarda revelen qué correos están registrados.
+func (service *PasswordResetService) ForgotPassword(email string) {
+	go func() {
+		if err := service.sendResetLink(email); err != nil {
+			log.Printf("Error al procesar la solicitud de restablecimiento: %v", err)
+		}
+	}()
+}
+
+// sendResetLink genera el token y envía el correo; si el correo no está
+// registrado o ya se envió un enlace hace poco no hace nada
+func (service *PasswordResetService) sendResetLink(email string) error {
+	user, err := service.UserRepo.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			log.Printf("Solicitud de restablecimiento para un correo no registrado")
+			return nil
+		}
+		return err
+	}
+
+	// Evitar que se use el endpoint para llenar la bandeja de entrada de alguien
+	lastCreated, err := service.ResetRepo.LastCreatedAt(user.ID)
+	if err != nil {
+		return err
+	}
+	if lastCreated.Valid && time.Since(lastCreated.Time) < service.ResendInterval {
+		log.Printf("Enlace de restablecimiento para el usuario %d omitido: se pidió hace poco", user.ID)
+		return nil
+	}
+
+	token, tokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return err
+	}
+	if err := service.ResetRepo.CreateToken(user.ID, tokenHash, time.Now().UTC().Add(service.TTL)); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", service.BaseURL, url.QueryEscape(token))
+	return service.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Restablecer tu contraseña",
+		Body: fmt.Sprintf("Hola %s,\n\nPara elegir una nueva contraseña abre este enlace:\n%s\n\n"+
+			"El enlace vence en %s y solo se puede usar una vez. Si no lo solicitaste, ignora este correo.",
+			user.Nombre, link, service.TTL),
+	})
+}
+
+// ResetPassword cambia la contraseña usando el token recibido por correo y
+// cierra todas las sesiones abiertas del usuario
+func (service *PasswordResetService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	if newPassword == "" {
+		return ErrEmptyPassword
+	}
+
+	// La contraseña se valida antes de consumir el token, para que una
+	// contraseña rechazada no obligue a pedir otro enlace
+	tokenHash := auth.HashOpaqueToken(token)
+	userID, err := service.ResetRepo.FindValidToken(tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	user, err := service.UserRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := checkNewPassword(service.PasswordPolicy, "contraseña", newPassword, user); err != nil {
+		return err
+	}
+
+	userID, err = service.ResetRepo.ConsumeToken(tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	hash, err := utils.HashPassword(newPassword, service.BcryptCost)
+	if err != nil {
+		return err
+	}
+	if err := service.UserRepo.UpdatePassword(userID, hash); err != nil {
+		return err
+	}
+	service.Audit.Record(ctx, AuditPasswordReset, AuditEntityUser, userID, nil, nil)
+
+	if err := service.TokenService.RevokeAllSessions(userID); err != nil {
+		log.Printf("No se pudieron revocar las sesiones del usuario %d: %v", userID, err)
+	}
+	return nil
+}
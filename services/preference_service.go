@@ -1,19 +1,34 @@
 package services
 
 import (
+	"context"
+	"dbconnection/auth"
 	"dbconnection/models"
 	"dbconnection/repositories"
 )
 
 type PreferenceService struct {
 	PreferenceRepo *repositories.PreferenceRepository
+	Audit          *AuditService
 }
 
-func (service *PreferenceService) UpdatePreferences(pref *models.Preference, token string) (*models.Preference, error) {
-	return service.PreferenceRepo.UpdatePreferences(pref, token)
+func (service *PreferenceService) UpdatePreferences(ctx context.Context, pref *models.Preference) (*models.Preference, error) {
+	if err := authorizePreferenceOwner(ctx, pref); err != nil {
+		return nil, err
+	}
+
+	// El estado anterior solo se usa para la auditoría
+	current, _ := service.PreferenceRepo.GetPreferencesByUserID(pref.IdUsuario)
+
+	updated, err := service.PreferenceRepo.UpdatePreferences(pref)
+	if err != nil {
+		return nil, err
+	}
+	service.Audit.Record(ctx, AuditUpdate, AuditEntityPreference, updated.ID, current, updated)
+	return updated, nil
 }
-func (service *PreferenceService) GetPreferencesByUserID(idUsuario int, token string) (*models.Preference, error) {
-	pref, err := service.PreferenceRepo.GetPreferencesByUserID(idUsuario, token)
+func (service *PreferenceService) GetPreferencesByUserID(idUsuario int) (*models.Preference, error) {
+	pref, err := service.PreferenceRepo.GetPreferencesByUserID(idUsuario)
 	if err != nil {
 		if err.Error() == "La tabla 'preferencias_usuarios' no existe en la base de datos" {
 			return nil, nil // No content, sin datos para devolver
@@ -24,6 +39,31 @@ func (service *PreferenceService) GetPreferencesByUserID(idUsuario int, token st
 }
 
 // CreatePreferences - Llama al repositorio para crear nuevas preferencias
-func (service *PreferenceService) CreatePreferences(pref *models.Preference, token string) (*models.Preference, error) {
-	return service.PreferenceRepo.CreatePreferences(pref, token)
+func (service *PreferenceService) CreatePreferences(ctx context.Context, pref *models.Preference) (*models.Preference, error) {
+	if err := authorizePreferenceOwner(ctx, pref); err != nil {
+		return nil, err
+	}
+
+	created, err := service.PreferenceRepo.CreatePreferences(pref)
+	if err != nil {
+		return nil, err
+	}
+	service.Audit.Record(ctx, AuditCreate, AuditEntityPreference, created.ID, nil, created)
+	return created, nil
+}
+
+// authorizePreferenceOwner asigna las preferencias al usuario autenticado si no
+// se indicó un id_usuario, y rechaza las de otro usuario salvo para administradores
+func authorizePreferenceOwner(ctx context.Context, pref *models.Preference) error {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return err
+	}
+	if pref.IdUsuario == 0 {
+		pref.IdUsuario = principal.UserID
+	}
+	if !auth.Can(principal, auth.ActionUpdatePreferences, pref.IdUsuario) {
+		return ErrForbidden
+	}
+	return nil
 }
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"dbconnection/auth"
+	"dbconnection/mailer"
+	"dbconnection/models"
+	"dbconnection/repositories"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrRegistrationClosed indica que la feria ya terminó y no admite inscripciones
+var ErrRegistrationClosed = errors.New("la feria ya terminó; no admite inscripciones")
+
+type RegistrationService struct {
+	RegistrationRepo *repositories.RegistrationRepository
+	FairRepo         *repositories.FairRepository
+	UserRepo         *repositories.UserRepository
+	Mailer           mailer.Mailer
+}
+
+// Register inscribe al usuario autenticado en la feria. Si no queda lugar
+// queda en la lista de espera. Repetir la inscripción devuelve la existente
+// con created en false.
+func (service *RegistrationService) Register(ctx context.Context, fairID int) (registration *models.Registration, created bool, err error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if !auth.Can(principal, auth.ActionRegisterFair, 0) {
+		return nil, false, ErrForbidden
+	}
+
+	fair, err := service.getFair(fairID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !fair.FechaFin.IsZero() && !time.Now().Before(fair.FechaFin) {
+		return nil, false, ErrRegistrationClosed
+	}
+
+	registration, created, err = service.RegistrationRepo.Register(fairID, principal.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, ErrNotFound
+		}
+		return nil, false, err
+	}
+	if err := service.RegistrationRepo.FillPosition(registration); err != nil {
+		return nil, false, err
+	}
+	return registration, created, nil
+}
+
+// Unregister cancela la inscripción del usuario autenticado; el lugar que deja
+// pasa al primero de la lista de espera
+func (service *RegistrationService) Unregister(ctx context.Context, fairID int) error {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return err
+	}
+	if !auth.Can(principal, auth.ActionRegisterFair, 0) {
+		return ErrForbidden
+	}
+
+	promoted, err := service.RegistrationRepo.Cancel(fairID, principal.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	service.notifyPromoted(fairID, promoted)
+	return nil
+}
+
+// GetRegistration devuelve la inscripción del usuario autenticado en la feria
+func (service *RegistrationService) GetRegistration(ctx context.Context, fairID int) (*models.Registration, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !auth.Can(principal, auth.ActionRegisterFair, 0) {
+		return nil, ErrForbidden
+	}
+
+	registration, err := service.RegistrationRepo.GetRegistration(fairID, principal.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return registration, err
+}
+
+// ListRegistrations devuelve los inscritos de la feria; solo para su
+// organizador o un administrador. estado filtra por confirmed o waitlist.
+func (service *RegistrationService) ListRegistrations(ctx context.Context, fairID int, estado string) (*models.RegistrationSummary, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fair, err := service.getFair(fairID)
+	if err != nil {
+		return nil, err
+	}
+	if !auth.Can(principal, auth.ActionReadRegistrations, fair.IdUsuario) {
+		return nil, ErrForbidden
+	}
+	if estado != "" && estado != models.RegistrationConfirmed && estado != models.RegistrationWaitlist {
+		return nil, &ValidationError{Fields: map[string][]string{"estado": {"debe ser confirmed o waitlist"}}}
+	}
+
+	registrations, err := service.RegistrationRepo.ListRegistrations(fairID, estado)
+	if err != nil {
+		return nil, err
+	}
+	confirmed, waitlist, err := service.RegistrationRepo.CountRegistrations(fairID)
+	if err != nil {
+		return nil, err
+	}
+	_, present, err := service.RegistrationRepo.CountAttendance(fairID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.RegistrationSummary{
+		Capacidad:     fair.Capacidad,
+		Confirmadas:   confirmed,
+		EnEspera:      waitlist,
+		Presentes:     present,
+		Inscripciones: registrations,
+	}, nil
+}
+
+// FillFromWaitlist confirma a los que ahora caben en la feria, por ejemplo
+// después de aumentar o quitar su capacidad. Los errores solo se registran:
+// la lista de espera se vuelve a revisar en la siguiente cancelación.
+func (service *RegistrationService) FillFromWaitlist(fairID int) {
+	if service == nil {
+		return
+	}
+	promoted, err := service.RegistrationRepo.PromoteWaitlist(fairID)
+	if err != nil {
+		log.Printf("Error al promover la lista de espera de la feria %d: %v", fairID, err)
+		return
+	}
+	service.notifyPromoted(fairID, promoted)
+}
+
+// notifyPromoted avisa por correo a quienes pasaron de la lista de espera a
+// confirmados; un correo que falla no deshace la confirmación
+func (service *RegistrationService) notifyPromoted(fairID int, promoted []models.Registration) {
+	if len(promoted) == 0 || service.Mailer == nil {
+		return
+	}
+	fair, err := service.FairRepo.GetFairByID(fairID)
+	if err != nil {
+		log.Printf("Error al obtener la feria %d para avisar a los confirmados: %v", fairID, err)
+		return
+	}
+	for _, registration := range promoted {
+		user, err := service.UserRepo.GetUserByID(registration.IdUsuario)
+		if err != nil {
+			log.Printf("Error al obtener al usuario %d para avisarle de su inscripción: %v", registration.IdUsuario, err)
+			continue
+		}
+		err = service.Mailer.Send(mailer.Message{
+			To:      user.Email,
+			Subject: "Ya tienes lugar en " + fair.Titulo,
+			Body: fmt.Sprintf("Hola %s,\n\nSe liberó un lugar en la feria \"%s\" y tu inscripción quedó confirmada.",
+				user.Nombre, fair.Titulo),
+		})
+		if err != nil {
+			log.Printf("Error al avisar al usuario %d de su inscripción confirmada: %v", registration.IdUsuario, err)
+		}
+	}
+}
+
+func (service *RegistrationService) getFair(fairID int) (*models.Fair, error) {
+	fair, err := service.FairRepo.GetFairByID(fairID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return fair, err
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"dbconnection/mailer"
+	"dbconnection/models"
+	"dbconnection/repositories"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	maxUserAgentLength   = 255 // Tamaño de la columna user_agent
+	defaultHistoryLength = 20
+	maxHistoryLength     = 100
+)
+
+// ClientInfo identifica desde dónde se hace una solicitud de inicio de sesión
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+type SessionService struct {
+	SessionRepo     *repositories.SessionRepository
+	TokenRepo       *repositories.TokenRepository
+	UserRepo        *repositories.UserRepository
+	Mailer          mailer.Mailer
+	NewDeviceAlerts bool // Avisar por correo de los inicios de sesión desde un dispositivo nuevo
+}
+
+// RecordLogin guarda el inicio de sesión que abrió la sesión indicada. Un
+// dispositivo es nuevo si el usuario nunca inició sesión con ese user agent;
+// el primer inicio de sesión de la cuenta no cuenta como dispositivo nuevo.
+func (service *SessionService) RecordLogin(user *models.User, sessionID string, client ClientInfo) error {
+	userAgent := client.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+
+	hasHistory, sameDevice, err := service.SessionRepo.HasLoggedIn(user.ID, userAgent)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	session := &models.Session{
+		ID:               sessionID,
+		IdUsuario:        user.ID,
+		IP:               client.IP,
+		UserAgent:        userAgent,
+		DispositivoNuevo: hasHistory && !sameDevice,
+		CreadoEn:         now,
+		UltimoUsoEn:      now,
+	}
+	if err := service.SessionRepo.CreateSession(session); err != nil {
+		return err
+	}
+
+	if session.DispositivoNuevo && service.NewDeviceAlerts {
+		service.sendNewDeviceAlert(user, session)
+	}
+	return nil
+}
+
+// TouchSession registra que la sesión se usó para rotar sus tokens
+func (service *SessionService) TouchSession(sessionID string) {
+	service.SessionRepo.TouchSession(sessionID)
+}
+
+// ListActiveSessions devuelve las sesiones abiertas del usuario autenticado,
+// marcando la de la solicitud en curso
+func (service *SessionService) ListActiveSessions(ctx context.Context) ([]models.Session, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := service.SessionRepo.ListActiveSessions(principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	markCurrent(sessions, principal.SessionID)
+	return sessions, nil
+}
+
+// LoginHistory devuelve los últimos inicios de sesión del usuario autenticado
+func (service *SessionService) LoginHistory(ctx context.Context, limit int) ([]models.Session, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultHistoryLength
+	}
+	if limit > maxHistoryLength {
+		limit = maxHistoryLength
+	}
+
+	sessions, err := service.SessionRepo.ListHistory(principal.UserID, limit)
+	if err != nil {
+		return nil, err
+	}
+	markCurrent(sessions, principal.SessionID)
+	return sessions, nil
+}
+
+// RevokeSession cierra una sesión del usuario autenticado; sus tokens de
+// acceso dejan de aceptarse de inmediato
+func (service *SessionService) RevokeSession(ctx context.Context, sessionID string) error {
+	principal, err := requireCredentialAccess(ctx)
+	if err != nil {
+		return err
+	}
+
+	session, err := service.SessionRepo.GetSession(sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	// Las sesiones de otros usuarios se responden como inexistentes
+	if session.IdUsuario != principal.UserID {
+		return ErrNotFound
+	}
+
+	if err := service.TokenRepo.RevokeFamily(sessionID); err != nil {
+		return err
+	}
+	log.Printf("Sesión %s del usuario %d revocada", sessionID, principal.UserID)
+	return nil
+}
+
+// sendNewDeviceAlert avisa al usuario del inicio de sesión; un error al enviar
+// el correo no impide el inicio de sesión
+func (service *SessionService) sendNewDeviceAlert(user *models.User, session *models.Session) {
+	err := service.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Nuevo inicio de sesión en tu cuenta",
+		Body: fmt.Sprintf("Hola %s,\n\nSe inició sesión en tu cuenta desde un dispositivo nuevo:\n\n"+
+			"Fecha: %s (UTC)\nIP: %s\nNavegador: %s\n\n"+
+			"Si fuiste tú, no tienes que hacer nada. Si no lo reconoces, cierra esa sesión desde la lista de sesiones activas y cambia tu contraseña.",
+			user.Nombre, session.CreadoEn.Format("2006-01-02 15:04"), session.IP, session.UserAgent),
+	})
+	if err != nil {
+		log.Printf("Error al enviar el aviso de nuevo dispositivo al usuario %d: %v", user.ID, err)
+	}
+}
+
+func markCurrent(sessions []models.Session, currentID string) {
+	for i := range sessions {
+		sessions[i].Actual = currentID != "" && sessions[i].ID == currentID
+	}
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"dbconnection/auth"
+	"dbconnection/models"
+	"dbconnection/repositories"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxStandCodeLength     = 20
+	maxStandLocationLength = 100
+	maxStandExhibitors     = 20
+)
+
+// StandForm son los datos de un stand que envía el organizador
+type StandForm struct {
+	Codigo      string
+	Ubicacion   string
+	Expositores []int // IDs de los usuarios expositores
+}
+
+type StandService struct {
+	StandRepo *repositories.StandRepository
+	FairRepo  *repositories.FairRepository
+	UserRepo  *repositories.UserRepository
+	Audit     *AuditService
+}
+
+// ListStands devuelve los stands de la feria; se pueden leer sin autenticarse
+func (service *StandService) ListStands(ctx context.Context, fairID int) ([]models.Stand, error) {
+	if err := authorizeRead(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := service.getFair(fairID); err != nil {
+		return nil, err
+	}
+	return service.StandRepo.ListStands(fairID)
+}
+
+// GetStand devuelve un stand de la feria
+func (service *StandService) GetStand(ctx context.Context, fairID, standID int) (*models.Stand, error) {
+	if err := authorizeRead(ctx); err != nil {
+		return nil, err
+	}
+	stand, err := service.StandRepo.GetStand(fairID, standID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return stand, err
+}
+
+// CreateStand crea un stand en la feria; solo su organizador o un administrador
+func (service *StandService) CreateStand(ctx context.Context, fairID int, form StandForm) (*models.Stand, error) {
+	if err := service.authorize(ctx, fairID, auth.ActionManageStands); err != nil {
+		return nil, err
+	}
+
+	stand := &models.Stand{IdFeria: fairID}
+	if err := service.save(stand, form); err != nil {
+		return nil, err
+	}
+	created, err := service.StandRepo.GetStand(fairID, stand.ID)
+	if err != nil {
+		return nil, err
+	}
+	service.Audit.Record(ctx, AuditCreate, AuditEntityStand, created.ID, nil, created)
+	return created, nil
+}
+
+// UpdateStand reemplaza el código, la ubicación y los expositores del stand
+func (service *StandService) UpdateStand(ctx context.Context, fairID, standID int, form StandForm) (*models.Stand, error) {
+	if err := service.authorize(ctx, fairID, auth.ActionManageStands); err != nil {
+		return nil, err
+	}
+	existing, err := service.StandRepo.GetStand(fairID, standID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	stand := &models.Stand{ID: standID, IdFeria: fairID}
+	if err := service.save(stand, form); err != nil {
+		return nil, err
+	}
+	updated, err := service.StandRepo.GetStand(fairID, standID)
+	if err != nil {
+		return nil, err
+	}
+	service.Audit.Record(ctx, AuditUpdate, AuditEntityStand, standID, existing, updated)
+	return updated, nil
+}
+
+// DeleteStand borra el stand y sus asignaciones
+func (service *StandService) DeleteStand(ctx context.Context, fairID, standID int) error {
+	if err := service.authorize(ctx, fairID, auth.ActionDeleteStand); err != nil {
+		return err
+	}
+	existing, err := service.StandRepo.GetStand(fairID, standID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	if err := service.StandRepo.DeleteStand(fairID, standID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	service.Audit.Record(ctx, AuditDelete, AuditEntityStand, standID, existing, nil)
+	return nil
+}
+
+// save valida el formulario y guarda el stand. Un expositor no puede estar en
+// dos stands de la misma feria ni en stands de ferias con fechas que se cruzan.
+func (service *StandService) save(stand *models.Stand, form StandForm) error {
+	problems := map[string][]string{}
+
+	stand.Codigo = strings.TrimSpace(form.Codigo)
+	stand.Ubicacion = strings.TrimSpace(form.Ubicacion)
+	if stand.Codigo == "" {
+		problems["codigo"] = append(problems["codigo"], "es obligatorio")
+	} else if utf8.RuneCountInString(stand.Codigo) > maxStandCodeLength {
+		problems["codigo"] = append(problems["codigo"], fmt.Sprintf("admite hasta %d caracteres", maxStandCodeLength))
+	}
+	if utf8.RuneCountInString(stand.Ubicacion) > maxStandLocationLength {
+		problems["ubicacion"] = append(problems["ubicacion"], fmt.Sprintf("admite hasta %d caracteres", maxStandLocationLength))
+	}
+
+	userIDs := []int{}
+	seen := map[int]bool{}
+	for _, id := range form.Expositores {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		userIDs = append(userIDs, id)
+	}
+	if len(userIDs) > maxStandExhibitors {
+		problems["expositores"] = append(problems["expositores"], fmt.Sprintf("admite hasta %d expositores", maxStandExhibitors))
+	} else {
+		for _, id := range userIDs {
+			user, err := service.UserRepo.GetUserByID(id)
+			if err != nil {
+				if !errors.Is(err, sql.ErrNoRows) {
+					return err
+				}
+				problems["expositores"] = append(problems["expositores"], fmt.Sprintf("el usuario %d no existe", id))
+				continue
+			}
+			if user.Rol != auth.RoleExhibitor {
+				problems["expositores"] = append(problems["expositores"], fmt.Sprintf("el usuario %d no es expositor", id))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Fields: problems}
+	}
+
+	conflicts, err := service.StandRepo.SaveStand(stand, userIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrStandCodeTaken):
+			return &ValidationError{Fields: map[string][]string{"codigo": {"ya existe en esta feria"}}}
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		}
+		return err
+	}
+	if len(conflicts) > 0 {
+		return &ValidationError{Fields: map[string][]string{"expositores": standConflictMessages(conflicts, stand.IdFeria)}}
+	}
+	return nil
+}
+
+// standConflictMessages describe las asignaciones que se cruzarían; fairID es
+// la feria que se está modificando
+func standConflictMessages(conflicts []models.StandConflict, fairID int) []string {
+	messages := make([]string, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		if conflict.IdFeria == fairID {
+			messages = append(messages, fmt.Sprintf("el usuario %d ya está en el stand %s de esta feria", conflict.IdUsuario, conflict.Codigo))
+		} else {
+			messages = append(messages, fmt.Sprintf("el usuario %d ya está en el stand %s de la feria \"%s\", que se cruza en fechas",
+				conflict.IdUsuario, conflict.Codigo, conflict.Titulo))
+		}
+	}
+	return messages
+}
+
+// authorize verifica que la feria exista y que el usuario autenticado pueda
+// ejecutar la acción sobre sus stands
+func (service *StandService) authorize(ctx context.Context, fairID int, action auth.Action) error {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return err
+	}
+	fair, err := service.getFair(fairID)
+	if err != nil {
+		return err
+	}
+	if !auth.Can(principal, action, fair.IdUsuario) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (service *StandService) getFair(fairID int) (*models.Fair, error) {
+	fair, err := service.FairRepo.GetFairByID(fairID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return fair, err
+}
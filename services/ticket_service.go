@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"dbconnection/auth"
+	"dbconnection/models"
+	"dbconnection/repositories"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrTicketUnavailable indica que la inscripción está en lista de espera y todavía no tiene boleto
+	ErrTicketUnavailable = errors.New("solo las inscripciones confirmadas tienen boleto")
+	// ErrInvalidTicket indica que el boleto no tiene una firma válida, venció o es de otra feria
+	ErrInvalidTicket = errors.New("el boleto es inválido")
+	// ErrTicketCancelled indica que la inscripción del boleto se canceló
+	ErrTicketCancelled = errors.New("el boleto fue cancelado")
+	// ErrAlreadyCheckedIn indica que el boleto ya se usó para entrar
+	ErrAlreadyCheckedIn = errors.New("el boleto ya se usó para entrar")
+)
+
+// ticketGracePeriod es el tiempo que el boleto sigue valiendo después del fin de la feria
+const ticketGracePeriod = 24 * time.Hour
+
+type TicketService struct {
+	RegistrationRepo *repositories.RegistrationRepository
+	FairRepo         *repositories.FairRepository
+	Tokens           *auth.TokenManager
+}
+
+// GetTicket devuelve el boleto firmado de la inscripción confirmada del usuario
+// autenticado. El boleto se puede volver a pedir; siempre lleva el mismo código.
+func (service *TicketService) GetTicket(ctx context.Context, fairID int) (*models.Ticket, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !auth.Can(principal, auth.ActionRegisterFair, 0) {
+		return nil, ErrForbidden
+	}
+
+	registration, err := service.RegistrationRepo.GetRegistration(fairID, principal.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if registration.Estado != models.RegistrationConfirmed {
+		return nil, ErrTicketUnavailable
+	}
+	fair, err := service.FairRepo.GetFairByID(fairID)
+	if err != nil {
+		return nil, err
+	}
+
+	code := registration.CodigoBoleto
+	if code == "" {
+		newCode, err := auth.NewTicketCode()
+		if err != nil {
+			return nil, err
+		}
+		if code, err = service.RegistrationRepo.EnsureTicketCode(registration.ID, newCode); err != nil {
+			return nil, err
+		}
+	}
+
+	var expiresAt time.Time
+	if !fair.FechaFin.IsZero() {
+		expiresAt = fair.FechaFin.Add(ticketGracePeriod)
+	}
+	signed, err := service.Tokens.IssueTicket(auth.TicketClaims{
+		RegistrationID: registration.ID,
+		FairID:         fairID,
+		UserID:         registration.IdUsuario,
+		Code:           code,
+	}, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Ticket{IdInscripcion: registration.ID, IdFeria: fairID, Boleto: signed, EntradaEn: registration.EntradaEn}, nil
+}
+
+// CheckIn registra la entrada del boleto leído en la puerta de la feria; solo
+// para el organizador de la feria o un administrador. Devuelve la inscripción
+// y la asistencia actualizada.
+func (service *TicketService) CheckIn(ctx context.Context, fairID int, ticket string) (*models.CheckIn, error) {
+	fair, err := service.authorizeFair(ctx, fairID, auth.ActionCheckIn)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := service.Tokens.ParseTicket(ticket)
+	if err != nil || claims.FairID != fairID {
+		return nil, ErrInvalidTicket
+	}
+
+	registration, checkedIn, err := service.RegistrationRepo.CheckIn(fairID, claims.RegistrationID, claims.Code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTicketCancelled
+		}
+		return nil, err
+	}
+	if !checkedIn {
+		if registration.EntradaEn != nil {
+			return nil, ErrAlreadyCheckedIn
+		}
+		return nil, ErrTicketUnavailable
+	}
+
+	attendance, err := service.attendance(fair)
+	if err != nil {
+		return nil, err
+	}
+	return &models.CheckIn{Inscripcion: *registration, Asistencia: *attendance}, nil
+}
+
+// Attendance devuelve cuántos confirmados ya entraron a la feria; solo para su
+// organizador o un administrador
+func (service *TicketService) Attendance(ctx context.Context, fairID int) (*models.Attendance, error) {
+	fair, err := service.authorizeFair(ctx, fairID, auth.ActionReadRegistrations)
+	if err != nil {
+		return nil, err
+	}
+	return service.attendance(fair)
+}
+
+func (service *TicketService) attendance(fair *models.Fair) (*models.Attendance, error) {
+	confirmed, present, err := service.RegistrationRepo.CountAttendance(fair.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Attendance{
+		IdFeria:     fair.ID,
+		Capacidad:   fair.Capacidad,
+		Confirmadas: confirmed,
+		Presentes:   present,
+		Pendientes:  confirmed - present,
+	}, nil
+}
+
+// authorizeFair verifica que la feria exista y que el usuario autenticado pueda
+// ejecutar la acción sobre ella
+func (service *TicketService) authorizeFair(ctx context.Context, fairID int, action auth.Action) (*models.Fair, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fair, err := service.FairRepo.GetFairByID(fairID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if !auth.Can(principal, action, fair.IdUsuario) {
+		return nil, ErrForbidden
+	}
+	return fair, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"dbconnection/auth"
+	"dbconnection/config"
+	"dbconnection/db/dbtest"
+	"dbconnection/models"
+	"dbconnection/repositories"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTicketCheckIn(t *testing.T) {
+	database := dbtest.Open(t)
+	tokens, err := auth.NewTokenManager(&config.Config{JWTTemporaryKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fairRepo := &repositories.FairRepository{DB: database}
+	registrationRepo := &repositories.RegistrationRepository{DB: database}
+	service := &TicketService{RegistrationRepo: registrationRepo, FairRepo: fairRepo, Tokens: tokens}
+
+	ownerID := dbtest.CreateUser(t, database, "organizador", auth.RoleOrganizer)
+	visitorID := dbtest.CreateUser(t, database, "visitante", auth.RoleVisitor)
+	owner := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: ownerID, Role: auth.RoleOrganizer, EmailVerified: true})
+	visitor := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: visitorID, Role: auth.RoleVisitor, EmailVerified: true})
+
+	start := time.Now().Add(time.Hour)
+	fairs := make([]int, 2)
+	for i := range fairs {
+		fair, err := fairRepo.CreateFair(&models.Fair{Titulo: "Feria", FechaInicio: start, FechaFin: start.Add(8 * time.Hour), ZonaHoraria: "UTC", IdUsuario: ownerID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fairs[i] = fair.ID
+		if _, _, err := registrationRepo.Register(fair.ID, visitorID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fairID, otherFairID := fairs[0], fairs[1]
+
+	ticket, err := service.GetTicket(visitor, fairID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := service.GetTicket(visitor, fairID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, _ := tokens.ParseTicket(again.Boleto); claims == nil || claims.RegistrationID != ticket.IdInscripcion {
+		t.Fatalf("volver a pedir el boleto devolvió otra inscripción")
+	}
+
+	if _, err := service.CheckIn(owner, otherFairID, ticket.Boleto); !errors.Is(err, ErrInvalidTicket) {
+		t.Errorf("boleto de otra feria: err = %v, se esperaba ErrInvalidTicket", err)
+	}
+	if _, err := service.CheckIn(visitor, fairID, ticket.Boleto); !errors.Is(err, ErrForbidden) {
+		t.Errorf("entrada registrada por el visitante: err = %v, se esperaba ErrForbidden", err)
+	}
+
+	checkIn, err := service.CheckIn(owner, fairID, ticket.Boleto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkIn.Inscripcion.EntradaEn == nil || checkIn.Asistencia.Presentes != 1 || checkIn.Asistencia.Pendientes != 0 {
+		t.Errorf("entrada = %+v", checkIn)
+	}
+	if _, err := service.CheckIn(owner, fairID, ticket.Boleto); !errors.Is(err, ErrAlreadyCheckedIn) {
+		t.Errorf("segunda entrada: err = %v, se esperaba ErrAlreadyCheckedIn", err)
+	}
+
+	// Al cancelar la inscripción el boleto deja de valer, aunque vuelva a inscribirse
+	otherTicket, err := service.GetTicket(visitor, otherFairID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registrationRepo.Cancel(otherFairID, visitorID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := registrationRepo.Register(otherFairID, visitorID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.CheckIn(owner, otherFairID, otherTicket.Boleto); !errors.Is(err, ErrTicketCancelled) {
+		t.Errorf("boleto cancelado: err = %v, se esperaba ErrTicketCancelled", err)
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"dbconnection/auth"
+	"dbconnection/models"
+	"dbconnection/repositories"
+	"errors"
+	"log"
+	"time"
+)
+
+// ErrInvalidRefreshToken agrupa los motivos por los que se rechaza un token de
+// actualización (inexistente, expirado, revocado o reutilizado)
+var ErrInvalidRefreshToken = errors.New("token de actualización inválido o expirado")
+
+type TokenService struct {
+	TokenRepo  *repositories.TokenRepository
+	UserRepo   *repositories.UserRepository
+	Tokens     *auth.TokenManager
+	RefreshTTL time.Duration
+	Sessions   *SessionService // Historial de inicios de sesión
+}
+
+// TokenPair es el par de tokens que se entrega al iniciar sesión o al rotar
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int // Segundos de vigencia del token de acceso
+}
+
+// StartSession abre una nueva sesión (familia de tokens) para el usuario y la
+// registra en su historial de inicios de sesión
+func (service *TokenService) StartSession(user *models.User, client ClientInfo) (*TokenPair, error) {
+	sessionID, err := auth.NewSessionID()
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := service.issuePair(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if service.Sessions != nil {
+		if err := service.Sessions.RecordLogin(user, sessionID, client); err != nil {
+			log.Printf("Error al registrar el inicio de sesión del usuario %d: %v", user.ID, err)
+		}
+	}
+	return tokens, nil
+}
+
+// NewLoginResponse abre una sesión para el usuario y arma la respuesta del login
+func (service *TokenService) NewLoginResponse(user *models.User, client ClientInfo) (*LoginResponse, error) {
+	// Abrir una sesión nueva: token de acceso de corta duración + token de actualización
+	tokens, err := service.StartSession(user, client)
+	if err != nil {
+		return nil, errors.New("Error al generar el token de autenticación.")
+	}
+
+	// Limpiar la contraseña antes de devolver los datos
+	user.Password = ""
+
+	return &LoginResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         user,
+	}, nil
+}
+
+// Refresh rota el token de actualización: invalida el presentado y emite uno
+// nuevo de la misma familia junto con un token de acceso nuevo
+func (service *TokenService) Refresh(refreshToken string) (*TokenPair, error) {
+	stored, err := service.TokenRepo.ConsumeRefreshToken(auth.HashOpaqueToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repositories.ErrRefreshTokenReused) {
+			log.Printf("Reutilización de token de actualización detectada, sesión %s revocada", stored.Familia)
+			return nil, ErrInvalidRefreshToken
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if stored.RevocadoEn.Valid || time.Now().After(stored.ExpiraEn) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Se vuelve a leer el usuario para que los cambios de rol se apliquen al rotar
+	user, err := service.UserRepo.GetUserByID(stored.IdUsuario)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if service.Sessions != nil {
+		service.Sessions.TouchSession(stored.Familia)
+	}
+	return service.issuePair(user, stored.Familia)
+}
+
+// Logout revoca la sesión del token de acceso actual y el propio token de acceso.
+// Si se envía un token de actualización de otra sesión del mismo usuario, también se revoca.
+func (service *TokenService) Logout(ctx context.Context, refreshToken string) error {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if principal.SessionID != "" {
+		if err := service.TokenRepo.RevokeFamily(principal.SessionID); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken != "" {
+		stored, err := service.TokenRepo.FindRefreshToken(auth.HashOpaqueToken(refreshToken))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if stored != nil && stored.IdUsuario == principal.UserID && stored.Familia != principal.SessionID {
+			if err := service.TokenRepo.RevokeFamily(stored.Familia); err != nil {
+				return err
+			}
+		}
+	}
+
+	if principal.TokenID != "" {
+		return service.TokenRepo.RevokeAccessToken(principal.TokenID, principal.ExpiresAt)
+	}
+	return nil
+}
+
+// RevokeAllSessions cierra todas las sesiones del usuario
+func (service *TokenService) RevokeAllSessions(userID int) error {
+	return service.TokenRepo.RevokeAllForUser(userID)
+}
+
+// RevokeOtherSessions cierra todas las sesiones del usuario menos la actual
+func (service *TokenService) RevokeOtherSessions(userID int, currentSession string) error {
+	if currentSession == "" {
+		return service.TokenRepo.RevokeAllForUser(userID)
+	}
+	return service.TokenRepo.RevokeAllForUserExcept(userID, currentSession)
+}
+
+// IsRevoked indica si un token de acceso válido fue revocado, ya sea por su jti
+// o porque se revocó la sesión a la que pertenece
+func (service *TokenService) IsRevoked(claims *auth.Claims) (bool, error) {
+	if claims.Id != "" {
+		revoked, err := service.TokenRepo.IsAccessTokenRevoked(claims.Id)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+	if claims.SessionID != "" {
+		return service.TokenRepo.IsFamilyRevoked(claims.SessionID)
+	}
+	return false, nil
+}
+
+func (service *TokenService) issuePair(user *models.User, sessionID string) (*TokenPair, error) {
+	accessToken, expiresAt, err := service.Tokens.IssueAccessToken(auth.Claims{
+		UserID:        user.ID,
+		Role:          user.Rol,
+		EmailVerified: user.EmailVerificado,
+		SessionID:     sessionID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := service.TokenRepo.CreateRefreshToken(user.ID, refreshHash, sessionID, time.Now().UTC().Add(service.RefreshTTL)); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(time.Until(expiresAt).Seconds()),
+	}, nil
+}
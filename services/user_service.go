@@ -1,79 +1,305 @@
 package services
 
 import (
+	"context"
+	"database/sql"
+	"dbconnection/auth"
 	"dbconnection/models"
 	"dbconnection/repositories"
+	"dbconnection/utils"
 	"errors"
-	"time"
-
-	"github.com/dgrijalva/jwt-go"
+	"fmt"
+	"log"
+	"sync"
 )
 
 type UserService struct {
-	UserRepo *repositories.UserRepository
+	UserRepo          *repositories.UserRepository
+	MagicLinkRepo     *repositories.MagicLinkRepository // Para anular los enlaces enviados al correo anterior
+	TokenService      *TokenService
+	EmailVerification *EmailVerificationService
+	LoginThrottle     *LoginThrottleService
+	MFA               *MFAService
+	Audit             *AuditService
+	PasswordPolicy    *auth.PasswordPolicy
+	BcryptCost        int
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
-var jwtKey = []byte("ASDFGHLJKQKWJDAKSDQWPWEASDL")
+// ErrInvalidCredentials es el único error que ve el cliente cuando falla el
+// login, exista o no la cuenta
+var ErrInvalidCredentials = errors.New("Credenciales inválidas.")
 
-// Estructura de respuesta para el login (token + datos del usuario)
+// Estructura de respuesta para el login (tokens + datos del usuario). Si el
+// usuario tiene activada la verificación en dos pasos, el primer paso solo
+// devuelve MFARequired y MFAToken.
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  *models.User `json:"user"`
+	Token        string       `json:"token,omitempty"`
+	RefreshToken string       `json:"refresh_token,omitempty"`
+	ExpiresIn    int          `json:"expires_in,omitempty"` // Segundos de vigencia del token de acceso
+	User         *models.User `json:"user,omitempty"`
+
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+
+	// En el modo de sesión con cookies los tokens van en cookies HttpOnly y el
+	// cuerpo solo trae el token CSRF que se debe repetir en cada solicitud
+	CSRFToken string `json:"csrf_token,omitempty"`
 }
 
-func (service *UserService) Login(email, password string) (*LoginResponse, error) {
+func (service *UserService) Login(email, password string, client ClientInfo) (*LoginResponse, error) {
+	// Rechazar de inmediato si la cuenta o la IP están bloqueadas por intentos fallidos
+	if err := service.LoginThrottle.Check(email, client.IP); err != nil {
+		return nil, err
+	}
+
 	// Buscar el usuario por email
 	user, err := service.UserRepo.GetUserByEmail(email)
 	if err != nil {
-		if err.Error() == "usuario no encontrado" {
-			return nil, errors.New("No se encontró un usuario con el email proporcionado.")
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			// Comparar contra un hash ficticio para que el tiempo de respuesta no
+			// revele si la cuenta existe
+			utils.CheckPassword(service.getDummyHash(), password, service.BcryptCost)
+			service.LoginThrottle.RecordFailure(email, client.IP)
+			return nil, ErrInvalidCredentials
 		}
-		return nil, errors.New("Error al buscar usuario en la base de datos.")
+		return nil, fmt.Errorf("Error al buscar usuario en la base de datos: %v", err)
+	}
+
+	// Verificar la contraseña contra el hash (o el texto plano de usuarios antiguos)
+	ok, needsRehash := utils.CheckPassword(user.Password, password, service.BcryptCost)
+	if !ok {
+		service.LoginThrottle.RecordFailure(email, client.IP)
+		return nil, ErrInvalidCredentials
 	}
 
-	// Verificar si la contraseña coincide usando una comparación de texto plano
-	if user.Password != password {
-		// Si la comparación falla, significa que la contraseña es incorrecta
-		return nil, errors.New("La contraseña proporcionada es incorrecta.")
+	// Migrar las contraseñas en texto plano (o con otro costo) al hash actual
+	if needsRehash {
+		if hash, err := utils.HashPassword(password, service.BcryptCost); err != nil {
+			log.Printf("Error al generar el hash de la contraseña del usuario %d: %v", user.ID, err)
+		} else if err := service.UserRepo.UpdatePassword(user.ID, hash); err != nil {
+			log.Printf("No se pudo migrar la contraseña del usuario %d: %v", user.ID, err)
+		}
 	}
 
-	// Crear el token JWT
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userId": user.ID,
-		"exp":    time.Now().Add(time.Hour * 24).Unix(), // El token expira en 24 horas
+	// Con la verificación en dos pasos activada, los fallos siguen contando hasta
+	// que se valide el código
+	mfaEnabled, err := service.MFA.IsEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaEnabled {
+		return service.MFA.BeginChallenge(user)
+	}
+	service.LoginThrottle.RecordSuccess(email)
+
+	return service.TokenService.NewLoginResponse(user, client)
+}
+
+// getDummyHash genera una sola vez el hash usado cuando el email no existe
+func (service *UserService) getDummyHash() string {
+	service.dummyHashOnce.Do(func() {
+		hash, err := utils.HashPassword("contraseña-inexistente", service.BcryptCost)
+		if err != nil {
+			log.Printf("Error al generar el hash ficticio: %v", err)
+		}
+		service.dummyHash = hash
 	})
+	return service.dummyHash
+}
 
-	// Firmar el token con la clave secreta
-	tokenString, err := token.SignedString(jwtKey)
+func (service *UserService) RegisterUser(ctx context.Context, user *models.User) (*models.User, error) {
+	if err := checkNewPassword(service.PasswordPolicy, "contraseña", user.Password, user); err != nil {
+		return nil, err
+	}
+
+	// Guardar solo el hash de la contraseña
+	hash, err := utils.HashPassword(user.Password, service.BcryptCost)
 	if err != nil {
-		return nil, errors.New("Error al generar el token de autenticación.")
+		return nil, fmt.Errorf("error al generar el hash de la contraseña: %v", err)
 	}
+	user.Password = hash
 
-	// Limpiar la contraseña antes de devolver los datos
-	user.Password = "" // Dejar la contraseña vacía para que no se incluya en la respuesta
+	// La cuenta se crea sin verificar y se envía el enlace de verificación
+	createdUser, err := service.UserRepo.CreateUser(user)
+	if err != nil {
+		return nil, err
+	}
+	service.Audit.Record(ctx, AuditCreate, AuditEntityUser, createdUser.ID, nil, createdUser)
+	if err := service.EmailVerification.SendVerification(createdUser); err != nil {
+		log.Printf("No se pudo enviar la verificación de correo al usuario %d: %v", createdUser.ID, err)
+	}
+
+	return createdUser, nil
+}
+
+// ChangePassword cambia la contraseña del usuario autenticado tras comprobar la
+// actual. Las demás sesiones se cierran; la sesión desde la que se hizo el
+// cambio sigue abierta.
+func (service *UserService) ChangePassword(ctx context.Context, currentPassword, newPassword string) error {
+	principal, err := requireCredentialAccess(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := service.UserRepo.GetUserByID(principal.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	hash, err := service.UserRepo.GetPasswordHash(user.ID)
+	if err != nil {
+		return err
+	}
+	if ok, _ := utils.CheckPassword(hash, currentPassword, service.BcryptCost); !ok {
+		return &ValidationError{Fields: map[string][]string{"contraseña_actual": {"no es correcta"}}}
+	}
+	if newPassword == currentPassword {
+		return &ValidationError{Fields: map[string][]string{"contraseña_nueva": {"debe ser distinta de la actual"}}}
+	}
+	if err := checkNewPassword(service.PasswordPolicy, "contraseña_nueva", newPassword, user); err != nil {
+		return err
+	}
 
-	// Retornar el token y los datos del usuario
-	return &LoginResponse{
-		Token: tokenString,
-		User:  user,
-	}, nil
+	newHash, err := utils.HashPassword(newPassword, service.BcryptCost)
+	if err != nil {
+		return fmt.Errorf("error al generar el hash de la contraseña: %v", err)
+	}
+	if err := service.UserRepo.UpdatePassword(user.ID, newHash); err != nil {
+		return err
+	}
+	service.Audit.Record(ctx, AuditPasswordChange, AuditEntityUser, user.ID, nil, nil)
+
+	if err := service.TokenService.RevokeOtherSessions(user.ID, principal.SessionID); err != nil {
+		log.Printf("No se pudieron revocar las otras sesiones del usuario %d: %v", user.ID, err)
+	}
+	return nil
 }
 
-func (service *UserService) RegisterUser(user *models.User) (*models.User, error) {
-	return service.UserRepo.CreateUser(user)
+// checkNewPassword aplica la política de contraseñas y devuelve los problemas
+// como un ValidationError del campo indicado. Sin política solo se exige que
+// no esté vacía.
+func checkNewPassword(policy *auth.PasswordPolicy, field, password string, user *models.User) error {
+	var problems []string
+	if policy != nil {
+		problems = policy.Check(password, user.Email, user.Nombre)
+	} else if password == "" {
+		problems = []string{"no puede estar vacía"}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Fields: map[string][]string{field: problems}}
+	}
+	return nil
 }
 
 func (service *UserService) GetUserProfile(id int) (*models.User, error) {
 	return service.UserRepo.GetUserByID(id)
 }
 
-// Función para actualizar el perfil del usuario
-func (service *UserService) UpdateUserProfile(id int, user *models.User) (*models.User, error) {
+// CheckProfileOwnership verifica que el usuario autenticado sea el dueño del perfil o un administrador
+func (service *UserService) CheckProfileOwnership(ctx context.Context, id int) error {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return err
+	}
+	if !auth.Can(principal, auth.ActionUpdateProfile, id) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Función para actualizar el perfil del usuario; solo el propio usuario o un administrador pueden modificarlo
+func (service *UserService) UpdateUserProfile(ctx context.Context, id int, user *models.User) (*models.User, error) {
+	if err := service.CheckProfileOwnership(ctx, id); err != nil {
+		return nil, err
+	}
+
+	current, err := service.UserRepo.GetUserByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	// El correo recibe los enlaces para restablecer la contraseña e iniciar
+	// sesión, así que cambiarlo es cambiar una credencial: no se permite durante
+	// una suplantación
+	if user.Email != current.Email {
+		if _, err := requireCredentialAccess(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	// Llamamos al repositorio para actualizar el perfil
 	updatedUser, err := service.UserRepo.UpdateUserProfile(id, user)
 	if err != nil {
 		return nil, err
 	}
+	service.Audit.Record(ctx, AuditUpdate, AuditEntityUser, id, current, updatedUser)
+
+	// Un correo nuevo debe verificarse otra vez, y los enlaces de inicio de
+	// sesión enviados al anterior dejan de valer
+	if updatedUser.Email != current.Email {
+		if err := service.MagicLinkRepo.DeleteUnusedTokens(id); err != nil {
+			return nil, err
+		}
+		if err := service.UserRepo.SetEmailVerified(id, false); err != nil {
+			return nil, err
+		}
+		updatedUser.EmailVerificado = false
+		if err := service.EmailVerification.SendVerification(updatedUser); err != nil {
+			log.Printf("No se pudo enviar la verificación de correo al usuario %d: %v", id, err)
+		}
+	}
 
 	return updatedUser, nil
 }
+
+// SetRole asigna un rol a un usuario; solo los administradores pueden hacerlo.
+// Las sesiones del usuario se revocan para que el nuevo rol se aplique de inmediato.
+func (service *UserService) SetRole(ctx context.Context, id int, role string) (*models.User, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !auth.Can(principal, auth.ActionManageUsers, 0) {
+		return nil, ErrForbidden
+	}
+	if !auth.ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	// Evita que un administrador se quite a sí mismo el acceso de administración
+	if id == principal.UserID && role != auth.RoleAdmin {
+		return nil, ErrForbidden
+	}
+
+	current, err := service.UserRepo.GetUserByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if err := service.UserRepo.UpdateRole(id, role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := service.TokenService.RevokeAllSessions(id); err != nil {
+		log.Printf("No se pudieron revocar las sesiones del usuario %d: %v", id, err)
+	}
+
+	updated, err := service.UserRepo.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+	service.Audit.Record(ctx, AuditRoleChange, AuditEntityUser, id, current, updated)
+	return updated, nil
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"dbconnection/auth"
+	"dbconnection/models"
+	"dbconnection/repositories"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+var (
+	// ErrInvalidWebAuthnSession indica que la ceremonia no existe, expiró o ya se completó
+	ErrInvalidWebAuthnSession = errors.New("la solicitud de passkey expiró o ya se usó")
+	// ErrWebAuthnFailed agrupa los motivos por los que se rechaza una passkey
+	ErrWebAuthnFailed = errors.New("no se pudo validar la passkey")
+)
+
+const (
+	maxCredentialNameLength = 100
+	maxCredentialIDLength   = 255 // Tamaño de la columna credential_id
+)
+
+type WebAuthnService struct {
+	WebAuthn     *webauthn.WebAuthn
+	WebAuthnRepo *repositories.WebAuthnRepository
+	UserRepo     *repositories.UserRepository
+	TokenService *TokenService
+	SessionTTL   time.Duration // Tiempo para completar la ceremonia en el navegador
+}
+
+// WebAuthnCeremony son las opciones que el frontend pasa a
+// navigator.credentials.create/get, junto con el identificador que debe
+// devolver al terminar
+type WebAuthnCeremony struct {
+	SessionID string      `json:"session_id"`
+	Options   interface{} `json:"options"`
+}
+
+// webAuthnUser adapta un usuario a la interfaz que pide la librería. El user
+// handle es el ID del usuario, que no revela datos personales.
+type webAuthnUser struct {
+	user        *models.User
+	credentials []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte                         { return userHandle(u.user.ID) }
+func (u *webAuthnUser) WebAuthnName() string                       { return u.user.Email }
+func (u *webAuthnUser) WebAuthnDisplayName() string                { return u.user.Nombre }
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+func userHandle(userID int) []byte {
+	return []byte(strconv.Itoa(userID))
+}
+
+// BeginRegistration genera el desafío para registrar una passkey nueva del
+// usuario autenticado; las que ya tiene se excluyen para no duplicarlas
+func (service *WebAuthnService) BeginRegistration(ctx context.Context) (*WebAuthnCeremony, error) {
+	principal, err := requireCredentialAccess(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := service.loadUser(principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	creation, session, err := service.WebAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := service.saveSession(user.user.ID, session)
+	if err != nil {
+		return nil, err
+	}
+	return &WebAuthnCeremony{SessionID: sessionID, Options: creation}, nil
+}
+
+// FinishRegistration valida la respuesta del autenticador y guarda la passkey
+func (service *WebAuthnService) FinishRegistration(ctx context.Context, sessionID, name string, response []byte) (*models.WebAuthnCredential, error) {
+	principal, err := requireCredentialAccess(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, session, err := service.consumeSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if userID != principal.UserID {
+		return nil, ErrInvalidWebAuthnSession
+	}
+
+	user, err := service.loadUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		log.Printf("Respuesta de registro de passkey inválida: %v", err)
+		return nil, ErrWebAuthnFailed
+	}
+	credential, err := service.WebAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		log.Printf("Registro de passkey rechazado para el usuario %d: %v", userID, err)
+		return nil, ErrWebAuthnFailed
+	}
+
+	if len(credential.ID) > maxCredentialIDLength {
+		return nil, ErrWebAuthnFailed
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len([]rune(name)) > maxCredentialNameLength {
+		name = string([]rune(name)[:maxCredentialNameLength])
+	}
+
+	stored := &models.WebAuthnCredential{
+		IdUsuario:    userID,
+		CredentialID: credential.ID,
+		Nombre:       name,
+		Datos:        data,
+		Contador:     credential.Authenticator.SignCount,
+	}
+	if err := service.WebAuthnRepo.CreateCredential(stored); err != nil {
+		return nil, err
+	}
+	log.Printf("Passkey %d registrada para el usuario %d", stored.ID, userID)
+	return stored, nil
+}
+
+// ListCredentials devuelve las passkeys del usuario autenticado
+func (service *WebAuthnService) ListCredentials(ctx context.Context) ([]models.WebAuthnCredential, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return service.WebAuthnRepo.ListCredentials(principal.UserID)
+}
+
+// DeleteCredential quita una passkey del usuario autenticado
+func (service *WebAuthnService) DeleteCredential(ctx context.Context, id int) error {
+	principal, err := requireCredentialAccess(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = service.WebAuthnRepo.DeleteCredential(id, principal.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// BeginLogin genera el desafío para iniciar sesión con una passkey. No se pide
+// el correo: el autenticador elige la credencial y devuelve el user handle.
+func (service *WebAuthnService) BeginLogin() (*WebAuthnCeremony, error) {
+	assertion, session, err := service.WebAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := service.saveSession(0, session)
+	if err != nil {
+		return nil, err
+	}
+	return &WebAuthnCeremony{SessionID: sessionID, Options: assertion}, nil
+}
+
+// FinishLogin valida la firma de la passkey y emite los mismos tokens que el
+// login con contraseña. La passkey con verificación del usuario (PIN o
+// biometría) ya cuenta como dos factores, así que no se pide el código TOTP.
+func (service *WebAuthnService) FinishLogin(sessionID string, response []byte, client ClientInfo) (*LoginResponse, error) {
+	userID, session, err := service.consumeSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if userID != 0 {
+		return nil, ErrInvalidWebAuthnSession
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		log.Printf("Respuesta de inicio de sesión con passkey inválida: %v", err)
+		return nil, ErrWebAuthnFailed
+	}
+
+	// La librería pide el dueño de la credencial a partir de lo que envía el autenticador
+	var stored *models.WebAuthnCredential
+	handler := func(rawID, handle []byte) (webauthn.User, error) {
+		found, err := service.WebAuthnRepo.FindCredential(rawID)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(handle, userHandle(found.IdUsuario)) {
+			return nil, errors.New("el user handle no corresponde a la credencial")
+		}
+		stored = found
+		return service.loadUser(found.IdUsuario)
+	}
+
+	user, credential, err := service.WebAuthn.ValidatePasskeyLogin(handler, *session, parsed)
+	if err != nil {
+		log.Printf("Inicio de sesión con passkey rechazado: %v", err)
+		return nil, ErrWebAuthnFailed
+	}
+
+	// Un contador que no avanza indica que la llave privada pudo haberse copiado
+	if credential.Authenticator.CloneWarning {
+		log.Printf("Contador de firmas de la passkey %d no avanzó; posible autenticador clonado", stored.ID)
+		return nil, ErrWebAuthnFailed
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return nil, err
+	}
+	err = service.WebAuthnRepo.UpdateAfterLogin(stored.ID, stored.Contador, credential.Authenticator.SignCount, data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWebAuthnFailed
+		}
+		return nil, err
+	}
+
+	return service.TokenService.NewLoginResponse(user.(*webAuthnUser).user, client)
+}
+
+// loadUser lee el usuario junto con sus passkeys
+func (service *WebAuthnService) loadUser(userID int) (*webAuthnUser, error) {
+	user, err := service.UserRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := service.WebAuthnRepo.ListCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+	credentials := make([]webauthn.Credential, 0, len(stored))
+	for _, item := range stored {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(item.Datos, &credential); err != nil {
+			log.Printf("No se pudo leer la passkey %d: %v", item.ID, err)
+			continue
+		}
+		credentials = append(credentials, credential)
+	}
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+// saveSession guarda los datos de la ceremonia y devuelve el identificador que
+// recibe el cliente; en la base de datos solo queda su hash
+func (service *WebAuthnService) saveSession(userID int, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	sessionID, sessionHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	if err := service.WebAuthnRepo.CreateSession(sessionHash, userID, data, time.Now().UTC().Add(service.SessionTTL)); err != nil {
+		return "", err
+	}
+	return sessionID, nil
+}
+
+func (service *WebAuthnService) consumeSession(sessionID string) (int, *webauthn.SessionData, error) {
+	userID, data, err := service.WebAuthnRepo.ConsumeSession(auth.HashOpaqueToken(sessionID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil, ErrInvalidWebAuthnSession
+		}
+		return 0, nil, err
+	}
+
+	session := &webauthn.SessionData{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return 0, nil, err
+	}
+	return userID, session, nil
+}
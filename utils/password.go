@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashPassword genera el hash bcrypt de la contraseña con el costo indicado
+func HashPassword(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsPasswordHash indica si el valor guardado ya es un hash bcrypt y no una
+// contraseña en texto plano de las filas anteriores a la migración
+func IsPasswordHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// CheckPassword compara la contraseña con el valor guardado en tiempo constante.
+// needsRehash es true cuando la contraseña es correcta pero el valor guardado
+// está en texto plano o usa un costo distinto al configurado.
+func CheckPassword(stored, password string, cost int) (ok bool, needsRehash bool) {
+	if !IsPasswordHash(stored) {
+		// Se comparan los digest para que el tiempo no dependa de la longitud
+		storedSum := sha256.Sum256([]byte(stored))
+		passwordSum := sha256.Sum256([]byte(password))
+		ok = subtle.ConstantTimeCompare(storedSum[:], passwordSum[:]) == 1
+		return ok, ok
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)); err != nil {
+		return false, false
+	}
+
+	currentCost, err := bcrypt.Cost([]byte(stored))
+	return true, err == nil && currentCost != cost
+}
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// accentFolds quita los acentos más comunes del español y otros idiomas
+// latinos. Cada letra se cambia por una sola letra, así las posiciones del
+// texto normalizado coinciden con las del original.
+var accentFolds = map[rune]rune{
+	'á': 'a', 'à': 'a', 'â': 'a', 'ä': 'a', 'ã': 'a', 'å': 'a',
+	'é': 'e', 'è': 'e', 'ê': 'e', 'ë': 'e',
+	'í': 'i', 'ì': 'i', 'î': 'i', 'ï': 'i',
+	'ó': 'o', 'ò': 'o', 'ô': 'o', 'ö': 'o', 'õ': 'o',
+	'ú': 'u', 'ù': 'u', 'û': 'u', 'ü': 'u',
+	'ñ': 'n', 'ç': 'c', 'ý': 'y', 'ÿ': 'y',
+}
+
+// FoldRunes pasa el texto a minúsculas y sin acentos, runa por runa
+func FoldRunes(text string) []rune {
+	runes := []rune(text)
+	for i, r := range runes {
+		r = unicode.ToLower(r)
+		if folded, ok := accentFolds[r]; ok {
+			r = folded
+		}
+		runes[i] = r
+	}
+	return runes
+}
+
+// FoldText es FoldRunes como cadena: "Tecnología" -> "tecnologia"
+func FoldText(text string) string {
+	return string(FoldRunes(text))
+}
+
+// minSearchTermLength descarta las palabras de una letra, que coinciden con casi todo
+const minSearchTermLength = 2
+
+// SearchTerms separa la consulta en palabras normalizadas con FoldText, sin
+// repetir y descartando signos de puntuación
+func SearchTerms(query string) []string {
+	seen := map[string]bool{}
+	terms := []string{}
+	for _, word := range strings.FieldsFunc(FoldText(query), func(r rune) bool {
+		return !isWordRune(r)
+	}) {
+		if len([]rune(word)) >= minSearchTermLength && !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// wordPrefixMatches devuelve dónde aparece el término al inicio de una palabra
+// del texto normalizado, igual que una búsqueda por prefijo ("expo" encuentra
+// "exposición" pero no "multiexpo")
+func wordPrefixMatches(folded []rune, term string) []int {
+	needle := []rune(term)
+	var matches []int
+	if len(needle) == 0 {
+		return nil
+	}
+	for i := 0; i+len(needle) <= len(folded); i++ {
+		if i > 0 && isWordRune(folded[i-1]) {
+			continue
+		}
+		if string(folded[i:i+len(needle)]) == term {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// CountMatches cuenta las palabras del texto que empiezan con el término
+// normalizado, sin distinguir mayúsculas ni acentos
+func CountMatches(text, term string) int {
+	return len(wordPrefixMatches(FoldRunes(text), term))
+}
+
+// Highlight escapa el texto como HTML y envuelve en <mark> las palabras que
+// empiezan con los términos (ya normalizados), sin distinguir mayúsculas ni
+// acentos. Si maxRunes es mayor que cero, devuelve solo un fragmento de ese
+// tamaño alrededor de la primera aparición.
+func Highlight(text string, terms []string, maxRunes int) string {
+	original := []rune(text)
+	folded := FoldRunes(text)
+
+	marked := make([]bool, len(original))
+	first := -1
+	for _, term := range terms {
+		length := len([]rune(term))
+		for _, i := range wordPrefixMatches(folded, term) {
+			for j := i; j < i+length; j++ {
+				marked[j] = true
+			}
+			if first == -1 || i < first {
+				first = i
+			}
+		}
+	}
+
+	start, end := 0, len(original)
+	if maxRunes > 0 && len(original) > maxRunes {
+		// Dejar algo de contexto antes de la primera aparición
+		if first > maxRunes/4 {
+			start = first - maxRunes/4
+		}
+		if start+maxRunes < end {
+			end = start + maxRunes
+		} else {
+			start = end - maxRunes
+		}
+	}
+
+	var builder strings.Builder
+	if start > 0 {
+		builder.WriteString("…")
+	}
+	inMark := false
+	for i := start; i < end; i++ {
+		if marked[i] != inMark {
+			if marked[i] {
+				builder.WriteString("<mark>")
+			} else {
+				builder.WriteString("</mark>")
+			}
+			inMark = marked[i]
+		}
+		builder.WriteString(html.EscapeString(string(original[i])))
+	}
+	if inMark {
+		builder.WriteString("</mark>")
+	}
+	if end < len(original) {
+		builder.WriteString("…")
+	}
+	return builder.String()
+}
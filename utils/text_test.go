@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFoldText(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Tecnología", "tecnologia"},
+		{"tecnologia", "tecnologia"},
+		{"ÑANDÚ", "nandu"},
+		{"Ça va, Müller", "ca va, muller"},
+		{"Feria 2030", "feria 2030"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got := FoldText(tt.text)
+		if got != tt.want {
+			t.Errorf("FoldText(%q) = %q, se esperaba %q", tt.text, got, tt.want)
+		}
+		// Highlight usa las posiciones del texto normalizado en el original
+		if len([]rune(got)) != len([]rune(tt.text)) {
+			t.Errorf("FoldText(%q) cambió la cantidad de runas", tt.text)
+		}
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"Tecnología", []string{"tecnologia"}},
+		{"Expo, expo; EXPO!", []string{"expo"}},
+		{"a la feria", []string{"la", "feria"}},
+		{"año 2030", []string{"ano", "2030"}},
+		{"  ¿?  ", []string{}},
+		{"", []string{}},
+	}
+	for _, tt := range tests {
+		if got := SearchTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SearchTerms(%q) = %q, se esperaba %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestCountMatches(t *testing.T) {
+	tests := []struct {
+		name, text, term string
+		want             int
+	}{
+		{"acento en el texto", "Feria de Tecnología", "tecnologia", 1},
+		{"acento en la consulta", "feria de tecnologia", SearchTerms("tecnología")[0], 1},
+		{"prefijo de palabra", "Exposición de artesanías", "expo", 1},
+		{"dentro de otra palabra", "multiexpo", "expo", 0},
+		{"separado por guion", "expo-feria, expo", "expo", 2},
+		{"repetido sin separar", "expoexpo", "expo", 1},
+		{"sin coincidencias", "feria del libro", "expo", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CountMatches(tt.text, tt.term); got != tt.want {
+				t.Errorf("CountMatches(%q, %q) = %d, se esperaba %d", tt.text, tt.term, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	const long = "uno dos tres cuatro cinco seis siete ocho nueve diez"
+
+	tests := []struct {
+		name     string
+		text     string
+		terms    []string
+		maxRunes int
+		want     string
+	}{
+		{"acento", "Feria de Tecnología", []string{"tecnologia"}, 0, "Feria de <mark>Tecnología</mark>"},
+		{"solo el prefijo", "Exposición y multiexpo", []string{"expo"}, 0, "<mark>Expo</mark>sición y multiexpo"},
+		{"varios términos", "feria expo", []string{"feria", "expo"}, 0, "<mark>feria</mark> <mark>expo</mark>"},
+		{"escapa el HTML", `<b>Expo</b> & "más"`, []string{"expo"}, 0, "&lt;b&gt;<mark>Expo</mark>&lt;/b&gt; &amp; &#34;más&#34;"},
+		{"escapa sin coincidencias", "<script>", []string{"expo"}, 0, "&lt;script&gt;"},
+		{"texto más corto que el fragmento", "feria expo", []string{"expo"}, 50, "feria <mark>expo</mark>"},
+		{"fragmento al inicio", long, []string{"uno"}, 12, "<mark>uno</mark> dos tres…"},
+		{"fragmento en medio", long, []string{"ocho"}, 12, "…te <mark>ocho</mark> nuev…"},
+		{"fragmento al final", long, []string{"diez"}, 12, "…o nueve <mark>diez</mark>"},
+		{"fragmento sin coincidencias", long, []string{"expo"}, 12, "uno dos tres…"},
+		{"el fragmento corta la coincidencia", long, []string{"cuatro"}, 5, "… <mark>cuat</mark>…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Highlight(tt.text, tt.terms, tt.maxRunes); got != tt.want {
+				t.Errorf("Highlight(%q, %q, %d) = %q, se esperaba %q", tt.text, tt.terms, tt.maxRunes, got, tt.want)
+			}
+		})
+	}
+}